@@ -0,0 +1,40 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+// BigEndianBuffer is a Buffer in big endian byte order, e.g. for network protocols and file formats like PNG,
+// MP4 boxes or Java class files.
+type BigEndianBuffer struct {
+	Buffer
+}
+
+// NewBigEndianBuffer returns a BigEndianBuffer, which reads and writes the given bytes starting at position 0.
+func NewBigEndianBuffer(b []byte) *BigEndianBuffer {
+	return &BigEndianBuffer{Buffer{Order: BigEndian, Bytes: b}}
+}
+
+// TypedBigEndianBuffer is a TypedBuffer in big endian byte order. It has the same format as the
+// TypedLittleEndianBuffer, except for the byte order of the values.
+type TypedBigEndianBuffer struct {
+	TypedBuffer
+}
+
+// NewTypedBigEndianBuffer returns a TypedBigEndianBuffer, which reads and writes the given bytes starting at
+// position 0.
+func NewTypedBigEndianBuffer(b []byte) *TypedBigEndianBuffer {
+	return &TypedBigEndianBuffer{TypedBuffer{Order: BigEndian, Bytes: b}}
+}
@@ -0,0 +1,161 @@
+package ioutil
+
+import (
+	"bytes"
+	"testing"
+)
+
+// positionalBuffer is the common API of LittleEndianBuffer and Buffer used by the tests.
+type positionalBuffer interface {
+	WriteUint8(v uint8)
+	WriteUint16(v uint16)
+	WriteUint24(v uint32)
+	WriteUint32(v uint32)
+	WriteUint40(v uint64)
+	WriteUint48(v uint64)
+	WriteUint56(v uint64)
+	WriteUint64(v uint64)
+	WriteFloat32(v float32)
+	WriteFloat64(v float64)
+	WriteComplex128(v complex128)
+	WriteBlob16(v []byte)
+	WriteString8(v string)
+	WriteUvarint(v uint64)
+	WriteType(typ Type)
+}
+
+func writePositional(b positionalBuffer) {
+	b.WriteUint8(0x01)
+	b.WriteUint16(0x0203)
+	b.WriteUint24(0x040506)
+	b.WriteUint32(0x0708090A)
+	b.WriteUint40(0x0B0C0D0E0F)
+	b.WriteUint48(0x101112131415)
+	b.WriteUint56(0x161718191A1B1C)
+	b.WriteUint64(0x1D1E1F2021222324)
+	b.WriteFloat32(1.5)
+	b.WriteFloat64(-2.5)
+	b.WriteComplex128(complex(1, 2))
+	b.WriteBlob16([]byte{1, 2, 3})
+	b.WriteString8("hello")
+	b.WriteUvarint(300)
+	b.WriteType(TRecord)
+}
+
+func writeDataOutput(dout DataOutput) {
+	dout.WriteUint8(0x01)
+	dout.WriteUint16(0x0203)
+	dout.WriteUint24(0x040506)
+	dout.WriteUint32(0x0708090A)
+	dout.WriteUint40(0x0B0C0D0E0F)
+	dout.WriteUint48(0x101112131415)
+	dout.WriteUint56(0x161718191A1B1C)
+	dout.WriteUint64(0x1D1E1F2021222324)
+	dout.WriteFloat32(1.5)
+	dout.WriteFloat64(-2.5)
+	dout.WriteComplex128(complex(1, 2))
+	dout.WriteBlob(I16, []byte{1, 2, 3})
+	dout.WriteUTF8(I8, "hello")
+	dout.WriteUvarint(300)
+	dout.WriteUint8(uint8(TRecord))
+}
+
+func TestBigEndianBuffer(t *testing.T) {
+	for _, order := range []ByteOrder{LittleEndian, BigEndian} {
+		expected := &bytes.Buffer{}
+		writeDataOutput(NewDataOutput(order, expected))
+
+		generic := &Buffer{Order: order, Bytes: make([]byte, expected.Len())}
+		writePositional(generic)
+
+		if !bytes.Equal(expected.Bytes(), generic.Bytes) {
+			t.Fatalf("%s: expected\n%x\nbut got\n%x", order, expected.Bytes(), generic.Bytes)
+		}
+	}
+
+	expected := &bytes.Buffer{}
+	writeDataOutput(NewDataOutput(BigEndian, expected))
+
+	be := NewBigEndianBuffer(make([]byte, expected.Len()))
+	writePositional(be)
+
+	if !bytes.Equal(expected.Bytes(), be.Bytes) {
+		t.Fatalf("expected\n%x\nbut got\n%x", expected.Bytes(), be.Bytes)
+	}
+
+	be.Pos = 0
+	if be.ReadUint8() != 0x01 || be.ReadUint16() != 0x0203 || be.ReadUint24() != 0x040506 ||
+		be.ReadUint32() != 0x0708090A || be.ReadUint40() != 0x0B0C0D0E0F || be.ReadUint48() != 0x101112131415 ||
+		be.ReadUint56() != 0x161718191A1B1C || be.ReadUint64() != 0x1D1E1F2021222324 {
+		t.Fatal("unexpected values")
+	}
+
+	if be.ReadFloat32() != 1.5 || be.ReadFloat64() != -2.5 || be.ReadComplex128() != complex(1, 2) {
+		t.Fatal("unexpected floats")
+	}
+
+	be.Pos = 0
+	for _, typ := range []Type{TUint8, TUint16, TUint24, TUint32, TUint40, TUint48, TUint56, TUint64, TFloat32,
+		TFloat64, TComplex128, TBlob16, TString8, TUvarint} {
+		be.Drain(typ)
+	}
+
+	if typ := be.ReadType(); typ != TRecord {
+		t.Fatalf("expected %s but got %s", TRecord, typ)
+	}
+
+	be = NewBigEndianBuffer(nestedArrays(maxTypedDepth + 1))
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for excessive nesting")
+		}
+	}()
+
+	be.Drain(be.ReadType())
+}
+
+func TestTypedBigEndianBuffer(t *testing.T) {
+	samples := typedSamples()
+	le := &TypedLittleEndianBuffer{Bytes: writeTypedSamples()}
+	be := NewTypedBigEndianBuffer(make([]byte, len(le.Bytes)))
+
+	for _, s := range samples {
+		be.WriteValue(s.typ, s.val)
+	}
+
+	if be.Pos != len(be.Bytes) {
+		t.Fatalf("expected the same size as the little endian format")
+	}
+
+	be.Pos = 0
+	for _, s := range samples {
+		typ, v := be.Next()
+		if typ != s.typ || !sameValue(v, s.val) {
+			t.Fatalf("expected %s %v but got %s %v", s.typ, s.val, typ, v)
+		}
+	}
+
+	// in little endian order the format is the same as the one of the TypedLittleEndianBuffer
+	generic := &TypedBuffer{Order: LittleEndian, Bytes: make([]byte, len(le.Bytes))}
+	for _, s := range samples {
+		generic.WriteValue(s.typ, s.val)
+	}
+
+	if !bytes.Equal(le.Bytes, generic.Bytes) {
+		t.Fatalf("expected\n%x\nbut got\n%x", le.Bytes, generic.Bytes)
+	}
+
+	be.Pos = 0
+	if be.ReadUint8() != 255 || be.ReadUint16() != 65535 || be.ReadUint24() != MaxUint24 {
+		t.Fatal("unexpected values")
+	}
+
+	rec := be.BeginRecord()
+	be.WriteInt(-70000)
+	be.EndRecord(rec)
+	be.Pos = rec - 1
+
+	if end := be.ReadRecord(); end != rec+4+4 || be.ReadInt() != -70000 {
+		t.Fatalf("unexpected record end %d", end)
+	}
+}
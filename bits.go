@@ -0,0 +1,219 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"io"
+	"strconv"
+)
+
+// BitOrder defines how bits are packed into bytes.
+type BitOrder int
+
+const (
+	// MSBFirst fills each byte starting at the most significant bit and writes the most significant bit of a
+	// value first. This is used by most media and network formats.
+	MSBFirst BitOrder = 0
+
+	// LSBFirst fills each byte starting at the least significant bit and writes the least significant bit of a
+	// value first. This is used e.g. by DEFLATE.
+	LSBFirst BitOrder = 1
+)
+
+// A BitReader reads values of 1 to 64 bits from a byte stream. It never reads ahead more than the current
+// byte, so after calling Align, the wrapped reader is positioned exactly at the next byte boundary and can be
+// used by a Decoder or DataInput again. As soon as any error occurred, any call is a no-op and will result in
+// the same error state. A BitReader is not thread safe.
+type BitReader struct {
+	in       io.Reader
+	order    BitOrder
+	buf      [1]byte
+	avail    uint // avail is the amount of unread bits in buf
+	firstErr error
+}
+
+// NewBitReader creates a new BitReader with the given bit order.
+func NewBitReader(in io.Reader, order BitOrder) *BitReader {
+	return &BitReader{in: in, order: order}
+}
+
+// ReadBits reads n bits, where n must be within [1, 64].
+func (r *BitReader) ReadBits(n int) uint64 {
+	if n < 1 || n > 64 {
+		panic("invalid bit count " + strconv.Itoa(n))
+	}
+
+	if r.firstErr != nil {
+		return 0
+	}
+
+	var v uint64
+
+	for got := 0; got < n; {
+		if r.avail == 0 {
+			if _, err := io.ReadFull(r.in, r.buf[:]); err != nil {
+				if got > 0 && err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+
+				r.firstErr = err
+
+				return 0
+			}
+
+			r.avail = 8
+		}
+
+		k := uint(n - got)
+		if k > r.avail {
+			k = r.avail
+		}
+
+		mask := uint64(1)<<k - 1
+
+		if r.order == MSBFirst {
+			bits := uint64(r.buf[0]>>(r.avail-k)) & mask
+			v = v<<k | bits
+		} else {
+			bits := uint64(r.buf[0]>>(8-r.avail)) & mask
+			v |= bits << uint(got)
+		}
+
+		r.avail -= k
+		got += int(k)
+	}
+
+	return v
+}
+
+// ReadBit reads a single bit.
+func (r *BitReader) ReadBit() bool {
+	return r.ReadBits(1) != 0
+}
+
+// Align discards the unread bits of the current byte.
+func (r *BitReader) Align() {
+	r.avail = 0
+}
+
+// Buffered returns the amount of bits, which can be read before the next byte boundary.
+func (r *BitReader) Buffered() int {
+	return int(r.avail)
+}
+
+// Reset removes any error state.
+func (r *BitReader) Reset() {
+	r.firstErr = nil
+}
+
+// Error returns the first occurred error. Each call to any Read* method may cause an error.
+func (r *BitReader) Error() error {
+	return r.firstErr
+}
+
+// A BitWriter writes values of 1 to 64 bits into a byte stream. Each completed byte is written immediately, so
+// after calling Align, the wrapped writer can be used by an Encoder or DataOutput again. As soon as any error
+// occurred, any call is a no-op and will result in the same error state. A BitWriter is not thread safe.
+type BitWriter struct {
+	out      io.Writer
+	order    BitOrder
+	buf      [1]byte
+	used     uint // used is the amount of written bits in buf
+	firstErr error
+}
+
+// NewBitWriter creates a new BitWriter with the given bit order.
+func NewBitWriter(out io.Writer, order BitOrder) *BitWriter {
+	return &BitWriter{out: out, order: order}
+}
+
+// WriteBits writes the lower n bits of v, where n must be within [1, 64].
+func (w *BitWriter) WriteBits(v uint64, n int) {
+	if n < 1 || n > 64 {
+		panic("invalid bit count " + strconv.Itoa(n))
+	}
+
+	if w.firstErr != nil {
+		return
+	}
+
+	for left := uint(n); left > 0; {
+		k := 8 - w.used
+		if k > left {
+			k = left
+		}
+
+		mask := uint64(1)<<k - 1
+
+		if w.order == MSBFirst {
+			bits := byte(v >> (left - k) & mask)
+			w.buf[0] |= bits << (8 - w.used - k)
+		} else {
+			bits := byte(v >> (uint(n) - left) & mask)
+			w.buf[0] |= bits << w.used
+		}
+
+		w.used += k
+		left -= k
+
+		if w.used == 8 {
+			w.flush()
+		}
+	}
+}
+
+// WriteBit writes a single bit.
+func (w *BitWriter) WriteBit(v bool) {
+	if v {
+		w.WriteBits(1, 1)
+	} else {
+		w.WriteBits(0, 1)
+	}
+}
+
+// Align pads the current byte with zero bits and writes it out, if any bits are pending.
+func (w *BitWriter) Align() {
+	if w.used > 0 && w.firstErr == nil {
+		w.flush()
+	}
+}
+
+// Buffered returns the amount of bits, which are pending until the next byte boundary.
+func (w *BitWriter) Buffered() int {
+	return int(w.used)
+}
+
+func (w *BitWriter) flush() {
+	_, err := w.out.Write(w.buf[:])
+	if err != nil && w.firstErr == nil {
+		w.firstErr = err
+	}
+
+	w.buf[0] = 0
+	w.used = 0
+}
+
+// Reset removes any error state.
+func (w *BitWriter) Reset() {
+	w.firstErr = nil
+}
+
+// Error returns the first occurred error. Each call to any Write* method may cause an error. Per definition,
+// any other call after the first error is a no-op.
+func (w *BitWriter) Error() error {
+	return w.firstErr
+}
@@ -0,0 +1,75 @@
+package ioutil
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestBitWriter_Order(t *testing.T) {
+	tests := []struct {
+		order    BitOrder
+		expected []byte
+	}{
+		{MSBFirst, []byte{0xDA, 0xBC}},
+		{LSBFirst, []byte{0xCB, 0xAB}},
+	}
+
+	for _, tt := range tests {
+		buf := &bytes.Buffer{}
+		w := NewBitWriter(buf, tt.order)
+		w.WriteBit(true)
+		w.WriteBits(0x5, 3)
+		w.WriteBits(0xABC, 12)
+		w.Align()
+
+		if w.Error() != nil {
+			t.Fatal(w.Error())
+		}
+
+		if !bytes.Equal(buf.Bytes(), tt.expected) {
+			t.Fatalf("expected %x but got %x", tt.expected, buf.Bytes())
+		}
+	}
+}
+
+func TestBitReaderWriter(t *testing.T) {
+	widths := []int{1, 3, 12, 7, 64, 5, 33, 8, 2, 63}
+
+	for _, order := range []BitOrder{MSBFirst, LSBFirst} {
+		buf := &bytes.Buffer{}
+		w := NewBitWriter(buf, order)
+
+		for i, n := range widths {
+			w.WriteBits((uint64(i)*0x9E3779B97F4A7C15)>>(64-uint(n)), n)
+		}
+
+		w.Align()
+
+		// the stream continues with byte aligned data
+		dout := NewDataOutput(BigEndian, buf)
+		dout.WriteUint24(0x123456)
+
+		r := NewBitReader(buf, order)
+
+		for i, n := range widths {
+			expected := (uint64(i) * 0x9E3779B97F4A7C15) >> (64 - uint(n))
+			if v := r.ReadBits(n); v != expected {
+				t.Fatalf("%d: expected %x but got %x", i, expected, v)
+			}
+		}
+
+		r.Align()
+
+		din := NewDataInput(BigEndian, buf)
+		if v := din.ReadUint24(); v != 0x123456 {
+			t.Fatalf("expected %x but got %x", 0x123456, v)
+		}
+
+		r.ReadBit()
+
+		if r.Error() != io.EOF {
+			t.Fatalf("expected EOF but got %v", r.Error())
+		}
+	}
+}
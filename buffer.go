@@ -0,0 +1,511 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+)
+
+// Buffer is a light weight helper to modify bytes within a buffer in the given byte order. It has the same
+// positional API as the LittleEndianBuffer but is slower, because each access goes through the ByteOrder
+// interface. The BigEndianBuffer and the TypedBuffer are built on top of it.
+type Buffer struct {
+	Order ByteOrder
+	Bytes []byte
+	Pos   int
+}
+
+func (f *Buffer) ReadUint8() uint8 {
+	b := f.Bytes[f.Pos]
+	f.Pos++
+	return b
+}
+
+func (f *Buffer) WriteUint8(v uint8) {
+	f.Bytes[f.Pos] = v
+	f.Pos++
+}
+
+// ReadUint16 reads 2 bytes.
+func (f *Buffer) ReadUint16() uint16 {
+	b := f.Bytes[f.Pos:]
+	f.Pos += 2
+	return f.Order.Uint16(b)
+}
+
+// WriteUint16 writes 2 bytes.
+func (f *Buffer) WriteUint16(v uint16) {
+	b := f.Bytes[f.Pos:]
+	f.Pos += 2
+	f.Order.PutUint16(b, v)
+}
+
+// ReadUint24 reads 3 bytes.
+func (f *Buffer) ReadUint24() uint32 {
+	b := f.Bytes[f.Pos:]
+	f.Pos += 3
+	return f.Order.Uint24(b)
+}
+
+// WriteUint24 writes 3 bytes.
+func (f *Buffer) WriteUint24(v uint32) {
+	b := f.Bytes[f.Pos:]
+	f.Pos += 3
+	f.Order.PutUint24(b, v)
+}
+
+// ReadUint32 reads 4 bytes.
+func (f *Buffer) ReadUint32() uint32 {
+	b := f.Bytes[f.Pos:]
+	f.Pos += 4
+	return f.Order.Uint32(b)
+}
+
+// WriteUint32 writes 4 bytes.
+func (f *Buffer) WriteUint32(v uint32) {
+	b := f.Bytes[f.Pos:]
+	f.Pos += 4
+	f.Order.PutUint32(b, v)
+}
+
+// ReadUint40 reads 5 bytes.
+func (f *Buffer) ReadUint40() uint64 {
+	b := f.Bytes[f.Pos:]
+	f.Pos += 5
+	return f.Order.Uint40(b)
+}
+
+// WriteUint40 writes 5 bytes.
+func (f *Buffer) WriteUint40(v uint64) {
+	b := f.Bytes[f.Pos:]
+	f.Pos += 5
+	f.Order.PutUint40(b, v)
+}
+
+// ReadUint48 reads 6 bytes.
+func (f *Buffer) ReadUint48() uint64 {
+	b := f.Bytes[f.Pos:]
+	f.Pos += 6
+	return f.Order.Uint48(b)
+}
+
+// WriteUint48 writes 6 bytes.
+func (f *Buffer) WriteUint48(v uint64) {
+	b := f.Bytes[f.Pos:]
+	f.Pos += 6
+	f.Order.PutUint48(b, v)
+}
+
+// ReadUint56 reads 7 bytes.
+func (f *Buffer) ReadUint56() uint64 {
+	b := f.Bytes[f.Pos:]
+	f.Pos += 7
+	return f.Order.Uint56(b)
+}
+
+// WriteUint56 writes 7 bytes.
+func (f *Buffer) WriteUint56(v uint64) {
+	b := f.Bytes[f.Pos:]
+	f.Pos += 7
+	f.Order.PutUint56(b, v)
+}
+
+// ReadUint64 reads 8 bytes.
+func (f *Buffer) ReadUint64() uint64 {
+	b := f.Bytes[f.Pos:]
+	f.Pos += 8
+	return f.Order.Uint64(b)
+}
+
+// WriteUint64 writes 8 bytes.
+func (f *Buffer) WriteUint64(v uint64) {
+	b := f.Bytes[f.Pos:]
+	f.Pos += 8
+	f.Order.PutUint64(b, v)
+}
+
+// WriteSlice copies the content of the given buffer into the destination
+func (f *Buffer) WriteSlice(v []byte) {
+	b := f.Bytes[f.Pos : f.Pos+len(v)]
+	copy(b, v)
+	f.Pos += len(v)
+}
+
+// ReadSlice reads fully into the given buffer
+func (f *Buffer) ReadSlice(v []byte) {
+	b := f.Bytes[f.Pos : f.Pos+len(v)]
+	copy(v, b)
+	f.Pos += len(v)
+}
+
+// ReadBlob8 reads up to 255 bytes. The blob is truncated.
+func (f *Buffer) ReadBlob8(v []byte) int {
+	vLen := f.ReadUint8()
+	vBuf := v[0:vLen]
+
+	f.ReadSlice(vBuf)
+	return int(vLen)
+}
+
+// WriteBlob8 writes up to 255 bytes. The blob is truncated.
+func (f *Buffer) WriteBlob8(v []byte) {
+	vLen := len(v)
+	if vLen > int(MaxUint8) {
+		vLen = int(MaxUint8)
+	}
+
+	f.WriteUint8(uint8(vLen))
+	f.WriteSlice(v[:vLen])
+}
+
+// ReadBlob16 reads up to 65535 bytes. The blob is truncated.
+func (f *Buffer) ReadBlob16(v []byte) int {
+	vLen := f.ReadUint16()
+	vBuf := v[0:vLen]
+
+	f.ReadSlice(vBuf)
+	return int(vLen)
+}
+
+// WriteBlob16 writes up to 65535 bytes. The blob is truncated.
+func (f *Buffer) WriteBlob16(v []byte) {
+	vLen := len(v)
+	if vLen > int(MaxUint16) {
+		vLen = int(MaxUint16)
+	}
+
+	f.WriteUint16(uint16(vLen))
+	f.WriteSlice(v[:vLen])
+}
+
+// ReadBlob16 reads up to 16777215 bytes. The blob is truncated.
+func (f *Buffer) ReadBlob24(v []byte) int {
+	vLen := f.ReadUint24()
+	vBuf := v[0:vLen]
+
+	f.ReadSlice(vBuf)
+	return int(vLen)
+}
+
+// WriteBlob16 writes up to 16777215 bytes. The blob is truncated.
+func (f *Buffer) WriteBlob24(v []byte) {
+	vLen := len(v)
+	if vLen > int(MaxUint24) {
+		vLen = int(MaxUint24)
+	}
+
+	f.WriteUint24(uint32(vLen))
+	f.WriteSlice(v[:vLen])
+}
+
+// ReadBlob32 reads up to 4294967295 bytes. The blob is truncated.
+func (f *Buffer) ReadBlob32(v []byte) int {
+	vLen := f.ReadUint32()
+	vBuf := v[0:vLen]
+
+	f.ReadSlice(vBuf)
+	return int(vLen)
+}
+
+// WriteBlob32 writes up to 4294967295 bytes. The blob is truncated.
+func (f *Buffer) WriteBlob32(v []byte) {
+	vLen := len(v)
+	if vLen > int(MaxUint32) {
+		vLen = int(MaxUint32)
+	}
+
+	f.WriteUint32(uint32(vLen))
+	f.WriteSlice(v[:vLen])
+}
+
+// WriteString8 writes the string like WriteBlob8, without converting it into a slice first. The string is
+// truncated.
+func (f *Buffer) WriteString8(v string) {
+	if len(v) > int(MaxUint8) {
+		v = v[:MaxUint8]
+	}
+
+	f.WriteUint8(uint8(len(v)))
+	f.Pos += copy(f.Bytes[f.Pos:f.Pos+len(v)], v)
+}
+
+// ReadString8 creates a (mutable) string, by using the strBuffer.
+func (f *Buffer) ReadString8(strBuffer []byte) string {
+	vLen := f.ReadBlob8(strBuffer)
+	return unsafeString(strBuffer[:vLen])
+}
+
+// WriteString16 writes the string like WriteBlob16, without converting it into a slice first. The string is
+// truncated.
+func (f *Buffer) WriteString16(v string) {
+	if len(v) > int(MaxUint16) {
+		v = v[:MaxUint16]
+	}
+
+	f.WriteUint16(uint16(len(v)))
+	f.Pos += copy(f.Bytes[f.Pos:f.Pos+len(v)], v)
+}
+
+// ReadString16 creates a (mutable) string, by using the strBuffer.
+func (f *Buffer) ReadString16(strBuffer []byte) string {
+	vLen := f.ReadBlob16(strBuffer)
+	return unsafeString(strBuffer[:vLen])
+}
+
+// WriteString24 writes the string like WriteBlob24, without converting it into a slice first. The string is
+// truncated.
+func (f *Buffer) WriteString24(v string) {
+	if len(v) > int(MaxUint24) {
+		v = v[:MaxUint24]
+	}
+
+	f.WriteUint24(uint32(len(v)))
+	f.Pos += copy(f.Bytes[f.Pos:f.Pos+len(v)], v)
+}
+
+// ReadString24 creates a (mutable) string, by using the strBuffer.
+func (f *Buffer) ReadString24(strBuffer []byte) string {
+	vLen := f.ReadBlob24(strBuffer)
+	return unsafeString(strBuffer[:vLen])
+}
+
+// WriteString32 writes the string like WriteBlob32, without converting it into a slice first. The string is
+// truncated.
+func (f *Buffer) WriteString32(v string) {
+	if len(v) > int(MaxUint32) {
+		v = v[:MaxUint32]
+	}
+
+	f.WriteUint32(uint32(len(v)))
+	f.Pos += copy(f.Bytes[f.Pos:f.Pos+len(v)], v)
+}
+
+// ReadString32 creates a (mutable) string, by using the strBuffer.
+func (f *Buffer) ReadString32(strBuffer []byte) string {
+	vLen := f.ReadBlob32(strBuffer)
+	return unsafeString(strBuffer[:vLen])
+}
+
+// ReadFloat64 reads 8 bytes and interprets them as a float64 IEEE 754 4 byte bit sequence.
+func (f *Buffer) ReadFloat64() float64 {
+	bits := f.ReadUint64()
+	return math.Float64frombits(bits)
+}
+
+// ReadFloat32 reads 4 bytes and interprets them as a float32 IEEE 754 4 byte bit sequence.
+func (f *Buffer) ReadFloat32() float32 {
+	bits := f.ReadUint32()
+	return math.Float32frombits(bits)
+}
+
+// WriteFloat32 writes a float32 IEEE 754 4 byte bit sequence.
+func (f *Buffer) WriteFloat32(v float32) {
+	bits := math.Float32bits(v)
+	f.WriteUint32(bits)
+}
+
+// WriteFloat64 writes a float64 IEEE 754 8 byte bit sequence.
+func (f *Buffer) WriteFloat64(v float64) {
+	bits := math.Float64bits(v)
+	f.WriteUint64(bits)
+}
+
+// ReadUvarint reads a variable length integer, up to 10 bytes using zig-zag protobuf encoding.
+func (f *Buffer) ReadUvarint() uint64 {
+	v, n := binary.Uvarint(f.Bytes[f.Pos:])
+	if n <= 0 {
+		panic("invalid uvarint at " + strconv.Itoa(f.Pos))
+	}
+
+	f.Pos += n
+	return v
+}
+
+// WriteUvarint writes a variable length integer, up to 10 bytes using zig-zag protobuf encoding.
+func (f *Buffer) WriteUvarint(v uint64) {
+	f.Pos += binary.PutUvarint(f.Bytes[f.Pos:], v)
+}
+
+// ReadVarint reads a variable length and signed integer, up to 10 bytes using zig-zag protobuf encoding.
+func (f *Buffer) ReadVarint() int64 {
+	v, n := binary.Varint(f.Bytes[f.Pos:])
+	if n <= 0 {
+		panic("invalid varint at " + strconv.Itoa(f.Pos))
+	}
+
+	f.Pos += n
+	return v
+}
+
+// WriteVarint writes a variable length and signed integer, up to 10 bytes using zig-zag protobuf encoding.
+func (f *Buffer) WriteVarint(v int64) {
+	f.Pos += binary.PutVarint(f.Bytes[f.Pos:], v)
+}
+
+// ReadComplex64 reads two float32 IEEE 754 4 byte bit sequences for the real and imaginary parts.
+func (f *Buffer) ReadComplex64() complex64 {
+	return complex(f.ReadFloat32(), f.ReadFloat32())
+}
+
+// ReadComplex128 reads two float64 IEEE 754 8 byte bit sequences for the real and imaginary parts.
+func (f *Buffer) ReadComplex128() complex128 {
+	return complex(f.ReadFloat64(), f.ReadFloat64())
+}
+
+// WriteComplex64 writes two float32 IEEE 754 4 byte bit sequences.
+func (f *Buffer) WriteComplex64(v complex64) {
+	f.WriteFloat32(real(v))
+	f.WriteFloat32(imag(v))
+}
+
+// WriteComplex128 writes two float64 IEEE 754 8 byte bit sequences.
+func (f *Buffer) WriteComplex128(v complex128) {
+	f.WriteFloat64(real(v))
+	f.WriteFloat64(imag(v))
+}
+
+// WriteType writes the type as uint8
+func (f *Buffer) WriteType(typ Type) {
+	f.WriteUint8(uint8(typ))
+}
+
+func (f *Buffer) ReadType() Type {
+	return Type(f.ReadUint8())
+}
+
+// DrainFast uses an inlineable jump table for fixed types and returns -1 for unsupported types. In that case, you
+// have to fallback into the slow Drain. See also https://github.com/golang/go/issues/17566
+func (f *Buffer) DrainFast(t Type) int {
+	x := drainJumpTable[t]
+	if x != 0 {
+		f.Pos += x
+		return x
+	}
+
+	return -1
+}
+
+// Drain moves the buffer position the right amount of bytes without actually parsing it. Containers are skipped
+// including all nested values, so that unknown fields can be ignored.
+func (f *Buffer) Drain(t Type) int {
+	return f.drain(t, 0)
+}
+
+// drain implements Drain for a value nested in depth containers.
+func (f *Buffer) drain(t Type, depth int) int {
+	oldPos := f.Pos
+	switch t {
+	case TInt8:
+		fallthrough
+	case TUint8:
+		f.Pos++
+	case TInt16:
+		fallthrough
+	case TUint16:
+		f.Pos += 2
+	case TInt24:
+		fallthrough
+	case TUint24:
+		f.Pos += 3
+	case TInt32:
+		fallthrough
+	case TUint32:
+		f.Pos += 4
+	case TInt40:
+		fallthrough
+	case TUint40:
+		f.Pos += 5
+	case TInt48:
+		fallthrough
+	case TUint48:
+		f.Pos += 6
+	case TInt56:
+		fallthrough
+	case TUint56:
+		f.Pos += 7
+	case TInt64:
+		fallthrough
+	case TUint64:
+		f.Pos += 8
+	case TString8:
+		fallthrough
+	case TBlob8:
+		vLen := int(f.ReadUint8())
+		f.Pos += vLen
+	case TString16:
+		fallthrough
+	case TBlob16:
+		vLen := int(f.ReadUint16())
+		f.Pos += vLen
+	case TString24:
+		fallthrough
+	case TBlob24:
+		vLen := int(f.ReadUint24())
+		f.Pos += vLen
+	case TString32:
+		fallthrough
+	case TBlob32:
+		vLen := int(f.ReadUint32())
+		f.Pos += vLen
+	case TFloat32:
+		f.Pos += 4
+	case TFloat64:
+		f.Pos += 8
+	case TComplex64:
+		f.Pos += 8
+	case TComplex128:
+		f.Pos += 16
+	case TBool:
+		f.Pos++
+	case TNil:
+	case TVarint:
+		fallthrough
+	case TUvarint:
+		f.ReadUvarint()
+	case TArray:
+		f.drainValues(f.ReadUvarint(), depth)
+	case TMap:
+		n := f.ReadUvarint()
+		if n > math.MaxUint64/2 {
+			panic("invalid map size " + strconv.FormatUint(n, 10))
+		}
+
+		f.drainValues(2*n, depth)
+	case TRecord:
+		vLen := int(f.ReadUint32())
+		f.Pos += vLen
+	default:
+		panic("not implemented " + strconv.Itoa(int(t)))
+	}
+	return f.Pos - oldPos
+}
+
+// drainValues skips n type prefixed values of a container nested in depth other containers.
+func (f *Buffer) drainValues(n uint64, depth int) {
+	if depth >= maxTypedDepth {
+		panic("containers nested deeper than " + strconv.Itoa(maxTypedDepth))
+	}
+
+	for i := uint64(0); i < n; i++ {
+		t := f.ReadType()
+		if f.DrainFast(t) < 0 {
+			f.drain(t, depth+1)
+		}
+	}
+}
@@ -1,11 +1,25 @@
 package ioutil
 
 import (
+	"errors"
 	"io"
-	"math"
 )
 
-// ByteSeeker is an implementation for an in-memory io.WriteSeeker and io.ReadSeeker
+// minReadFrom is the minimum amount of free capacity, which ReadFrom provides to the reader.
+const minReadFrom = 512
+
+// maxBufferSize is the largest buffer, which the runtime can allocate at all. On 64 bit platforms, allocations
+// are limited to the 48 bit address space, so a larger buffer would panic instead of failing with an error.
+const maxBufferSize = MaxInt >> (UintSize / 64 * 15)
+
+var (
+	errNegativeOffset = errors.New("negative offset")
+	errOffsetTooLarge = errors.New("offset too large")
+)
+
+// ByteSeeker is an implementation for an in-memory io.WriteSeeker and io.ReadSeeker. It also implements
+// io.ReaderAt, io.WriterAt, io.ReaderFrom, io.WriterTo and Truncate with the same semantics as an os.File,
+// so that it can stand in for a real file. Unlike an os.File, seeking beyond the end already enlarges the buffer.
 type ByteSeeker struct {
 	buf []byte
 	pos int
@@ -13,26 +27,156 @@ type ByteSeeker struct {
 
 // Read returns EOF if no bytes can be read anymore.
 func (b *ByteSeeker) Read(p []byte) (n int, err error) {
-	if b.pos == len(b.buf)-1 && len(p) > 0 {
+	if b.pos >= len(b.buf) {
+		if len(p) == 0 {
+			return 0, nil
+		}
+
+		return 0, io.EOF
+	}
+
+	n = copy(p, b.buf[b.pos:])
+	b.pos += n
+
+	return n, nil
+}
+
+// ReadAt reads len(p) bytes at the given offset, without changing the position. Like an os.File, it returns
+// io.EOF if less than len(p) bytes are available.
+func (b *ByteSeeker) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+
+	if off >= int64(len(b.buf)) {
+		if len(p) == 0 {
+			return 0, nil
+		}
+
 		return 0, io.EOF
 	}
 
-	var atMost int
+	n = copy(p, b.buf[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// WriteAt writes p at the given offset, without changing the position. Writing beyond the end enlarges the
+// buffer and fills the gap with zeros.
+func (b *ByteSeeker) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+
+	if off > int64(MaxInt-len(p)) {
+		return 0, errOffsetTooLarge
+	}
 
-	if b.pos+len(p) > len(b.buf) {
-		atMost = len(b.buf) - b.pos
-	} else {
-		atMost = len(p)
+	if err := b.ensureBuffer(int(off) + len(p)); err != nil {
+		return 0, err
 	}
 
-	copy(p[:atMost], b.buf[b.pos:b.pos+atMost])
+	copy(b.buf[off:], p)
+
+	return len(p), nil
+}
+
+// ReadFrom writes all bytes from r at the current position until io.EOF. It returns the amount of bytes read
+// and any other error of r.
+func (b *ByteSeeker) ReadFrom(r io.Reader) (n int64, err error) {
+	var tmp []byte
+
+	for {
+		var dst []byte
+
+		if b.pos != len(b.buf) {
+			// never pass existing bytes to r, which may use all of dst as scratch space, and only fill a gap
+			// after a truncate below the position, if there is actually something to write
+			if tmp == nil {
+				tmp = make([]byte, minReadFrom)
+			}
 
-	return atMost, nil
+			dst = tmp
+		} else {
+			if cap(b.buf)-len(b.buf) < minReadFrom {
+				if err := b.ensureCapacity(len(b.buf) + minReadFrom); err != nil {
+					return n, err
+				}
+			}
+
+			dst = b.buf[len(b.buf):cap(b.buf)]
+		}
+
+		m, rerr := r.Read(dst)
+		if m > 0 {
+			if b.pos != len(b.buf) {
+				if _, err := b.Write(dst[:m]); err != nil {
+					return n, err
+				}
+			} else {
+				b.buf = b.buf[:len(b.buf)+m]
+				b.pos += m
+			}
+
+			n += int64(m)
+		}
+
+		if rerr == io.EOF {
+			return n, nil
+		}
+
+		if rerr != nil {
+			return n, rerr
+		}
+	}
 }
 
+// WriteTo writes all bytes from the current position to the end into w and advances the position accordingly.
+func (b *ByteSeeker) WriteTo(w io.Writer) (n int64, err error) {
+	if b.pos >= len(b.buf) {
+		return 0, nil
+	}
+
+	remaining := b.buf[b.pos:]
+	m, err := w.Write(remaining)
+	b.pos += m
+
+	if err == nil && m < len(remaining) {
+		err = io.ErrShortWrite
+	}
+
+	return int64(m), err
+}
+
+// Truncate changes the size of the buffer, without changing the position. Enlarging fills the buffer with
+// zeros.
+func (b *ByteSeeker) Truncate(size int64) error {
+	if size < 0 {
+		return errNegativeOffset
+	}
+
+	if size <= int64(len(b.buf)) {
+		b.buf = b.buf[:size]
+		return nil
+	}
+
+	if size > maxBufferSize {
+		return errOffsetTooLarge
+	}
+
+	return b.ensureBuffer(int(size))
+}
+
+// Write writes p at the current position and enlarges the buffer, if required.
 func (b *ByteSeeker) Write(p []byte) (n int, err error) {
 	size := b.pos + len(p)
-	b.ensureBuffer(size)
+	if err := b.ensureBuffer(size); err != nil {
+		return 0, err
+	}
+
 	copy(b.buf[b.pos:], p)
 	b.pos += len(p)
 
@@ -41,18 +185,48 @@ func (b *ByteSeeker) Write(p []byte) (n int, err error) {
 
 // ensureBuffer ensures the required size. New capacity either doubles or uses the exact size, whatever is larger.
 // This will result in a nice adaptive behavior, where an initial write buffers
-// The exact size and does not cause any unused over provisioning
-func (b *ByteSeeker) ensureBuffer(size int) {
+// The exact size and does not cause any unused over provisioning. Enlarged bytes are always zero, even if they
+// have been used before a Truncate. Sizes, which cannot be allocated, are rejected with an error.
+func (b *ByteSeeker) ensureBuffer(size int) error {
+	if err := b.ensureCapacity(size); err != nil {
+		return err
+	}
+
+	if size > len(b.buf) {
+		oldLen := len(b.buf)
+		b.buf = b.buf[:size]
+
+		gap := b.buf[oldLen:]
+		for i := range gap {
+			gap[i] = 0
+		}
+	}
+
+	return nil
+}
+
+// ensureCapacity grows the capacity without changing the length.
+func (b *ByteSeeker) ensureCapacity(size int) error {
+	if size > maxBufferSize {
+		return errOffsetTooLarge
+	}
+
 	if size > cap(b.buf) {
-		newCap := int(math.Max(float64(size), float64(len(b.buf))))
+		newCap := 2 * cap(b.buf)
+		if size > newCap {
+			newCap = size
+		}
+
+		if newCap > maxBufferSize {
+			newCap = maxBufferSize
+		}
+
 		tmp := make([]byte, len(b.buf), newCap)
 		copy(tmp, b.buf)
 		b.buf = tmp
 	}
 
-	if size > len(b.buf) {
-		b.buf = b.buf[:size]
-	}
+	return nil
 }
 
 // Seek returns EOF if seeking before the beginning and enlarges the buffer, if required, seeks and allocates
@@ -74,7 +248,10 @@ func (b *ByteSeeker) Seek(offset int64, whence int) (int64, error) {
 		return 0, io.EOF
 	}
 
-	b.ensureBuffer(newPos)
+	if err := b.ensureBuffer(newPos); err != nil {
+		return int64(b.pos), err
+	}
+
 	b.pos = newPos
 
 	return int64(b.pos), nil
@@ -0,0 +1,233 @@
+package ioutil
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+	"testing/iotest"
+)
+
+// file is the common API of ByteSeeker and os.File, which is verified by the conformance test.
+type file interface {
+	io.ReadWriteSeeker
+	io.ReaderAt
+	io.WriterAt
+	Truncate(size int64) error
+}
+
+// fileOp performs a random operation and returns a comparable result.
+type fileOp func(f file, rnd *rand.Rand) []interface{}
+
+func fileOps() []fileOp {
+	data := func(rnd *rand.Rand) []byte {
+		b := make([]byte, rnd.Intn(2000))
+		rnd.Read(b)
+
+		return b
+	}
+
+	return []fileOp{
+		func(f file, rnd *rand.Rand) []interface{} {
+			n, err := f.Write(data(rnd))
+			return []interface{}{"Write", n, err}
+		},
+		func(f file, rnd *rand.Rand) []interface{} {
+			buf := make([]byte, rnd.Intn(1000))
+			n, err := f.Read(buf)
+
+			return []interface{}{"Read", n, err, buf[:n]}
+		},
+		func(f file, rnd *rand.Rand) []interface{} {
+			n, err := f.WriteAt(data(rnd), rnd.Int63n(5000))
+			return []interface{}{"WriteAt", n, err}
+		},
+		func(f file, rnd *rand.Rand) []interface{} {
+			buf := make([]byte, rnd.Intn(1000))
+			n, err := f.ReadAt(buf, rnd.Int63n(5000))
+
+			return []interface{}{"ReadAt", n, err, buf[:n]}
+		},
+		// a ByteSeeker enlarges the buffer when seeking beyond the end, so the seeks stay within the file
+		func(f file, rnd *rand.Rand) []interface{} {
+			end, _ := f.Seek(0, io.SeekEnd)
+			pos, err := f.Seek(rnd.Int63n(end+1), io.SeekStart)
+
+			return []interface{}{"SeekStart", pos, err}
+		},
+		func(f file, rnd *rand.Rand) []interface{} {
+			end, _ := f.Seek(0, io.SeekEnd)
+			pos, err := f.Seek(-rnd.Int63n(end+1), io.SeekCurrent)
+
+			return []interface{}{"SeekCurrent", pos, err}
+		},
+		func(f file, rnd *rand.Rand) []interface{} {
+			pos, err := f.Seek(-rnd.Int63n(100), io.SeekEnd)
+			if err != nil {
+				// ignore different errors for seeking before the start
+				pos, err = f.Seek(0, io.SeekStart)
+			}
+
+			return []interface{}{"SeekEnd", pos, err}
+		},
+		func(f file, rnd *rand.Rand) []interface{} {
+			return []interface{}{"Truncate", f.Truncate(rnd.Int63n(5000))}
+		},
+		func(f file, rnd *rand.Rand) []interface{} {
+			n, err := io.Copy(f, iotest.HalfReader(bytes.NewReader(data(rnd))))
+			return []interface{}{"ReadFrom", n, err}
+		},
+		func(f file, rnd *rand.Rand) []interface{} {
+			buf := &bytes.Buffer{}
+			n, err := io.Copy(buf, f)
+
+			return []interface{}{"WriteTo", n, err, buf.Bytes()}
+		},
+	}
+}
+
+func TestByteSeeker_Conformance(t *testing.T) {
+	testFileConformance(t, &ByteSeeker{})
+}
+
+// testFileConformance applies the same random operations to an os.File and the given seeker and compares the
+// results and the content after each operation.
+func testFileConformance(t *testing.T, seeker interface {
+	file
+	Bytes() []byte
+}) {
+	osFile, err := ioutil.TempFile("", "byteseeker")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(osFile.Name())
+	defer osFile.Close()
+
+	ops := fileOps()
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		op := rnd.Intn(len(ops))
+		seed := rnd.Int63()
+
+		expected := ops[op](osFile, rand.New(rand.NewSource(seed)))
+		actual := ops[op](seeker, rand.New(rand.NewSource(seed)))
+
+		if len(expected) != len(actual) {
+			t.Fatalf("%d: expected %v but got %v", i, expected, actual)
+		}
+
+		for j := range expected {
+			if eb, ok := expected[j].([]byte); ok {
+				if !bytes.Equal(eb, actual[j].([]byte)) {
+					t.Fatalf("%d: %v: expected %x but got %x", i, expected[0], eb, actual[j])
+				}
+
+				continue
+			}
+
+			// errors are only compared by their kind, because the os errors are wrapped
+			if err, ok := expected[j].(error); ok && err != io.EOF {
+				expected[j] = "error"
+			}
+
+			if err, ok := actual[j].(error); ok && err != io.EOF {
+				actual[j] = "error"
+			}
+
+			if expected[j] != actual[j] {
+				t.Fatalf("%d: expected %v but got %v", i, expected, actual)
+			}
+		}
+
+		content, err := ioutil.ReadFile(osFile.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(content, seeker.Bytes()) {
+			t.Fatalf("%d: %v: content differs", i, expected[0])
+		}
+	}
+}
+
+func TestByteSeeker_WriteAt(t *testing.T) {
+	seeker := &ByteSeeker{}
+	if n, err := seeker.WriteAt([]byte{1}, MaxInt64); n != 0 || err == nil {
+		t.Fatalf("expected an error but got %d", n)
+	}
+
+	for _, off := range []int64{1 << 62, MaxInt64 - 1} {
+		if n, err := seeker.WriteAt([]byte{1}, off); n != 0 || err == nil {
+			t.Fatalf("expected an error but got %d", n)
+		}
+
+		if err := seeker.Truncate(off); err == nil {
+			t.Fatal("expected an error")
+		}
+
+		if _, err := seeker.Seek(off, io.SeekStart); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+
+	if err := seeker.Truncate(MaxInt64); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if len(seeker.Bytes()) != 0 || seeker.Pos() != 0 {
+		t.Fatalf("expected an unchanged buffer")
+	}
+
+	// seeking beyond the end enlarges the buffer
+	if pos, err := seeker.Seek(10, io.SeekStart); pos != 10 || err != nil {
+		t.Fatal(pos, err)
+	}
+
+	if len(seeker.Bytes()) != 10 {
+		t.Fatalf("expected 10 bytes but got %d", len(seeker.Bytes()))
+	}
+}
+
+func TestByteSeeker_Zip(t *testing.T) {
+	seeker := &ByteSeeker{}
+	w := zip.NewWriter(seeker)
+
+	fw, err := w.Create("hello.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fw.Write([]byte("hello world")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(seeker, int64(len(seeker.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fr, err := r.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer fr.Close()
+
+	content, err := ioutil.ReadAll(fr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(content) != "hello world" {
+		t.Fatalf("expected hello world but got %q", content)
+	}
+}
@@ -0,0 +1,830 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// CBORType is the kind of a single CBOR data item (RFC 8949), as returned by CBORDecoder.Next.
+type CBORType uint8
+
+const (
+	CBORUint      CBORType = 1  // CBORUint is an unsigned integer with an uint64 value
+	CBORNegInt    CBORType = 2  // CBORNegInt is a negative integer with an uint64 value n, representing -1-n
+	CBORBytes     CBORType = 3  // CBORBytes is a byte string with a []byte value
+	CBORText      CBORType = 4  // CBORText is an UTF-8 text string with a string value
+	CBORArray     CBORType = 5  // CBORArray is followed by the amount of elements given by its int value
+	CBORMap       CBORType = 6  // CBORMap is followed by the amount of key and value pairs given by its int value
+	CBORTag       CBORType = 7  // CBORTag is followed by the tagged item and has the tag number as uint64 value
+	CBORSimple    CBORType = 8  // CBORSimple is an unassigned simple value with an uint8 value
+	CBORBool      CBORType = 9  // CBORBool is true or false with a bool value
+	CBORNull      CBORType = 10 // CBORNull is null with a nil value
+	CBORUndefined CBORType = 11 // CBORUndefined is undefined with a nil value
+	CBORFloat16   CBORType = 12 // CBORFloat16 is a half-precision float with a float32 value
+	CBORFloat32   CBORType = 13 // CBORFloat32 is a single-precision float with a float32 value
+	CBORFloat64   CBORType = 14 // CBORFloat64 is a double-precision float with a float64 value
+	CBORBreak     CBORType = 15 // CBORBreak ends an indefinite length item and has a nil value
+)
+
+// CBORIndefinite is the int value of a byte string, text string, array or map with indefinite length. The
+// chunks, elements or entries follow until a CBORBreak. The chunks of a string are definite strings of the
+// same type.
+const CBORIndefinite = -1
+
+// maxCBORDepth limits the nesting of arrays, maps and tags.
+const maxCBORDepth = 1000
+
+// the major types of the initial byte
+const (
+	cborMajorUint   = 0
+	cborMajorNegInt = 1
+	cborMajorBytes  = 2
+	cborMajorText   = 3
+	cborMajorArray  = 4
+	cborMajorMap    = 5
+	cborMajorTag    = 6
+	cborMajorSimple = 7
+)
+
+// String returns the name of the type.
+func (c CBORType) String() string {
+	switch c {
+	case CBORUint:
+		return "uint"
+	case CBORNegInt:
+		return "negint"
+	case CBORBytes:
+		return "bytes"
+	case CBORText:
+		return "text"
+	case CBORArray:
+		return "array"
+	case CBORMap:
+		return "map"
+	case CBORTag:
+		return "tag"
+	case CBORSimple:
+		return "simple"
+	case CBORBool:
+		return "bool"
+	case CBORNull:
+		return "null"
+	case CBORUndefined:
+		return "undefined"
+	case CBORFloat16:
+		return "float16"
+	case CBORFloat32:
+		return "float32"
+	case CBORFloat64:
+		return "float64"
+	case CBORBreak:
+		return "break"
+	default:
+		return "unspecified " + strconv.Itoa(int(c))
+	}
+}
+
+// A CBOREncoder writes CBOR data items using an Encoder. Heads always use the shortest form, but the encoder
+// does not buffer anything, so the caller is responsible for the amount and order of elements and entries.
+// Use DeterministicCBOR to get the core deterministic encoding. As soon as any error occurred, any call is a
+// no-op and will result in the same error state.
+type CBOREncoder struct {
+	encoder *Encoder
+}
+
+// NewCBOREncoder creates a new CBOREncoder, which writes into w.
+func NewCBOREncoder(w io.Writer) *CBOREncoder {
+	return &CBOREncoder{encoder: NewEncoder(w, true)}
+}
+
+// writeHead writes the initial byte and the argument in its shortest form.
+func (c *CBOREncoder) writeHead(op string, major byte, arg uint64) {
+	e := c.encoder
+
+	switch {
+	case arg < 24:
+		e.write(op, nil, []byte{major<<5 | byte(arg)})
+	case arg <= uint64(MaxUint8):
+		e.write(op, nil, []byte{major<<5 | 24, byte(arg)})
+	case arg <= uint64(MaxUint16):
+		e.write(op, nil, []byte{major<<5 | 25})
+		e.writeUint16(op, BigEndian, uint16(arg))
+	case arg <= uint64(MaxUint32):
+		e.write(op, nil, []byte{major<<5 | 26})
+		e.writeUint32(op, BigEndian, uint32(arg))
+	default:
+		e.write(op, nil, []byte{major<<5 | 27})
+		e.writeUint64(op, BigEndian, arg)
+	}
+}
+
+// WriteUint writes an unsigned integer.
+func (c *CBOREncoder) WriteUint(v uint64) {
+	c.writeHead("WriteUint", cborMajorUint, v)
+}
+
+// WriteInt writes a signed integer either as unsigned or as negative integer.
+func (c *CBOREncoder) WriteInt(v int64) {
+	if v >= 0 {
+		c.writeHead("WriteInt", cborMajorUint, uint64(v))
+		return
+	}
+
+	c.writeHead("WriteInt", cborMajorNegInt, ^uint64(v))
+}
+
+// WriteNegInt writes the negative integer -1-n, which covers the entire range down to -2^64.
+func (c *CBOREncoder) WriteNegInt(n uint64) {
+	c.writeHead("WriteNegInt", cborMajorNegInt, n)
+}
+
+// WriteBytes writes a definite length byte string.
+func (c *CBOREncoder) WriteBytes(v []byte) {
+	c.writeHead("WriteBytes", cborMajorBytes, uint64(len(v)))
+	c.encoder.write("WriteBytes", nil, v)
+}
+
+// WriteString writes a definite length text string. The string is not validated and must be valid UTF-8.
+func (c *CBOREncoder) WriteString(v string) {
+	c.writeHead("WriteString", cborMajorText, uint64(len(v)))
+	c.encoder.write("WriteString", nil, []byte(v))
+}
+
+// WriteArray writes the head of an array with n elements, which must follow.
+func (c *CBOREncoder) WriteArray(n int) {
+	c.writeHead("WriteArray", cborMajorArray, uint64(n))
+}
+
+// WriteMap writes the head of a map with n entries, whose keys and values must follow alternately.
+func (c *CBOREncoder) WriteMap(n int) {
+	c.writeHead("WriteMap", cborMajorMap, uint64(n))
+}
+
+// WriteTag writes a tag number, which must be followed by the tagged item, e.g. 1 for epoch-based date/time.
+func (c *CBOREncoder) WriteTag(tag uint64) {
+	c.writeHead("WriteTag", cborMajorTag, tag)
+}
+
+// BeginBytes starts an indefinite length byte string, whose chunks are written by WriteBytes and which is
+// ended by WriteBreak.
+func (c *CBOREncoder) BeginBytes() {
+	c.encoder.write("BeginBytes", nil, []byte{cborMajorBytes<<5 | 31})
+}
+
+// BeginString starts an indefinite length text string, whose chunks are written by WriteString and which is
+// ended by WriteBreak.
+func (c *CBOREncoder) BeginString() {
+	c.encoder.write("BeginString", nil, []byte{cborMajorText<<5 | 31})
+}
+
+// BeginArray starts an indefinite length array, which is ended by WriteBreak after the last element.
+func (c *CBOREncoder) BeginArray() {
+	c.encoder.write("BeginArray", nil, []byte{cborMajorArray<<5 | 31})
+}
+
+// BeginMap starts an indefinite length map, which is ended by WriteBreak after the last entry.
+func (c *CBOREncoder) BeginMap() {
+	c.encoder.write("BeginMap", nil, []byte{cborMajorMap<<5 | 31})
+}
+
+// WriteBreak ends an indefinite length item.
+func (c *CBOREncoder) WriteBreak() {
+	c.encoder.write("WriteBreak", nil, []byte{cborMajorSimple<<5 | 31})
+}
+
+// WriteBool writes true or false.
+func (c *CBOREncoder) WriteBool(v bool) {
+	if v {
+		c.encoder.write("WriteBool", nil, []byte{cborMajorSimple<<5 | 21})
+		return
+	}
+
+	c.encoder.write("WriteBool", nil, []byte{cborMajorSimple<<5 | 20})
+}
+
+// WriteNull writes null.
+func (c *CBOREncoder) WriteNull() {
+	c.encoder.write("WriteNull", nil, []byte{cborMajorSimple<<5 | 22})
+}
+
+// WriteUndefined writes undefined.
+func (c *CBOREncoder) WriteUndefined() {
+	c.encoder.write("WriteUndefined", nil, []byte{cborMajorSimple<<5 | 23})
+}
+
+// WriteSimple writes an unassigned simple value. The values 20 to 31 are reserved or have a dedicated method and
+// cause an error.
+func (c *CBOREncoder) WriteSimple(v uint8) {
+	if v >= 20 && v < 32 {
+		c.encoder.noteErr("WriteSimple", nil, c.encoder.pos, fmt.Errorf("invalid simple value %d", v))
+		return
+	}
+
+	c.writeHead("WriteSimple", cborMajorSimple, uint64(v))
+}
+
+// WriteFloat16 writes a half-precision float, which is rounded to the nearest representable value.
+func (c *CBOREncoder) WriteFloat16(v float32) {
+	c.encoder.write("WriteFloat16", nil, []byte{cborMajorSimple<<5 | 25})
+	c.encoder.writeUint16("WriteFloat16", BigEndian, Float32ToFloat16(v))
+}
+
+// WriteFloat32 writes a single-precision float.
+func (c *CBOREncoder) WriteFloat32(v float32) {
+	c.encoder.write("WriteFloat32", nil, []byte{cborMajorSimple<<5 | 26})
+	c.encoder.writeUint32("WriteFloat32", BigEndian, math.Float32bits(v))
+}
+
+// WriteFloat64 writes a double-precision float.
+func (c *CBOREncoder) WriteFloat64(v float64) {
+	c.encoder.write("WriteFloat64", nil, []byte{cborMajorSimple<<5 | 27})
+	c.encoder.writeUint64("WriteFloat64", BigEndian, math.Float64bits(v))
+}
+
+// WriteFloat writes the shortest float, which represents v exactly. Any NaN is written as the canonical
+// half-precision quiet NaN 0x7e00.
+func (c *CBOREncoder) WriteFloat(v float64) {
+	if math.IsNaN(v) {
+		c.encoder.write("WriteFloat", nil, []byte{cborMajorSimple<<5 | 25, 0x7e, 0x00})
+		return
+	}
+
+	f32 := float32(v)
+	if float64(f32) != v {
+		c.WriteFloat64(v)
+		return
+	}
+
+	if h := Float32ToFloat16(f32); Float16ToFloat32(h) == f32 {
+		c.encoder.write("WriteFloat", nil, []byte{cborMajorSimple<<5 | 25})
+		c.encoder.writeUint16("WriteFloat", BigEndian, h)
+
+		return
+	}
+
+	c.WriteFloat32(f32)
+}
+
+// WriteRaw writes an already encoded data item as is, e.g. to copy unknown items.
+func (c *CBOREncoder) WriteRaw(item []byte) {
+	c.encoder.write("WriteRaw", nil, item)
+}
+
+// Position returns the amount of bytes, which have been written so far.
+func (c *CBOREncoder) Position() int64 {
+	return c.encoder.Position()
+}
+
+// Error returns the first occurred error.
+func (c *CBOREncoder) Error() error {
+	return c.encoder.Error()
+}
+
+// cborFrame is a pending array, map, tag or indefinite string.
+type cborFrame struct {
+	typ       CBORType
+	remaining int64 // remaining is the amount of missing items or CBORIndefinite
+	odd       bool  // odd is true, if an indefinite map has read a key but not yet its value
+}
+
+// A CBORDecoder reads CBOR data items (RFC 8949) using a Decoder. It tracks the nesting of arrays, maps, tags
+// and indefinite strings, so that truncated or not well-formed input is always detected. As soon as any error
+// occurred, any call is a no-op and will result in the same error state.
+type CBORDecoder struct {
+	decoder *Decoder
+	stack   []cborFrame
+}
+
+// NewCBORDecoder creates a new CBORDecoder, which reads from r.
+func NewCBORDecoder(r io.Reader) *CBORDecoder {
+	return &CBORDecoder{decoder: NewDecoder(r, true)}
+}
+
+// SetLimits configures the limits of the underlying Decoder, to protect against hostile length prefixes.
+func (c *CBORDecoder) SetLimits(limits Limits) {
+	c.decoder.SetLimits(limits)
+}
+
+// Next reads the next data item, whose Value depends on the CBORType. For arrays, maps and tags, only the head
+// is read and the nested items are returned by the following calls. It returns io.EOF, if the input ends
+// exactly after a complete top level item, so that a CBOR sequence (RFC 8742) can be read as well.
+func (c *CBORDecoder) Next() (CBORType, Value, error) {
+	typ, v := c.next("ReadCBOR", true)
+	if c.decoder.Error() != nil {
+		return 0, nil, c.decoder.Error()
+	}
+
+	return typ, v, nil
+}
+
+// Skip reads and discards the next data item, including all nested items. The payload of strings is neither
+// allocated nor validated.
+func (c *CBORDecoder) Skip() {
+	depth := len(c.stack)
+
+	for {
+		c.next("SkipCBOR", false)
+
+		if c.decoder.Error() != nil || len(c.stack) <= depth {
+			return
+		}
+	}
+}
+
+// Depth returns the amount of arrays, maps, tags and indefinite strings, which are not completed yet.
+func (c *CBORDecoder) Depth() int {
+	return len(c.stack)
+}
+
+// next reads the next item and updates the nesting. Strings are only allocated if payload is true.
+//
+//nolint:gocyclo
+func (c *CBORDecoder) next(op string, payload bool) (CBORType, Value) {
+	d := c.decoder
+	if d.quickFail() {
+		return 0, nil
+	}
+
+	offset := d.pos
+	typ, arg, indefinite := c.readHead(op)
+
+	if d.Error() != nil {
+		if len(c.stack) == 0 && d.pos == offset && errors.Is(d.Error(), io.EOF) {
+			d.firstErr = io.EOF
+		}
+
+		d.unexpectedEOF()
+
+		return 0, nil
+	}
+
+	var top *cborFrame
+	if len(c.stack) > 0 {
+		top = &c.stack[len(c.stack)-1]
+	}
+
+	// only definite strings of the same type are allowed as chunks of an indefinite string
+	if top != nil && (top.typ == CBORBytes || top.typ == CBORText) && typ != CBORBreak &&
+		(typ != top.typ || indefinite) {
+		d.noteErr(op, nil, offset, fmt.Errorf("invalid chunk of an indefinite %s: %s", top.typ, typ))
+		return 0, nil
+	}
+
+	if typ == CBORBreak {
+		if top == nil || top.remaining != CBORIndefinite {
+			d.noteErr(op, nil, offset, fmt.Errorf("unexpected break"))
+			return 0, nil
+		}
+
+		if top.odd {
+			d.noteErr(op, nil, offset, fmt.Errorf("break after a map key without value"))
+			return 0, nil
+		}
+
+		c.stack = c.stack[:len(c.stack)-1]
+		c.popCompleted()
+
+		return typ, nil
+	}
+
+	if top != nil && top.remaining > 0 {
+		top.remaining--
+	}
+
+	if top != nil && top.remaining == CBORIndefinite && top.typ == CBORMap {
+		top.odd = !top.odd
+	}
+
+	var v Value
+
+	switch typ {
+	case CBORBytes, CBORText:
+		if indefinite {
+			v = CBORIndefinite
+			break
+		}
+
+		v = c.readPayload(op, typ, offset, arg, payload)
+	case CBORArray, CBORMap:
+		if indefinite {
+			v = CBORIndefinite
+			break
+		}
+
+		if arg > MaxInt/2 {
+			d.noteErr(op, nil, offset, IntegerOverflow{Val: arg, Max: MaxInt / 2})
+			return 0, nil
+		}
+
+		v = int(arg)
+	case CBORUint, CBORNegInt, CBORTag:
+		v = arg
+	case CBORSimple:
+		v = uint8(arg)
+	case CBORBool:
+		v = arg == 21
+	case CBORFloat16:
+		v = Float16ToFloat32(uint16(arg))
+	case CBORFloat32:
+		v = math.Float32frombits(uint32(arg))
+	case CBORFloat64:
+		v = math.Float64frombits(arg)
+	}
+
+	if d.Error() != nil {
+		return 0, nil
+	}
+
+	switch {
+	case indefinite:
+		c.push(op, offset, cborFrame{typ: typ, remaining: CBORIndefinite})
+	case typ == CBORArray && arg > 0:
+		c.push(op, offset, cborFrame{typ: typ, remaining: int64(arg)})
+	case typ == CBORMap && arg > 0:
+		c.push(op, offset, cborFrame{typ: typ, remaining: 2 * int64(arg)})
+	case typ == CBORTag:
+		c.push(op, offset, cborFrame{typ: typ, remaining: 1})
+	default:
+		c.popCompleted()
+	}
+
+	return typ, v
+}
+
+func (c *CBORDecoder) push(op string, offset int64, frame cborFrame) {
+	if len(c.stack) >= maxCBORDepth {
+		c.decoder.noteErr(op, nil, offset, fmt.Errorf("items nested deeper than %d", maxCBORDepth))
+		return
+	}
+
+	c.stack = append(c.stack, frame)
+}
+
+// popCompleted removes all frames, whose last item has been read.
+func (c *CBORDecoder) popCompleted() {
+	for len(c.stack) > 0 && c.stack[len(c.stack)-1].remaining == 0 {
+		c.stack = c.stack[:len(c.stack)-1]
+	}
+}
+
+// readHead reads the initial byte and the argument. For floats, the argument contains the bits.
+func (c *CBORDecoder) readHead(op string) (CBORType, uint64, bool) {
+	d := c.decoder
+	offset := d.pos
+	initial := d.readUint8(op)
+	major, info := initial>>5, initial&31
+
+	var arg uint64
+
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24:
+		arg = uint64(d.readUint8(op))
+	case info == 25:
+		arg = uint64(d.readUint16(op, BigEndian))
+	case info == 26:
+		arg = uint64(d.readUint32(op, BigEndian))
+	case info == 27:
+		arg = d.readUint64(op, BigEndian)
+	case info == 31 && major >= cborMajorBytes && major <= cborMajorMap:
+		return CBORType(major-cborMajorBytes) + CBORBytes, 0, true
+	case info == 31 && major == cborMajorSimple:
+		return CBORBreak, 0, false
+	default:
+		d.noteErr(op, nil, offset, fmt.Errorf("invalid initial byte %#x", initial))
+		return 0, 0, false
+	}
+
+	if major != cborMajorSimple {
+		return CBORType(major) + CBORUint, arg, false
+	}
+
+	switch info {
+	case 20, 21:
+		return CBORBool, arg, false
+	case 22:
+		return CBORNull, 0, false
+	case 23:
+		return CBORUndefined, 0, false
+	case 24:
+		if arg < 32 {
+			d.noteErr(op, nil, offset, fmt.Errorf("invalid simple value %d", arg))
+		}
+
+		return CBORSimple, arg, false
+	case 25:
+		return CBORFloat16, arg, false
+	case 26:
+		return CBORFloat32, arg, false
+	case 27:
+		return CBORFloat64, arg, false
+	default:
+		return CBORSimple, arg, false
+	}
+}
+
+// readPayload reads the content of a definite string or just discards it.
+func (c *CBORDecoder) readPayload(op string, typ CBORType, offset int64, n uint64, payload bool) Value {
+	d := c.decoder
+
+	if n > MaxInt {
+		d.noteErr(op, nil, offset, IntegerOverflow{Val: n, Max: MaxInt})
+		return nil
+	}
+
+	if !payload {
+		start := d.pos
+		m, err := io.CopyN(ioutil.Discard, d.in, int64(n))
+		d.pos += m
+
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		d.noteErr(op, nil, start, err)
+
+		return nil
+	}
+
+	if !d.allocate(op, nil, offset, int(n), 0) {
+		return nil
+	}
+
+	buf := make([]byte, n)
+	d.readFull(op, nil, buf)
+	d.unexpectedEOF()
+
+	if typ == CBORBytes {
+		return buf
+	}
+
+	if !utf8.Valid(buf) {
+		d.noteErr(op, nil, offset, fmt.Errorf("invalid UTF-8 text"))
+		return nil
+	}
+
+	return string(buf)
+}
+
+// Position returns the amount of bytes, which have been read so far.
+func (c *CBORDecoder) Position() int64 {
+	return c.decoder.Position()
+}
+
+// Error returns the first occurred error.
+func (c *CBORDecoder) Error() error {
+	return c.decoder.Error()
+}
+
+// DeterministicCBOR re-encodes a CBOR sequence using the core deterministic encoding requirements of RFC 8949
+// section 4.2.1: all heads and floats use their shortest form, indefinite lengths become definite and the
+// entries of each map are sorted by the bytewise order of their encoded keys. Duplicate keys are an error.
+func DeterministicCBOR(data []byte) ([]byte, error) {
+	dec := NewCBORDecoder(bytes.NewReader(data))
+	buf := &bytes.Buffer{}
+	enc := NewCBOREncoder(buf)
+
+	for {
+		typ, v, err := dec.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		deterministicCBOR(dec, enc, typ, v)
+
+		if dec.Error() != nil {
+			return nil, dec.Error()
+		}
+	}
+
+	if enc.Error() != nil {
+		return nil, enc.Error()
+	}
+
+	return buf.Bytes(), nil
+}
+
+// deterministicCBOR writes the item, whose head has just been read, including all nested items.
+func deterministicCBOR(dec *CBORDecoder, enc *CBOREncoder, typ CBORType, v Value) {
+	switch typ {
+	case CBORUint:
+		enc.WriteUint(v.(uint64))
+	case CBORNegInt:
+		enc.WriteNegInt(v.(uint64))
+	case CBORBytes:
+		if v == CBORIndefinite {
+			v = joinCBORChunks(dec, typ)
+		}
+
+		if b, ok := v.([]byte); ok {
+			enc.WriteBytes(b)
+		}
+	case CBORText:
+		if v == CBORIndefinite {
+			v = joinCBORChunks(dec, typ)
+		}
+
+		if str, ok := v.(string); ok {
+			enc.WriteString(str)
+		}
+	case CBORArray:
+		var elems [][]byte
+
+		forEachCBORChild(dec, v.(int), func(typ CBORType, v Value) {
+			elems = append(elems, deterministicCBORItem(dec, typ, v))
+		})
+
+		enc.WriteArray(len(elems))
+
+		for _, elem := range elems {
+			enc.WriteRaw(elem)
+		}
+	case CBORMap:
+		var entries [][2][]byte
+
+		offset := dec.Position()
+		n := v.(int)
+
+		if n != CBORIndefinite {
+			n *= 2
+		}
+
+		forEachCBORChild(dec, n, func(typ CBORType, v Value) {
+			item := deterministicCBORItem(dec, typ, v)
+			if len(entries) > 0 && entries[len(entries)-1][1] == nil {
+				entries[len(entries)-1][1] = item
+				return
+			}
+
+			entries = append(entries, [2][]byte{item})
+		})
+
+		sort.Slice(entries, func(i, j int) bool {
+			return bytes.Compare(entries[i][0], entries[j][0]) < 0
+		})
+
+		for i := 1; i < len(entries); i++ {
+			if bytes.Equal(entries[i-1][0], entries[i][0]) {
+				dec.decoder.noteErr("DeterministicCBOR", nil, offset, fmt.Errorf("duplicate map key %x", entries[i][0]))
+			}
+		}
+
+		enc.WriteMap(len(entries))
+
+		for _, entry := range entries {
+			enc.WriteRaw(entry[0])
+			enc.WriteRaw(entry[1])
+		}
+	case CBORTag:
+		enc.WriteTag(v.(uint64))
+
+		typ, v := dec.next("ReadCBOR", true)
+		deterministicCBOR(dec, enc, typ, v)
+	case CBORSimple:
+		enc.WriteSimple(v.(uint8))
+	case CBORBool:
+		enc.WriteBool(v.(bool))
+	case CBORNull:
+		enc.WriteNull()
+	case CBORUndefined:
+		enc.WriteUndefined()
+	case CBORFloat16, CBORFloat32:
+		enc.WriteFloat(float64(v.(float32)))
+	case CBORFloat64:
+		enc.WriteFloat(v.(float64))
+	}
+}
+
+// deterministicCBORItem returns the deterministic encoding of the item, whose head has just been read.
+func deterministicCBORItem(dec *CBORDecoder, typ CBORType, v Value) []byte {
+	buf := &bytes.Buffer{}
+	deterministicCBOR(dec, NewCBOREncoder(buf), typ, v)
+
+	return buf.Bytes()
+}
+
+// forEachCBORChild reads the n nested items of an array or map or all until the break, if n is CBORIndefinite.
+// The callback must read the nested items of each child.
+func forEachCBORChild(dec *CBORDecoder, n int, fn func(typ CBORType, v Value)) {
+	for i := 0; n == CBORIndefinite || i < n; i++ {
+		typ, v := dec.next("ReadCBOR", true)
+		if dec.Error() != nil || typ == CBORBreak {
+			return
+		}
+
+		fn(typ, v)
+	}
+}
+
+// joinCBORChunks concatenates the chunks of an indefinite string.
+func joinCBORChunks(dec *CBORDecoder, typ CBORType) Value {
+	var buf []byte
+
+	forEachCBORChild(dec, CBORIndefinite, func(_ CBORType, v Value) {
+		switch chunk := v.(type) {
+		case []byte:
+			buf = append(buf, chunk...)
+		case string:
+			buf = append(buf, chunk...)
+		}
+	})
+
+	if typ == CBORText {
+		return string(buf)
+	}
+
+	if buf == nil {
+		buf = []byte{}
+	}
+
+	return buf
+}
+
+// Float32ToFloat16 converts a float32 into the bits of an IEEE 754 half-precision float, rounding to the
+// nearest even value. Values out of range become infinite and a NaN keeps its sign and upper payload bits.
+func Float32ToFloat16(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+
+	if exp == 0xff {
+		if mant == 0 {
+			return sign | 0x7c00
+		}
+
+		return sign | 0x7e00 | uint16(mant>>13)
+	}
+
+	e := exp - 127 + 15
+	if e >= 0x1f {
+		return sign | 0x7c00
+	}
+
+	var half, rem, halfway uint32
+
+	if e <= 0 {
+		// a subnormal half or zero
+		if e < -10 {
+			return sign
+		}
+
+		mant |= 0x800000
+		shift := uint(14 - e)
+		half, rem, halfway = mant>>shift, mant&(1<<shift-1), 1<<(shift-1)
+	} else {
+		half, rem, halfway = uint32(e)<<10|mant>>13, mant&0x1fff, 0x1000
+	}
+
+	// a carry into the exponent is fine and results in the next larger exponent or infinity
+	if rem > halfway || rem == halfway && half&1 == 1 {
+		half++
+	}
+
+	return sign | uint16(half)
+}
+
+// Float16ToFloat32 converts the bits of an IEEE 754 half-precision float into a float32, which is always exact.
+func Float16ToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+
+	switch exp {
+	case 0:
+		f := float32(mant) / (1 << 24)
+		return math.Float32frombits(math.Float32bits(f) | sign)
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	default:
+		return math.Float32frombits(sign | (exp+112)<<23 | mant<<13)
+	}
+}
@@ -0,0 +1,441 @@
+package ioutil
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+// the examples of RFC 8949 Appendix A
+func TestCBOREncoder_RFC(t *testing.T) {
+	tests := []struct {
+		write    func(e *CBOREncoder)
+		expected string
+	}{
+		{func(e *CBOREncoder) { e.WriteUint(0) }, "00"},
+		{func(e *CBOREncoder) { e.WriteUint(23) }, "17"},
+		{func(e *CBOREncoder) { e.WriteUint(24) }, "1818"},
+		{func(e *CBOREncoder) { e.WriteUint(1000) }, "1903e8"},
+		{func(e *CBOREncoder) { e.WriteUint(1000000) }, "1a000f4240"},
+		{func(e *CBOREncoder) { e.WriteUint(math.MaxUint64) }, "1bffffffffffffffff"},
+		{func(e *CBOREncoder) { e.WriteNegInt(math.MaxUint64) }, "3bffffffffffffffff"},
+		{func(e *CBOREncoder) { e.WriteInt(-1) }, "20"},
+		{func(e *CBOREncoder) { e.WriteInt(-1000) }, "3903e7"},
+		{func(e *CBOREncoder) { e.WriteFloat(0) }, "f90000"},
+		{func(e *CBOREncoder) { e.WriteFloat(math.Copysign(0, -1)) }, "f98000"},
+		{func(e *CBOREncoder) { e.WriteFloat(1.5) }, "f93e00"},
+		{func(e *CBOREncoder) { e.WriteFloat(65504) }, "f97bff"},
+		{func(e *CBOREncoder) { e.WriteFloat(100000) }, "fa47c35000"},
+		{func(e *CBOREncoder) { e.WriteFloat(3.4028234663852886e+38) }, "fa7f7fffff"},
+		{func(e *CBOREncoder) { e.WriteFloat(1.1) }, "fb3ff199999999999a"},
+		{func(e *CBOREncoder) { e.WriteFloat(5.960464477539063e-8) }, "f90001"},
+		{func(e *CBOREncoder) { e.WriteFloat(0.00006103515625) }, "f90400"},
+		{func(e *CBOREncoder) { e.WriteFloat(-4) }, "f9c400"},
+		{func(e *CBOREncoder) { e.WriteFloat(math.Inf(1)) }, "f97c00"},
+		{func(e *CBOREncoder) { e.WriteFloat(math.NaN()) }, "f97e00"},
+		{func(e *CBOREncoder) { e.WriteFloat(math.Inf(-1)) }, "f9fc00"},
+		{func(e *CBOREncoder) { e.WriteFloat32(float32(math.Inf(1))) }, "fa7f800000"},
+		{func(e *CBOREncoder) { e.WriteFloat64(math.Inf(-1)) }, "fbfff0000000000000"},
+		{func(e *CBOREncoder) { e.WriteBool(false) }, "f4"},
+		{func(e *CBOREncoder) { e.WriteBool(true) }, "f5"},
+		{func(e *CBOREncoder) { e.WriteNull() }, "f6"},
+		{func(e *CBOREncoder) { e.WriteUndefined() }, "f7"},
+		{func(e *CBOREncoder) { e.WriteSimple(16) }, "f0"},
+		{func(e *CBOREncoder) { e.WriteSimple(255) }, "f8ff"},
+		{func(e *CBOREncoder) {
+			e.WriteTag(0)
+			e.WriteString("2013-03-21T20:04:00Z")
+		}, "c074323031332d30332d32315432303a30343a30305a"},
+		{func(e *CBOREncoder) {
+			e.WriteTag(1)
+			e.WriteUint(1363896240)
+		}, "c11a514b67b0"},
+		{func(e *CBOREncoder) { e.WriteBytes([]byte{1, 2, 3, 4}) }, "4401020304"},
+		{func(e *CBOREncoder) { e.WriteString("ü") }, "62c3bc"},
+		{func(e *CBOREncoder) {
+			e.WriteArray(3)
+			e.WriteUint(1)
+			e.WriteArray(2)
+			e.WriteUint(2)
+			e.WriteUint(3)
+			e.WriteArray(2)
+			e.WriteUint(4)
+			e.WriteUint(5)
+		}, "8301820203820405"},
+		{func(e *CBOREncoder) {
+			e.WriteMap(2)
+			e.WriteString("a")
+			e.WriteUint(1)
+			e.WriteString("b")
+			e.WriteArray(2)
+			e.WriteUint(2)
+			e.WriteUint(3)
+		}, "a26161016162820203"},
+		{func(e *CBOREncoder) {
+			e.BeginBytes()
+			e.WriteBytes([]byte{1, 2})
+			e.WriteBytes([]byte{3, 4, 5})
+			e.WriteBreak()
+		}, "5f42010243030405ff"},
+		{func(e *CBOREncoder) {
+			e.BeginString()
+			e.WriteString("strea")
+			e.WriteString("ming")
+			e.WriteBreak()
+		}, "7f657374726561646d696e67ff"},
+		{func(e *CBOREncoder) {
+			e.BeginMap()
+			e.WriteString("a")
+			e.WriteUint(1)
+			e.WriteString("b")
+			e.BeginArray()
+			e.WriteUint(2)
+			e.WriteUint(3)
+			e.WriteBreak()
+			e.WriteBreak()
+		}, "bf61610161629f0203ffff"},
+	}
+
+	for i, tt := range tests {
+		buf := &bytes.Buffer{}
+		e := NewCBOREncoder(buf)
+		tt.write(e)
+
+		if e.Error() != nil {
+			t.Fatal(i, e.Error())
+		}
+
+		if got := hex.EncodeToString(buf.Bytes()); got != tt.expected {
+			t.Fatalf("%d: expected %s but got %s", i, tt.expected, got)
+		}
+	}
+
+	e := NewCBOREncoder(&bytes.Buffer{})
+	e.WriteSimple(24)
+
+	if e.Error() == nil {
+		t.Fatal("expected an invalid simple value")
+	}
+}
+
+func TestCBORDecoder_RFC(t *testing.T) {
+	type item struct {
+		typ CBORType
+		v   Value
+	}
+
+	tests := []struct {
+		data     string
+		expected []item
+	}{
+		{"1bffffffffffffffff", []item{{CBORUint, uint64(math.MaxUint64)}}},
+		{"3903e7", []item{{CBORNegInt, uint64(999)}}},
+		{"f93c00", []item{{CBORFloat16, float32(1)}}},
+		{"f90001", []item{{CBORFloat16, float32(5.960464477539063e-8)}}},
+		{"f9fc00", []item{{CBORFloat16, float32(math.Inf(-1))}}},
+		{"fa47c35000", []item{{CBORFloat32, float32(100000)}}},
+		{"fb7e37e43c8800759c", []item{{CBORFloat64, 1.0e+300}}},
+		{"f4f5f6f7f0f8ff", []item{{CBORBool, false}, {CBORBool, true}, {CBORNull, nil},
+			{CBORUndefined, nil}, {CBORSimple, uint8(16)}, {CBORSimple, uint8(255)}}},
+		{"d74401020304", []item{{CBORTag, uint64(23)}, {CBORBytes, []byte{1, 2, 3, 4}}}},
+		{"6449455446", []item{{CBORText, "IETF"}}},
+		{"5f42010243030405ff", []item{{CBORBytes, CBORIndefinite}, {CBORBytes, []byte{1, 2}},
+			{CBORBytes, []byte{3, 4, 5}}, {CBORBreak, nil}}},
+		{"a201020304", []item{{CBORMap, 2}, {CBORUint, uint64(1)}, {CBORUint, uint64(2)},
+			{CBORUint, uint64(3)}, {CBORUint, uint64(4)}}},
+		{"9f018202039f0405ffff", []item{{CBORArray, CBORIndefinite}, {CBORUint, uint64(1)}, {CBORArray, 2},
+			{CBORUint, uint64(2)}, {CBORUint, uint64(3)}, {CBORArray, CBORIndefinite}, {CBORUint, uint64(4)},
+			{CBORUint, uint64(5)}, {CBORBreak, nil}, {CBORBreak, nil}}},
+		{"80a0", []item{{CBORArray, 0}, {CBORMap, 0}}},
+	}
+
+	for i, tt := range tests {
+		dec := NewCBORDecoder(bytes.NewReader(mustHex(t, tt.data)))
+
+		for j, expected := range tt.expected {
+			typ, v, err := dec.Next()
+			if err != nil {
+				t.Fatal(i, j, err)
+			}
+
+			if typ != expected.typ || !reflect.DeepEqual(v, expected.v) {
+				t.Fatalf("%d.%d: expected %v %#v but got %v %#v", i, j, expected.typ, expected.v, typ, v)
+			}
+		}
+
+		if _, _, err := dec.Next(); err != io.EOF {
+			t.Fatalf("%d: expected EOF but got %v", i, err)
+		}
+	}
+}
+
+func TestCBORDecoder_Malformed(t *testing.T) {
+	tests := []struct {
+		data string
+		want error
+	}{
+		{"18", io.ErrUnexpectedEOF},                 // missing argument
+		{"1a0000", io.ErrUnexpectedEOF},             // truncated argument
+		{"4401", io.ErrUnexpectedEOF},               // truncated byte string
+		{"8201", io.ErrUnexpectedEOF},               // missing element
+		{"a101", io.ErrUnexpectedEOF},               // missing value
+		{"9f01", io.ErrUnexpectedEOF},               // missing break
+		{"c1", io.ErrUnexpectedEOF},                 // missing tagged item
+		{"1c", nil},                                 // reserved additional information
+		{"1f", nil},                                 // indefinite integer
+		{"ff", nil},                                 // break outside of an indefinite item
+		{"81ff", nil},                               // break inside of a definite array
+		{"bf01ff", nil},                             // break after a map key
+		{"bf8101ff", nil},                           // break after an array as map key
+		{"5f01ff", nil},                             // integer chunk
+		{"5f5f4101ffff", nil},                       // indefinite chunk
+		{"7f4101ff", nil},                           // byte chunk in a text string
+		{"62c328", nil},                             // invalid UTF-8
+		{"f818", nil},                               // two byte simple value below 32
+		{"5b7fffffffffffffff", nil},                 // hostile length
+		{"9b00000000ffffffff", io.ErrUnexpectedEOF}, // large array without elements
+	}
+
+	for i, tt := range tests {
+		for _, skip := range []bool{false, true} {
+			dec := NewCBORDecoder(bytes.NewReader(mustHex(t, tt.data)))
+			dec.SetLimits(Limits{MaxBlobSize: 1024})
+
+			for dec.Error() == nil {
+				if skip {
+					dec.Skip()
+				} else {
+					dec.Next()
+				}
+			}
+
+			var decErr *DecodeError
+			if !errors.As(dec.Error(), &decErr) {
+				// skipping discards payloads and does not validate them
+				if skip && tt.data == "62c328" && dec.Error() == io.EOF {
+					continue
+				}
+
+				t.Fatalf("%d: expected a DecodeError but got %v", i, dec.Error())
+			}
+
+			if tt.want != nil && !errors.Is(dec.Error(), tt.want) {
+				t.Fatalf("%d: expected %v but got %v", i, tt.want, dec.Error())
+			}
+		}
+	}
+
+	dec := NewCBORDecoder(bytes.NewReader(bytes.Repeat([]byte{0x81}, maxCBORDepth+1)))
+	dec.Skip()
+
+	if dec.Error() == nil || errors.Is(dec.Error(), io.ErrUnexpectedEOF) {
+		t.Fatalf("expected the nesting limit but got %v", dec.Error())
+	}
+}
+
+func TestCBORDecoder_Skip(t *testing.T) {
+	data := mustHex(t, "bf61610161629f0203ffff"+"c11a514b67b0"+"5f42010243030405ff"+"0a")
+	dec := NewCBORDecoder(bytes.NewReader(data))
+
+	for i := 0; i < 3; i++ {
+		dec.Skip()
+	}
+
+	if typ, v, err := dec.Next(); err != nil || typ != CBORUint || v != uint64(10) {
+		t.Fatal(typ, v, err)
+	}
+
+	dec.Skip()
+
+	if dec.Error() != io.EOF {
+		t.Fatalf("expected EOF but got %v", dec.Error())
+	}
+}
+
+func TestFloat16(t *testing.T) {
+	// every half-precision value except NaNs survives a round trip
+	for h := 0; h <= math.MaxUint16; h++ {
+		f := Float16ToFloat32(uint16(h))
+		if f != f {
+			if Float32ToFloat16(f)&0x7e00 != 0x7e00 {
+				t.Fatalf("%#x: expected a NaN but got %#x", h, Float32ToFloat16(f))
+			}
+
+			continue
+		}
+
+		if got := Float32ToFloat16(f); got != uint16(h) {
+			t.Fatalf("%#x: expected a round trip but got %#x", h, got)
+		}
+	}
+
+	tests := []struct {
+		f        float32
+		expected uint16
+	}{
+		{1 + 1.0/2048, 0x3c00},          // tie rounds to even
+		{1 + 3.0/2048, 0x3c02},          // tie rounds to even
+		{1 + 1.5/2048, 0x3c01},          // above the tie
+		{65520, 0x7c00},                 // overflows
+		{65519, 0x7bff},                 // rounds down to the largest value
+		{1e-8, 0x0000},                  // underflows
+		{3.0 / (1 << 25), 0x0002},       // subnormal tie rounds to even
+		{-5.960464477539063e-8, 0x8001}, // smallest negative subnormal
+		{1023.5 / (1 << 24), 0x0400},    // rounds up into the smallest normal
+	}
+
+	for _, tt := range tests {
+		if got := Float32ToFloat16(tt.f); got != tt.expected {
+			t.Fatalf("%v: expected %#x but got %#x", tt.f, tt.expected, got)
+		}
+	}
+}
+
+func TestDeterministicCBOR(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected string
+	}{
+		// shortest heads and floats
+		{"1b0000000000000001" + "fb3ff8000000000000" + "fa7fc00000", "01" + "f93e00" + "f97e00"},
+		// indefinite lengths become definite
+		{"5f42010243030405ff" + "7f657374726561646d696e67ff" + "9f018202039f0405ffff",
+			"450102030405" + "6973747265616d696e67" + "83018202038204 05"},
+		// map keys are sorted by their encoding, also nested ones and tagged ones
+		{"a3" + "6161" + "01" + "19 0100" + "02" + "0a" + "c1bf6162 00 6161 00 ff",
+			"a3" + "0a" + "c1a2616100616200" + "190100" + "02" + "6161" + "01"},
+	}
+
+	for i, tt := range tests {
+		res, err := DeterministicCBOR(mustHex(t, removeSpaces(tt.data)))
+		if err != nil {
+			t.Fatal(i, err)
+		}
+
+		if got := hex.EncodeToString(res); got != removeSpaces(tt.expected) {
+			t.Fatalf("%d: expected %s but got %s", i, removeSpaces(tt.expected), got)
+		}
+	}
+
+	if _, err := DeterministicCBOR(mustHex(t, "a2016161016162")); err == nil {
+		t.Fatal("expected a duplicate key")
+	}
+
+	if _, err := DeterministicCBOR(mustHex(t, "8201")); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected unexpected EOF but got %v", err)
+	}
+
+	if _, err := DeterministicCBOR(mustHex(t, "bf01ff")); err == nil {
+		t.Fatal("expected an error for a map key without value")
+	}
+
+	if _, err := TypedFromCBOR(mustHex(t, "bf01ff")); err == nil {
+		t.Fatal("expected an error for a map key without value")
+	}
+}
+
+func removeSpaces(s string) string {
+	return string(bytes.ReplaceAll([]byte(s), []byte(" "), nil))
+}
+
+func TestTypedCBOR(t *testing.T) {
+	buf := &TypedLittleEndianBuffer{Mode: WriteGrow}
+	buf.WriteUint8(200)
+	buf.WriteInt24(-5)
+	buf.WriteUint64(math.MaxUint64)
+	buf.WriteInt64(math.MinInt64)
+	buf.WriteFloat32(1.5)
+	buf.WriteFloat64(1.1)
+	buf.WriteComplex64(complex(1, -1))
+	buf.WriteBlob16([]byte{1, 2, 3})
+	buf.WriteString("hello")
+	buf.WriteBool(true)
+	buf.WriteNil()
+	buf.WriteArray(2)
+	buf.WriteVarint(-1)
+	buf.WriteUvarint(1)
+	buf.WriteMap(1)
+	buf.WriteString("key")
+	start := buf.BeginRecord()
+	buf.WriteUint16(7)
+	buf.EndRecord(start)
+
+	res, err := TypedToCBOR(buf.Bytes[:buf.Pos])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "18c8" + "24" + "1bffffffffffffffff" + "3b7fffffffffffffff" + "fa3fc00000" + "fb3ff199999999999a" +
+		"82fa3f800000fabf800000" + "43010203" + "6568656c6c6f" + "f5" + "f6" + "822001" + "a1636b6579" + "9f07ff"
+
+	if got := hex.EncodeToString(res); got != expected {
+		t.Fatalf("expected %s but got %s", expected, got)
+	}
+
+	typed, err := TypedFromCBOR(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	back := &TypedLittleEndianBuffer{Bytes: typed}
+
+	var values []Value
+	for back.HasNext() {
+		_, v := back.Next()
+		values = append(values, v)
+	}
+
+	expectedValues := []Value{uint64(200), int64(-5), uint64(math.MaxUint64), int64(math.MinInt64), float32(1.5),
+		1.1, 2, float32(1), float32(-1), []byte{1, 2, 3}, "hello", true, nil, 2, int64(-1), uint64(1), 1, "key",
+		1, uint64(7)}
+
+	if !reflect.DeepEqual(values, expectedValues) {
+		t.Fatalf("expected %v but got %v", expectedValues, values)
+	}
+
+	// tags are dropped and half-precision floats become float32
+	typed, err = TypedFromCBOR(mustHex(t, "c1f93e00"+"5f4101ff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	back = &TypedLittleEndianBuffer{Bytes: typed}
+	if v := back.ReadFloat32(); v != 1.5 {
+		t.Fatal(v)
+	}
+
+	if _, err := TypedFromCBOR(mustHex(t, "3bffffffffffffffff")); err == nil {
+		t.Fatal("expected an overflow")
+	}
+
+	if _, err := TypedFromCBOR(mustHex(t, "f0")); err == nil {
+		t.Fatal("expected an unsupported simple value")
+	}
+
+	if _, err := TypedToCBOR([]byte{byte(TUint32), 1}); err == nil {
+		t.Fatal("expected a malformed buffer")
+	}
+
+	if _, err := TypedToCBOR(nestedArrays(maxTypedDepth)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := TypedToCBOR(nestedArrays(maxTypedDepth + 1)); err == nil {
+		t.Fatal("expected a nesting error")
+	}
+}
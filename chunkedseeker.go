@@ -0,0 +1,374 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"io"
+	"sort"
+)
+
+// DefaultChunkSize is the chunk size of a ChunkedByteSeeker, which has been created without an explicit size.
+const DefaultChunkSize = 64 * 1024
+
+// ChunkedByteSeeker is an in-memory io.ReadWriteSeeker like the ByteSeeker, but backed by chunks of a fixed size
+// instead of a contiguous slice. Growing never copies existing data and regions, which have never been written,
+// do not allocate any memory but are read as zeros. This makes it suitable for huge and sparse in-memory files.
+// The zero value is ready to use with the DefaultChunkSize.
+type ChunkedByteSeeker struct {
+	chunkSize int
+	chunks    map[int64][]byte // chunks contains the allocated chunks by index, sparse regions are missing
+	shared    map[int64]bool   // shared marks the chunks, which are referenced by a Snapshot and must be copied on write
+	size      int64
+	pos       int64
+}
+
+// NewChunkedByteSeeker creates a new instance with the given chunk size.
+func NewChunkedByteSeeker(chunkSize int) *ChunkedByteSeeker {
+	if chunkSize <= 0 {
+		panic("invalid chunk size")
+	}
+
+	return &ChunkedByteSeeker{chunkSize: chunkSize}
+}
+
+func (b *ChunkedByteSeeker) csize() int64 {
+	if b.chunkSize == 0 {
+		b.chunkSize = DefaultChunkSize
+	}
+
+	return int64(b.chunkSize)
+}
+
+// Read returns EOF if no bytes can be read anymore.
+func (b *ChunkedByteSeeker) Read(p []byte) (n int, err error) {
+	n, err = b.ReadAt(p, b.pos)
+	b.pos += int64(n)
+
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+
+	return n, err
+}
+
+// ReadAt reads len(p) bytes at the given offset, without changing the position. Like an os.File, it returns
+// io.EOF if less than len(p) bytes are available.
+func (b *ChunkedByteSeeker) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+
+	if off >= b.size {
+		if len(p) == 0 {
+			return 0, nil
+		}
+
+		return 0, io.EOF
+	}
+
+	cs := b.csize()
+
+	for n < len(p) && off < b.size {
+		idx, chunkOff := off/cs, off%cs
+		dst := p[n:]
+
+		if max := minInt64(cs-chunkOff, b.size-off); int64(len(dst)) > max {
+			dst = dst[:max]
+		}
+
+		if chunk := b.chunks[idx]; chunk != nil {
+			copy(dst, chunk[chunkOff:])
+		} else {
+			for i := range dst {
+				dst[i] = 0
+			}
+		}
+
+		n += len(dst)
+		off += int64(len(dst))
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Write writes p at the current position and enlarges the file, if required.
+func (b *ChunkedByteSeeker) Write(p []byte) (n int, err error) {
+	n, err = b.WriteAt(p, b.pos)
+	b.pos += int64(n)
+
+	return n, err
+}
+
+// WriteAt writes p at the given offset, without changing the position. Writing beyond the end enlarges the
+// file and leaves the gap sparse.
+func (b *ChunkedByteSeeker) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+
+	if off > MaxInt64-int64(len(p)) {
+		return 0, errOffsetTooLarge
+	}
+
+	cs := b.csize()
+
+	if end := off + int64(len(p)); end > b.size {
+		b.size = end
+	}
+
+	for n < len(p) {
+		idx, chunkOff := off/cs, off%cs
+		m := copy(b.ownChunk(idx)[chunkOff:], p[n:])
+		n += m
+		off += int64(m)
+	}
+
+	return n, nil
+}
+
+// Seek returns EOF if seeking before the beginning. Like an os.File, seeking beyond the end does not change the
+// size, but a subsequent write enlarges the file and leaves the gap sparse.
+func (b *ChunkedByteSeeker) Seek(offset int64, whence int) (int64, error) {
+	var newPos int64
+
+	switch whence {
+	case io.SeekStart:
+		newPos = offset
+	case io.SeekCurrent:
+		newPos = b.pos + offset
+	case io.SeekEnd:
+		newPos = b.size + offset
+	}
+
+	if newPos < 0 {
+		b.pos = 0
+		return 0, io.EOF
+	}
+
+	b.pos = newPos
+
+	return b.pos, nil
+}
+
+// Truncate changes the size of the file, without changing the position. Enlarging leaves the new region sparse
+// and shrinking releases all chunks beyond the new size.
+func (b *ChunkedByteSeeker) Truncate(size int64) error {
+	if size < 0 {
+		return errNegativeOffset
+	}
+
+	cs := b.csize()
+	n := (size + cs - 1) / cs
+
+	for idx := range b.chunks {
+		if idx >= n {
+			delete(b.chunks, idx)
+			delete(b.shared, idx)
+		}
+	}
+
+	// the tail of the last chunk must read as zeros, if the file is enlarged again
+	if size < b.size && size%cs != 0 && b.chunks[n-1] != nil {
+		tail := b.ownChunk(n - 1)[size%cs:]
+		for i := range tail {
+			tail[i] = 0
+		}
+	}
+
+	b.size = size
+
+	return nil
+}
+
+// ownChunk returns the chunk at the given index for writing. A sparse chunk is allocated and a shared chunk
+// is copied first.
+func (b *ChunkedByteSeeker) ownChunk(idx int64) []byte {
+	if b.chunks == nil {
+		b.chunks = map[int64][]byte{}
+	}
+
+	chunk := b.chunks[idx]
+
+	switch {
+	case chunk == nil:
+		chunk = make([]byte, b.csize())
+		b.chunks[idx] = chunk
+	case b.shared[idx]:
+		chunk = append([]byte(nil), chunk...)
+		b.chunks[idx] = chunk
+		delete(b.shared, idx)
+	}
+
+	return chunk
+}
+
+// Snapshot returns a read-only point-in-time view of the file. Subsequent writes do not affect the snapshot,
+// because all chunks are shared and only copied, when they are modified afterwards. Taking a snapshot costs
+// a pointer per allocated chunk and does not copy any data. A snapshot can be read concurrently to writes of this
+// ChunkedByteSeeker, but taking the snapshot itself must not happen concurrently to a write.
+func (b *ChunkedByteSeeker) Snapshot() *Snapshot {
+	chunks := make(map[int64][]byte, len(b.chunks))
+	b.shared = make(map[int64]bool, len(b.chunks))
+
+	for idx, chunk := range b.chunks {
+		chunks[idx] = chunk
+		b.shared[idx] = true
+	}
+
+	return &Snapshot{view: ChunkedByteSeeker{
+		chunkSize: int(b.csize()),
+		chunks:    chunks,
+		size:      b.size,
+	}}
+}
+
+// WriteTo writes all bytes from the current position to the end into w and advances the position accordingly.
+func (b *ChunkedByteSeeker) WriteTo(w io.Writer) (n int64, err error) {
+	var zeros []byte
+
+	cs := b.csize()
+
+	for b.pos < b.size {
+		idx, chunkOff := b.pos/cs, b.pos%cs
+		end := minInt64(cs, b.size-idx*cs)
+
+		chunk := b.chunks[idx]
+		if chunk == nil {
+			if zeros == nil {
+				zeros = make([]byte, cs)
+			}
+
+			chunk = zeros
+		}
+
+		m, err := w.Write(chunk[chunkOff:end])
+		n += int64(m)
+		b.pos += int64(m)
+
+		if err != nil {
+			return n, err
+		}
+
+		if int64(m) < end-chunkOff {
+			return n, io.ErrShortWrite
+		}
+	}
+
+	return n, nil
+}
+
+// Chunks calls fn for each allocated chunk in ascending order with the offset of the chunk. Sparse regions are
+// skipped and the last chunk is cut at the end of the file. The chunks are not copied and must not be retained
+// or modified. Iteration stops at the first error, which is returned.
+func (b *ChunkedByteSeeker) Chunks(fn func(offset int64, chunk []byte) error) error {
+	cs := b.csize()
+
+	indices := make([]int64, 0, len(b.chunks))
+	for idx := range b.chunks {
+		indices = append(indices, idx)
+	}
+
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+
+	for _, idx := range indices {
+		chunk := b.chunks[idx]
+		offset := idx * cs
+		if err := fn(offset, chunk[:minInt64(cs, b.size-offset)]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close is a no-op
+func (b *ChunkedByteSeeker) Close() error {
+	return nil
+}
+
+// Bytes returns a flattened copy of the entire file. Consider using Chunks or WriteTo instead, to avoid the
+// allocation.
+func (b *ChunkedByteSeeker) Bytes() []byte {
+	buf := make([]byte, b.size)
+	_, _ = b.ReadAt(buf, 0)
+
+	return buf
+}
+
+// Size returns the size of the file.
+func (b *ChunkedByteSeeker) Size() int64 {
+	return b.size
+}
+
+// Pos returns the current position
+func (b *ChunkedByteSeeker) Pos() int64 {
+	return b.pos
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// A Snapshot is a read-only point-in-time view of a ChunkedByteSeeker. It implements io.ReadSeeker, io.ReaderAt
+// and io.WriterTo. ReadAt is safe for concurrent use, the other methods share the position and are not.
+type Snapshot struct {
+	view ChunkedByteSeeker // view is never written, so none of its chunks are ever modified
+}
+
+// Read returns EOF if no bytes can be read anymore.
+func (s *Snapshot) Read(p []byte) (n int, err error) {
+	return s.view.Read(p)
+}
+
+// ReadAt reads len(p) bytes at the given offset, without changing the position. Like an os.File, it returns
+// io.EOF if less than len(p) bytes are available.
+func (s *Snapshot) ReadAt(p []byte, off int64) (n int, err error) {
+	return s.view.ReadAt(p, off)
+}
+
+// Seek returns EOF if seeking before the beginning.
+func (s *Snapshot) Seek(offset int64, whence int) (int64, error) {
+	return s.view.Seek(offset, whence)
+}
+
+// WriteTo writes all bytes from the current position to the end into w and advances the position accordingly.
+func (s *Snapshot) WriteTo(w io.Writer) (n int64, err error) {
+	return s.view.WriteTo(w)
+}
+
+// Chunks calls fn for each allocated chunk, see ChunkedByteSeeker.Chunks.
+func (s *Snapshot) Chunks(fn func(offset int64, chunk []byte) error) error {
+	return s.view.Chunks(fn)
+}
+
+// Bytes returns a flattened copy of the entire snapshot.
+func (s *Snapshot) Bytes() []byte {
+	return s.view.Bytes()
+}
+
+// Size returns the size of the snapshot.
+func (s *Snapshot) Size() int64 {
+	return s.view.size
+}
@@ -0,0 +1,180 @@
+package ioutil
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+func TestChunkedByteSeeker_Conformance(t *testing.T) {
+	testFileConformance(t, NewChunkedByteSeeker(100))
+}
+
+func TestChunkedByteSeeker_Sparse(t *testing.T) {
+	seeker := NewChunkedByteSeeker(DefaultChunkSize)
+
+	if _, err := seeker.Seek(1<<32, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := seeker.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	if seeker.Size() != 1<<32+5 {
+		t.Fatalf("unexpected size %d", seeker.Size())
+	}
+
+	var offsets []int64
+
+	err := seeker.Chunks(func(offset int64, chunk []byte) error {
+		offsets = append(offsets, offset)
+
+		if !bytes.Equal(chunk, []byte("hello")) {
+			t.Fatalf("unexpected chunk %q", chunk)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(offsets) != 1 || offsets[0] != 1<<32 {
+		t.Fatalf("expected a single allocated chunk but got %v", offsets)
+	}
+
+	buf := make([]byte, 8)
+	if n, err := seeker.ReadAt(buf, 1<<32-3); n != 8 || err != nil || string(buf) != "\x00\x00\x00hello" {
+		t.Fatalf("unexpected read %d %v %q", n, err, buf)
+	}
+}
+
+func TestChunkedByteSeeker_WriteAt(t *testing.T) {
+	seeker := NewChunkedByteSeeker(64)
+	if n, err := seeker.WriteAt([]byte{1}, MaxInt64); n != 0 || err == nil {
+		t.Fatalf("expected an error but got %d", n)
+	}
+
+	if _, err := seeker.Seek(MaxInt64, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := seeker.Write([]byte{1}); n != 0 || err == nil || seeker.Size() != 0 {
+		t.Fatalf("expected an error but got %d", n)
+	}
+	// huge offsets are valid and stay sparse
+	for _, off := range []int64{1 << 62, MaxInt64 - 1} {
+		huge := NewChunkedByteSeeker(64)
+		if n, err := huge.WriteAt([]byte{42}, off); n != 1 || err != nil || huge.Size() != off+1 {
+			t.Fatalf("unexpected %d %v with size %d", n, err, huge.Size())
+		}
+
+		buf := make([]byte, 2)
+		if n, err := huge.ReadAt(buf, off-1); n != 2 || err != nil || buf[0] != 0 || buf[1] != 42 {
+			t.Fatalf("unexpected %d %v %v", n, err, buf)
+		}
+
+		count := 0
+		_ = huge.Chunks(func(offset int64, chunk []byte) error {
+			count++
+			return nil
+		})
+
+		if count != 1 {
+			t.Fatalf("expected a single chunk but got %d", count)
+		}
+
+		if err := huge.Truncate(off / 2); err != nil || huge.Size() != off/2 {
+			t.Fatalf("unexpected %v with size %d", err, huge.Size())
+		}
+
+		if err := huge.Truncate(off); err != nil || huge.Size() != off {
+			t.Fatalf("unexpected %v with size %d", err, huge.Size())
+		}
+	}
+}
+
+func TestChunkedByteSeeker_Snapshot(t *testing.T) {
+	seeker := NewChunkedByteSeeker(64)
+	rnd := rand.New(rand.NewSource(1))
+
+	data := make([]byte, 1000)
+	rnd.Read(data)
+
+	if _, err := seeker.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := seeker.Snapshot()
+	expected := seeker.Bytes()
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		rnd := rand.New(rand.NewSource(2))
+
+		for i := 0; i < 100; i++ {
+			buf := make([]byte, 100)
+			off := rnd.Int63n(900)
+
+			if _, err := snapshot.ReadAt(buf, off); err != nil || !bytes.Equal(buf, expected[off:off+100]) {
+				t.Errorf("snapshot changed at %d: %v", off, err)
+				return
+			}
+		}
+	}()
+
+	// modify, shrink and enlarge the file, while the snapshot is read concurrently
+	for i := 0; i < 10; i++ {
+		if _, err := seeker.WriteAt([]byte("modified"), 512+rnd.Int63n(600)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := seeker.Truncate(333); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := seeker.Truncate(2000); err != nil {
+		t.Fatal(err)
+	}
+
+	wg.Wait()
+
+	if snapshot.Size() != 1000 || !bytes.Equal(snapshot.Bytes(), expected) {
+		t.Fatal("snapshot has been modified")
+	}
+
+	all, err := ioutil.ReadAll(snapshot)
+	if err != nil || !bytes.Equal(all, expected) {
+		t.Fatalf("unexpected snapshot content: %v", err)
+	}
+
+	// the seeker only copied the modified chunks
+	shared := 0
+
+	_ = seeker.Chunks(func(offset int64, chunk []byte) error {
+		if offset < 1000 && &chunk[0] == &snapshot.view.chunks[offset/64][0] {
+			shared++
+		}
+
+		return nil
+	})
+
+	if shared == 0 {
+		t.Fatal("expected unchanged chunks to be shared")
+	}
+
+	if !bytes.Equal(seeker.Bytes()[333:], make([]byte, 2000-333)) {
+		t.Fatal("expected zeros after truncating and enlarging")
+	}
+}
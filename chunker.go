@@ -0,0 +1,230 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"hash"
+	"io"
+	"math/bits"
+	"strconv"
+)
+
+// ChunkSizes configures the sizes of the chunks, which are emitted by a Chunker. Zero values are derived from
+// the given ones, so that Min is a quarter and Max eight times the average size. Without any given size, the
+// defaults are 2 KiB, 8 KiB and 64 KiB.
+type ChunkSizes struct {
+	// Min is the minimum size of a chunk. Only the last chunk of a stream may be smaller.
+	Min int
+
+	// Avg is the desired average size of a chunk. It is rounded to the next power of two.
+	Avg int
+
+	// Max is the maximum size of a chunk. A boundary is forced, if no content-defined boundary has been found.
+	Max int
+}
+
+// A Chunk is a content-defined part of a stream.
+type Chunk struct {
+	Offset int64  // Offset is the position of the first byte of the chunk within the stream.
+	Data   []byte // Data is only valid until the next call to Chunker.Next.
+	Sum    []byte // Sum is the digest of Data.
+}
+
+// A Chunker splits a stream at content-defined boundaries using the FastCDC algorithm, so that inserting or
+// removing bytes only affects the chunks around the modification and all other chunks can be deduplicated.
+// The digest of each chunk is the same as the Sum of a HashReader, which has read exactly the chunk. Because
+// the Chunker reads ahead to find the boundaries, it writes each chunk into the hash itself instead of wrapping
+// the reader into a HashReader. The boundaries are determined by a fixed gear table, so equal content is always
+// split at equal positions. A Chunker is not thread safe.
+type Chunker struct {
+	reader   io.Reader
+	hasher   hash.Hash
+	sizes    ChunkSizes
+	maskS    uint64 // maskS is the harder mask, used before reaching the average size
+	maskL    uint64 // maskL is the easier mask, used after reaching the average size
+	buf      []byte
+	start    int
+	end      int
+	offset   int64
+	eof      bool
+	firstErr error
+}
+
+// NewChunker creates a new instance, which reads from reader and calculates the digest of each chunk using h.
+// It panics, if the sizes are not ordered like 0 < Min <= Avg <= Max.
+func NewChunker(reader io.Reader, h hash.Hash, sizes ChunkSizes) *Chunker {
+	switch {
+	case sizes.Avg != 0:
+	case sizes.Min != 0:
+		sizes.Avg = 4 * sizes.Min
+	case sizes.Max != 0:
+		sizes.Avg = (sizes.Max + 7) / 8
+	default:
+		sizes.Avg = 8 * 1024
+	}
+
+	if sizes.Max != 0 && sizes.Avg > sizes.Max {
+		sizes.Avg = sizes.Max
+	}
+
+	if sizes.Min == 0 {
+		sizes.Min = (sizes.Avg + 3) / 4
+	}
+
+	if sizes.Max == 0 {
+		sizes.Max = 8 * sizes.Avg
+	}
+
+	if sizes.Min <= 0 || sizes.Min > sizes.Avg || sizes.Avg > sizes.Max {
+		panic("invalid chunk sizes " + strconv.Itoa(sizes.Min) + "/" + strconv.Itoa(sizes.Avg) + "/" +
+			strconv.Itoa(sizes.Max))
+	}
+
+	// the average is rounded to a power of two and normalized chunking uses 2 bits more or less
+	avgBits := bits.Len(uint(sizes.Avg - 1))
+	sizes.Avg = 1 << avgBits
+
+	if sizes.Avg > sizes.Max {
+		sizes.Avg = sizes.Max
+	}
+
+	return &Chunker{
+		reader: reader,
+		hasher: h,
+		sizes:  sizes,
+		maskS:  chunkMask(avgBits + 2),
+		maskL:  chunkMask(avgBits - 2),
+		buf:    make([]byte, sizes.Max),
+	}
+}
+
+// chunkMask returns a mask with the n most significant bits set. The gear hash is shifted by one bit per byte,
+// so these bits depend on the last 64 bytes, like the whole hash.
+func chunkMask(n int) uint64 {
+	if n < 1 {
+		n = 1
+	}
+
+	return ^uint64(0) << uint(64-n)
+}
+
+// Next returns the next chunk or io.EOF, if the stream has been consumed completely. Any other error of the
+// wrapped reader is returned as is. Once an error has been returned, any call returns the same error.
+func (c *Chunker) Next() (Chunk, error) {
+	if c.firstErr != nil {
+		return Chunk{}, c.firstErr
+	}
+
+	if err := c.fill(); err != nil {
+		c.firstErr = err
+		return Chunk{}, err
+	}
+
+	if c.start == c.end {
+		c.firstErr = io.EOF
+		return Chunk{}, io.EOF
+	}
+
+	data := c.buf[c.start : c.start+c.cut(c.buf[c.start:c.end])]
+
+	c.hasher.Reset()
+	_, _ = c.hasher.Write(data) // a hash.Hash never returns an error
+
+	chunk := Chunk{Offset: c.offset, Data: data, Sum: c.hasher.Sum(nil)}
+	c.start += len(data)
+	c.offset += int64(len(data))
+
+	return chunk, nil
+}
+
+// fill reads until at least Max bytes are buffered or the stream has been consumed.
+func (c *Chunker) fill() error {
+	if c.eof || c.end-c.start >= c.sizes.Max {
+		return nil
+	}
+
+	c.end = copy(c.buf, c.buf[c.start:c.end])
+	c.start = 0
+
+	for c.end < len(c.buf) {
+		n, err := c.reader.Read(c.buf[c.end:])
+		c.end += n
+
+		if err == io.EOF {
+			c.eof = true
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cut returns the length of the next chunk within data using normalized chunking.
+func (c *Chunker) cut(data []byte) int {
+	n := len(data)
+	if n <= c.sizes.Min {
+		return n
+	}
+
+	if n > c.sizes.Max {
+		n = c.sizes.Max
+	}
+
+	normal := c.sizes.Avg
+	if normal > n {
+		normal = n
+	}
+
+	var fp uint64
+
+	i := c.sizes.Min
+	for ; i < normal; i++ {
+		fp = fp<<1 + gearTable[data[i]]
+		if fp&c.maskS == 0 {
+			return i + 1
+		}
+	}
+
+	for ; i < n; i++ {
+		fp = fp<<1 + gearTable[data[i]]
+		if fp&c.maskL == 0 {
+			return i + 1
+		}
+	}
+
+	return n
+}
+
+// gearTable contains 256 random values for the rolling gear hash. It is derived from a fixed seed using
+// splitmix64 and must never change, otherwise previously chunked streams cannot be deduplicated anymore.
+var gearTable = func() (table [256]uint64) {
+	seed := uint64(0x6A09E667F3BCC908)
+
+	for i := range table {
+		seed += 0x9E3779B97F4A7C15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+		z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+		table[i] = z ^ (z >> 31)
+	}
+
+	return table
+}()
@@ -0,0 +1,125 @@
+package ioutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+func chunkAll(t *testing.T, reader io.Reader, sizes ChunkSizes) []Chunk {
+	t.Helper()
+
+	var chunks []Chunk
+
+	chunker := NewChunker(reader, sha256.New(), sizes)
+
+	for {
+		chunk, err := chunker.Next()
+		if err == io.EOF {
+			return chunks
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		chunk.Data = append([]byte(nil), chunk.Data...)
+		chunks = append(chunks, chunk)
+	}
+}
+
+func TestChunker(t *testing.T) {
+	src := make([]byte, 1024*1024)
+	rand.New(rand.NewSource(1)).Read(src)
+
+	sizes := ChunkSizes{Min: 1024, Avg: 4096, Max: 16 * 1024}
+	chunks := chunkAll(t, iotest.HalfReader(bytes.NewReader(src)), sizes)
+
+	joined := &bytes.Buffer{}
+
+	for i, chunk := range chunks {
+		if chunk.Offset != int64(joined.Len()) {
+			t.Fatalf("%d: expected offset %d but got %d", i, joined.Len(), chunk.Offset)
+		}
+
+		if len(chunk.Data) > sizes.Max || len(chunk.Data) < sizes.Min && i != len(chunks)-1 {
+			t.Fatalf("%d: invalid chunk size %d", i, len(chunk.Data))
+		}
+
+		hr := NewHashReader(sha256.New(), bytes.NewReader(chunk.Data))
+		if _, err := io.ReadFull(hr, make([]byte, len(chunk.Data))); err != nil || !bytes.Equal(hr.Sum(), chunk.Sum) {
+			t.Fatalf("%d: invalid digest", i)
+		}
+
+		joined.Write(chunk.Data)
+	}
+
+	if !bytes.Equal(joined.Bytes(), src) {
+		t.Fatal("chunks do not reassemble the stream")
+	}
+
+	if avg := len(src) / len(chunks); avg < sizes.Avg/2 || avg > sizes.Avg*2 {
+		t.Fatalf("unexpected average chunk size %d", avg)
+	}
+
+	// insert a few bytes in the middle, which must only affect the chunks around it
+	modified := append(append(append([]byte(nil), src[:500000]...), "inserted"...), src[500000:]...)
+
+	known := map[string]bool{}
+	for _, chunk := range chunks {
+		known[string(chunk.Sum)] = true
+	}
+
+	changed := 0
+
+	for _, chunk := range chunkAll(t, bytes.NewReader(modified), sizes) {
+		if !known[string(chunk.Sum)] {
+			changed++
+		}
+	}
+
+	if changed > 3 {
+		t.Fatalf("expected at most 3 changed chunks but got %d", changed)
+	}
+}
+
+func TestChunker_Empty(t *testing.T) {
+	if chunks := chunkAll(t, bytes.NewReader(nil), ChunkSizes{}); len(chunks) != 0 {
+		t.Fatalf("expected no chunks but got %d", len(chunks))
+	}
+
+	if chunks := chunkAll(t, bytes.NewReader([]byte("abc")), ChunkSizes{}); len(chunks) != 1 {
+		t.Fatalf("expected a single chunk but got %d", len(chunks))
+	}
+}
+
+func TestNewChunker_Sizes(t *testing.T) {
+	tests := []struct {
+		given    ChunkSizes
+		expected ChunkSizes
+	}{
+		{ChunkSizes{}, ChunkSizes{Min: 2 * 1024, Avg: 8 * 1024, Max: 64 * 1024}},
+		{ChunkSizes{Min: 16 * 1024}, ChunkSizes{Min: 16 * 1024, Avg: 64 * 1024, Max: 512 * 1024}},
+		{ChunkSizes{Avg: 1024}, ChunkSizes{Min: 256, Avg: 1024, Max: 8 * 1024}},
+		{ChunkSizes{Max: 1024}, ChunkSizes{Min: 32, Avg: 128, Max: 1024}},
+		{ChunkSizes{Min: 1024, Max: 2048}, ChunkSizes{Min: 1024, Avg: 2048, Max: 2048}},
+		{ChunkSizes{Avg: 3000, Max: 4000}, ChunkSizes{Min: 750, Avg: 4000, Max: 4000}},
+	}
+
+	for _, test := range tests {
+		if sizes := NewChunker(nil, sha256.New(), test.given).sizes; sizes != test.expected {
+			t.Fatalf("%+v: expected %+v but got %+v", test.given, test.expected, sizes)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for contradicting sizes")
+		}
+	}()
+
+	NewChunker(nil, sha256.New(), ChunkSizes{Min: 4096, Max: 1024})
+}
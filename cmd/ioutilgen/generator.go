@@ -0,0 +1,887 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/worldiety/ioutil"
+)
+
+// mode selects which kind of method is generated.
+type mode int
+
+const (
+	encode mode = iota
+	decode
+	encodeBuffer
+	decodeBuffer
+)
+
+// wireInt describes the width and signedness of an integer wire kind.
+type wireInt struct {
+	bytes  int
+	signed bool
+	varint bool
+}
+
+var wireInts = map[string]wireInt{ //nolint:gochecknoglobals
+	"u8":      {1, false, false},
+	"u16":     {2, false, false},
+	"u24":     {3, false, false},
+	"u32":     {4, false, false},
+	"u40":     {5, false, false},
+	"u48":     {6, false, false},
+	"u56":     {7, false, false},
+	"u64":     {8, false, false},
+	"uvarint": {0, false, true},
+	"i8":      {1, true, false},
+	"i16":     {2, true, false},
+	"i24":     {3, true, false},
+	"i32":     {4, true, false},
+	"i40":     {5, true, false},
+	"i48":     {6, true, false},
+	"i56":     {7, true, false},
+	"i64":     {8, true, false},
+	"varint":  {0, true, true},
+}
+
+// prefixKinds maps the storage class of a length prefix to the according wire kind.
+var prefixKinds = map[ioutil.IntSize]string{ //nolint:gochecknoglobals
+	ioutil.I8:   "u8",
+	ioutil.I16:  "u16",
+	ioutil.I24:  "u24",
+	ioutil.I32:  "u32",
+	ioutil.I40:  "u40",
+	ioutil.I64:  "u64",
+	ioutil.IVar: "uvarint",
+}
+
+// prefixMax contains the largest length of a storage class, which can overflow.
+var prefixMax = map[ioutil.IntSize]string{ //nolint:gochecknoglobals
+	ioutil.I8:  "math.MaxUint8",
+	ioutil.I16: "math.MaxUint16",
+	ioutil.I24: "ioutil.MaxUint24",
+	ioutil.I32: "uint64(math.MaxUint32)",
+	ioutil.I40: "ioutil.MaxUint40",
+}
+
+var intSizeNames = map[ioutil.IntSize]string{ //nolint:gochecknoglobals
+	ioutil.I8:   "ioutil.I8",
+	ioutil.I16:  "ioutil.I16",
+	ioutil.I24:  "ioutil.I24",
+	ioutil.I32:  "ioutil.I32",
+	ioutil.I40:  "ioutil.I40",
+	ioutil.I64:  "ioutil.I64",
+	ioutil.IVar: "ioutil.IVar",
+}
+
+// goType is a resolved field type.
+type goType struct {
+	name  string          // name is the type expression as written in the source
+	basic string          // basic is the underlying predeclared type, if any
+	strct *ast.StructType // strct is the underlying struct, if any
+	elem  *goType         // elem is the element type of arrays and slices
+	array bool            // array is true for fixed size arrays
+}
+
+func (t *goType) isInt() bool {
+	switch t.basic {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr":
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *goType) isFloat() bool {
+	return t.basic == "float32" || t.basic == "float64"
+}
+
+func (t *goType) isComplex() bool {
+	return t.basic == "complex64" || t.basic == "complex128"
+}
+
+func (t *goType) isByteSlice() bool {
+	return t.elem != nil && !t.array && t.elem.basic == "uint8"
+}
+
+// generator collects the generated source code.
+type generator struct {
+	buf     bytes.Buffer
+	order   string
+	specs   map[string]*ast.TypeSpec
+	imports map[string]bool
+	defines map[string]bool // defines contains variables, whose first assignment must declare them
+	vars    int
+}
+
+// Generate parses the package in dir and returns the formatted source code for the given struct types.
+// The order is either le or be and defines the default byte order of the Encoder and Decoder methods.
+func Generate(dir string, order string, typeNames []string) ([]byte, error) {
+	g := &generator{specs: map[string]*ast.TypeSpec{}, imports: map[string]bool{}, defines: map[string]bool{}}
+
+	switch order {
+	case "le":
+		g.order = ioutil.LittleEndian.String()
+	case "be":
+		g.order = ioutil.BigEndian.String()
+	default:
+		return nil, fmt.Errorf("invalid byte order '%s'", order)
+	}
+
+	fset := token.NewFileSet()
+
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected exactly one package in %s but found %d", dir, len(pkgs))
+	}
+
+	var pkgName string
+
+	for name, pkg := range pkgs {
+		pkgName = name
+
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+					for _, spec := range gen.Specs {
+						ts := spec.(*ast.TypeSpec)
+						g.specs[ts.Name.Name] = ts
+					}
+				}
+			}
+		}
+	}
+
+	for _, name := range typeNames {
+		if err := g.generateType(strings.TrimSpace(name)); err != nil {
+			return nil, err
+		}
+	}
+
+	return g.source(pkgName)
+}
+
+func (g *generator) source(pkgName string) ([]byte, error) {
+	var imports []string
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+
+	sort.Strings(imports)
+
+	src := &bytes.Buffer{}
+	src.WriteString("// Code generated by ioutilgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(src, "package %s\n\n", pkgName)
+	src.WriteString("import (\n")
+
+	for _, imp := range imports {
+		fmt.Fprintf(src, "\t%s\n", strconv.Quote(imp))
+	}
+
+	src.WriteString("\n\t\"github.com/worldiety/ioutil\"\n)\n")
+	src.Write(g.buf.Bytes())
+
+	res, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid code: %w\n%s", err, src.String())
+	}
+
+	return res, nil
+}
+
+func (g *generator) generateType(name string) error {
+	spec, ok := g.specs[name]
+	if !ok {
+		return fmt.Errorf("type %s not found", name)
+	}
+
+	t, err := g.resolve(&ast.Ident{Name: name})
+	if err != nil {
+		return err
+	}
+
+	if t.strct == nil {
+		return fmt.Errorf("type %s is not a struct", spec.Name.Name)
+	}
+
+	methods := []struct {
+		mode mode
+		doc  string
+		sig  string
+	}{
+		{encode, "EncodeTo writes all fields using the given Encoder.", "EncodeTo(e *ioutil.Encoder)"},
+		{decode, "DecodeFrom reads all fields using the given Decoder.", "DecodeFrom(d *ioutil.Decoder)"},
+		{encodeBuffer, "EncodeToBuffer writes all fields at the current buffer position, according to its " +
+			"WriteMode, and returns\n// an IntegerOverflow, if a length does not fit into its prefix.",
+			"EncodeToBuffer(b *ioutil.LittleEndianBuffer) error"},
+		{decodeBuffer, "DecodeFromBuffer reads all fields from the current buffer position. A truncated or " +
+			"malformed buffer results\n// in an error.",
+			"DecodeFromBuffer(b *ioutil.LittleEndianBuffer) error"},
+	}
+
+	for _, m := range methods {
+		g.vars = 0
+		fmt.Fprintf(&g.buf, "\n// %s\nfunc (v *%s) %s {\n", m.doc, name, m.sig)
+
+		if err := g.value(m.mode, "v", t, ioutil.FieldTag{Size: ioutil.IVar}, g.order); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		if m.mode == encodeBuffer || m.mode == decodeBuffer {
+			g.printf("return nil")
+		}
+
+		g.buf.WriteString("}\n")
+	}
+
+	return nil
+}
+
+// resolve determines the underlying type of the given expression.
+func (g *generator) resolve(expr ast.Expr) (*goType, error) {
+	t := &goType{name: types.ExprString(expr)}
+
+	switch e := expr.(type) {
+	case *ast.Ident:
+		switch e.Name {
+		case "byte":
+			t.basic = "uint8"
+			return t, nil
+		case "rune":
+			t.basic = "int32"
+			return t, nil
+		case "bool", "string", "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32",
+			"uint64", "uintptr", "float32", "float64", "complex64", "complex128":
+			t.basic = e.Name
+			return t, nil
+		}
+
+		spec, ok := g.specs[e.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown type %s", e.Name)
+		}
+
+		underlying, err := g.resolve(spec.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		underlying.name = e.Name
+
+		return underlying, nil
+	case *ast.ArrayType:
+		elem, err := g.resolve(e.Elt)
+		if err != nil {
+			return nil, err
+		}
+
+		t.elem = elem
+		t.array = e.Len != nil
+
+		return t, nil
+	case *ast.StructType:
+		t.strct = e
+		return t, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t.name)
+	}
+}
+
+func (g *generator) newVar(prefix string) string {
+	g.vars++
+	return prefix + strconv.Itoa(g.vars)
+}
+
+// assign emits the assignment of expr to x and declares x, if required.
+func (g *generator) assign(x, expr string) {
+	if g.defines[x] {
+		delete(g.defines, x)
+		g.printf("%s := %s", x, expr)
+
+		return
+	}
+
+	g.printf("%s = %s", x, expr)
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+	g.buf.WriteByte('\n')
+}
+
+// value emits the code for the expression x of type t.
+func (g *generator) value(m mode, x string, t *goType, tag ioutil.FieldTag, order string) error {
+	if tag.Order != nil {
+		order = tag.Order.String()
+	}
+
+	isBlobKind := tag.Kind == "" || tag.Kind == "blob" || tag.Kind == "utf8"
+
+	switch {
+	case isBlobKind && (t.basic == "string" || t.isByteSlice()):
+		return g.blob(m, x, t, tag, order)
+	case t.strct != nil:
+		return g.structFields(m, x, t, order)
+	case t.elem != nil:
+		return g.elements(m, x, t, tag, order)
+	}
+
+	kind := tag.Kind
+	if kind == "" {
+		kind = defaultKind(t)
+	}
+
+	if wi, ok := wireInts[kind]; ok {
+		if !t.isInt() {
+			return fmt.Errorf("cannot use '%s' for %s", kind, t.name)
+		}
+
+		g.integer(m, x, t.name, kind, wi, order)
+
+		return nil
+	}
+
+	switch {
+	case kind == "bool" && t.basic == "bool":
+		g.boolean(m, x, t.name)
+	case (kind == "f32" || kind == "f64") && t.isFloat():
+		g.float(m, x, t.name, kind, order)
+	case (kind == "c64" || kind == "c128") && t.isComplex():
+		g.complex(m, x, t.name, kind, order)
+	default:
+		return fmt.Errorf("cannot use '%s' for %s", kind, t.name)
+	}
+
+	return nil
+}
+
+func defaultKind(t *goType) string {
+	switch t.basic {
+	case "bool":
+		return "bool"
+	case "int8":
+		return "i8"
+	case "int16":
+		return "i16"
+	case "int32":
+		return "i32"
+	case "int", "int64":
+		return "i64"
+	case "uint8":
+		return "u8"
+	case "uint16":
+		return "u16"
+	case "uint32":
+		return "u32"
+	case "uint", "uint64", "uintptr":
+		return "u64"
+	case "float32":
+		return "f32"
+	case "float64":
+		return "f64"
+	case "complex64":
+		return "c64"
+	case "complex128":
+		return "c128"
+	default:
+		return ""
+	}
+}
+
+// fieldTag parses the io struct tag of the field.
+func (g *generator) fieldTag(field *ast.Field) (ioutil.FieldTag, error) {
+	tagValue := ""
+	if field.Tag != nil {
+		unquoted, err := strconv.Unquote(field.Tag.Value)
+		if err != nil {
+			return ioutil.FieldTag{}, err
+		}
+
+		tagValue = reflect.StructTag(unquoted).Get(ioutil.TagName)
+	}
+
+	return ioutil.ParseFieldTag(tagValue)
+}
+
+func (g *generator) structFields(m mode, x string, t *goType, order string) error {
+	for _, field := range t.strct.Fields.List {
+		tag, err := g.fieldTag(field)
+		if err != nil {
+			return err
+		}
+
+		if tag.Skip {
+			continue
+		}
+
+		ft, err := g.resolve(field.Type)
+		if err != nil {
+			return err
+		}
+
+		names := field.Names
+		if len(names) == 0 {
+			// an embedded field is named after its type, just like reflection does
+			ident, ok := field.Type.(*ast.Ident)
+			if !ok {
+				return fmt.Errorf("unsupported embedded field %s", types.ExprString(field.Type))
+			}
+
+			names = []*ast.Ident{ident}
+		}
+
+		for _, name := range names {
+			if !name.IsExported() {
+				continue
+			}
+
+			if err := g.value(m, x+"."+name.Name, ft, tag, order); err != nil {
+				return fmt.Errorf("field %s: %w", name.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (g *generator) elements(m mode, x string, t *goType, tag ioutil.FieldTag, order string) error {
+	i := g.newVar("i")
+
+	if t.array {
+		g.printf("for %s := range %s {", i, x)
+
+		if err := g.value(m, x+"["+i+"]", t.elem, tag, order); err != nil {
+			return err
+		}
+
+		g.printf("}")
+
+		return nil
+	}
+
+	prefix := prefixKinds[tag.Size]
+
+	switch m {
+	case encode:
+		g.printf("e.WriteSize(ioutil.%s, %s, len(%s))", order, intSizeNames[tag.Size], x)
+	case encodeBuffer:
+		g.checkLen(x, tag.Size)
+		g.integer(m, "len("+x+")", "int", prefix, wireInts[prefix], order)
+	case decode:
+		n := g.newVar("n")
+		g.imports["unsafe"] = true
+		g.defines[n] = true
+		g.assign(n, fmt.Sprintf("d.ReadSize(ioutil.%s, %s, int(unsafe.Sizeof(%s[0])))", order,
+			intSizeNames[tag.Size], x))
+
+		return g.appendElements(m, x, t, tag, order, i, n+" && d.Error() == nil")
+	case decodeBuffer:
+		n := g.newVar("n")
+		g.defines[n] = true
+		g.integer(m, n, "int", prefix, wireInts[prefix], order)
+
+		occupies, err := g.occupiesBytes(t.elem)
+		if err != nil {
+			return err
+		}
+
+		// each element needs at least one byte, so a hostile length cannot allocate more than the buffer size
+		if !occupies {
+			return g.appendElements(m, x, t, tag, order, i, n)
+		}
+
+		g.checkRemaining(n)
+		g.printf("%s = make(%s, %s)", x, t.name, n)
+		g.printf("for %s := 0; %s < %s; %s++ {", i, i, n, i)
+	}
+
+	if m == encode || m == encodeBuffer {
+		g.printf("for %s := range %s {", i, x)
+	}
+
+	if err := g.value(m, x+"["+i+"]", t.elem, tag, order); err != nil {
+		return err
+	}
+
+	g.printf("}")
+
+	return nil
+}
+
+// appendElements emits a loop, which decodes each element before appending it, so that the slice only grows
+// with the actually decoded input instead of trusting the length prefix.
+func (g *generator) appendElements(m mode, x string, t *goType, tag ioutil.FieldTag, order, i, cond string) error {
+	e := g.newVar("e")
+
+	g.printf("%s = make(%s, 0)", x, t.name)
+	g.printf("for %s := 0; %s < %s; %s++ {", i, i, cond, i)
+	g.printf("var %s %s", e, t.elem.name)
+
+	if err := g.value(m, e, t.elem, tag, order); err != nil {
+		return err
+	}
+
+	g.printf("%s = append(%s, %s)", x, x, e)
+	g.printf("}")
+
+	return nil
+}
+
+// checkLen emits the overflow check of a length prefix for the buffer methods, which have no error state.
+func (g *generator) checkLen(x string, p ioutil.IntSize) {
+	max, ok := prefixMax[p]
+	if !ok {
+		return
+	}
+
+	g.imports["math"] = true
+	g.printf("if uint64(len(%s)) > uint64(%s) {", x, max)
+	g.printf("return ioutil.IntegerOverflow{Val: len(%s), Max: %s}", x, max)
+	g.printf("}")
+}
+
+// checkRemaining emits a check, that at least n bytes are left in the buffer.
+func (g *generator) checkRemaining(n string) {
+	g.printf("if %s < 0 || %s > len(b.Bytes)-b.Pos {", n, n)
+	g.printf("return ioutil.BufferOverrun{Pos: b.Pos, Len: %s, Missing: %s - (len(b.Bytes) - b.Pos)}", n, n)
+	g.printf("}")
+}
+
+// checkRead emits a check, that a fixed size value of n bytes can be read from the buffer.
+func (g *generator) checkRead(n int) {
+	g.printf("if len(b.Bytes)-b.Pos < %d {", n)
+	g.printf("return ioutil.BufferOverrun{Pos: b.Pos, Len: %d, Missing: %d - (len(b.Bytes) - b.Pos)}", n, n)
+	g.printf("}")
+}
+
+// checkedVarint emits a bounds-checked read of a varint into x, just like the CheckedLittleEndianBuffer does.
+func (g *generator) checkedVarint(x string, typeName string, method string, wireType string) {
+	v, n := g.newVar("v"), g.newVar("n")
+	g.imports["encoding/binary"] = true
+	g.imports["fmt"] = true
+
+	g.printf("%s, %s := binary.%s(b.Bytes[b.Pos:])", v, n, method)
+	g.printf("if %s == 0 {", n)
+	g.printf("return ioutil.BufferOverrun{Pos: b.Pos, Len: len(b.Bytes) - b.Pos + 1, Missing: 1}")
+	g.printf("}")
+	g.printf("if %s < 0 {", n)
+	g.printf("return fmt.Errorf(\"malformed varint at offset %%d\", b.Pos)")
+	g.printf("}")
+	g.printf("b.Pos += %s", n)
+	g.assign(x, conv(typeName, wireType, v))
+}
+
+// occupiesBytes returns true, if any value of the type is encoded using at least one byte.
+func (g *generator) occupiesBytes(t *goType) (bool, error) {
+	switch {
+	case t.array:
+		// the length of an array may be zero
+		return false, nil
+	case t.strct == nil:
+		return true, nil
+	}
+
+	for _, field := range t.strct.Fields.List {
+		tag, err := g.fieldTag(field)
+		if err != nil {
+			return false, err
+		}
+
+		if tag.Skip || (len(field.Names) > 0 && !field.Names[0].IsExported()) {
+			continue
+		}
+
+		ft, err := g.resolve(field.Type)
+		if err != nil {
+			return false, err
+		}
+
+		if occupies, err := g.occupiesBytes(ft); occupies || err != nil {
+			return occupies, err
+		}
+	}
+
+	return false, nil
+}
+
+func (g *generator) blob(m mode, x string, t *goType, tag ioutil.FieldTag, order string) error {
+	prefix := prefixKinds[tag.Size]
+
+	switch m {
+	case encode:
+		if t.basic == "string" {
+			g.printf("e.WriteUTF8(ioutil.%s, %s, %s)", order, intSizeNames[tag.Size], conv("string", t.name, x))
+		} else {
+			g.printf("e.WriteBlob(ioutil.%s, %s, %s)", order, intSizeNames[tag.Size], x)
+		}
+	case decode:
+		if t.basic == "string" {
+			g.printf("%s = %s", x, conv(t.name, "string",
+				fmt.Sprintf("d.ReadUTF8(ioutil.%s, %s)", order, intSizeNames[tag.Size])))
+		} else {
+			g.printf("%s = %s", x, conv(t.name, "[]byte",
+				fmt.Sprintf("d.ReadBlob(ioutil.%s, %s)", order, intSizeNames[tag.Size])))
+		}
+	case encodeBuffer:
+		g.checkLen(x, tag.Size)
+		g.integer(m, "len("+x+")", "int", prefix, wireInts[prefix], order)
+
+		if t.basic == "string" {
+			g.printf("b.WriteStringBytes(%s)", conv("string", t.name, x))
+		} else {
+			g.printf("b.WriteSlice(%s)", x)
+		}
+	case decodeBuffer:
+		n := g.newVar("n")
+		g.defines[n] = true
+		g.integer(m, n, "int", prefix, wireInts[prefix], order)
+		g.checkRemaining(n)
+
+		if t.basic == "string" {
+			g.printf("%s = %s", x, conv(t.name, "string", fmt.Sprintf("string(b.Bytes[b.Pos : b.Pos+%s])", n)))
+			g.printf("b.Pos += %s", n)
+		} else {
+			g.printf("%s = make(%s, %s)", x, t.name, n)
+			g.printf("b.ReadSlice(%s)", x)
+		}
+	}
+
+	return nil
+}
+
+// conv returns the expression x converted to the type name, if it is not already of the type have.
+func conv(name, have, x string) string {
+	aliases := map[string]string{"byte": "uint8", "rune": "int32"}
+	if name == have || aliases[name] == have || aliases[have] == name {
+		return x
+	}
+
+	return name + "(" + x + ")"
+}
+
+// method returns the name of the Encoder or LittleEndianBuffer method for the given wire int.
+func (w wireInt) method() string {
+	bits := strconv.Itoa(w.bytes * 8) //nolint:gomnd
+	if w.signed {
+		return "Int" + bits
+	}
+
+	return "Uint" + bits
+}
+
+// goTypes returns the unsigned and signed Go types used by the Encoder and Decoder for the wire int.
+func (w wireInt) goTypes() (string, string) {
+	switch {
+	case w.bytes == 1:
+		return "uint8", "int8"
+	case w.bytes == 2:
+		return "uint16", "int16"
+	case w.bytes <= 4:
+		return "uint32", "int32"
+	default:
+		return "uint64", "int64"
+	}
+}
+
+func (g *generator) integer(m mode, x string, typeName string, kind string, w wireInt, order string) {
+	unsigned, signed := w.goTypes()
+
+	wireType := unsigned
+	if w.signed {
+		wireType = signed
+	}
+
+	orderArg := "ioutil." + order + ", "
+	if w.bytes == 1 {
+		orderArg = ""
+	}
+
+	switch m {
+	case encode:
+		switch kind {
+		case "uvarint":
+			g.printf("e.WriteUvarint(%s)", conv("uint64", typeName, x))
+		case "varint":
+			g.printf("e.WriteVarint(%s)", conv("int64", typeName, x))
+		default:
+			g.printf("e.Write%s(%s%s)", w.method(), orderArg, conv(wireType, typeName, x))
+		}
+	case decode:
+		switch kind {
+		case "uvarint":
+			g.assign(x, conv(typeName, "uint64", "d.ReadUvarint()"))
+		case "varint":
+			g.assign(x, conv(typeName, "int64", "d.ReadVarint()"))
+		default:
+			g.assign(x, conv(typeName, wireType, fmt.Sprintf("d.Read%s(%s)", w.method(),
+				strings.TrimSuffix(orderArg, ", "))))
+		}
+	case encodeBuffer:
+		switch kind {
+		case "uvarint":
+			g.printf("b.WriteUvarint(%s)", conv("uint64", typeName, x))
+		case "varint":
+			g.printf("b.WriteVarint(%s)", conv("int64", typeName, x))
+		default:
+			g.printf("b.WriteUint%d(%s)", w.bytes*8, g.bufferOrder(conv(unsigned, typeName, x), w, order)) //nolint:gomnd
+		}
+	case decodeBuffer:
+		switch {
+		case kind == "uvarint":
+			g.checkedVarint(x, typeName, "Uvarint", "uint64")
+		case kind == "varint":
+			g.checkedVarint(x, typeName, "Varint", "int64")
+		default:
+			g.checkRead(w.bytes)
+			read := g.bufferOrder(fmt.Sprintf("b.ReadUint%d()", w.bytes*8), w, order) //nolint:gomnd
+
+			if w.signed {
+				// sign extension for the odd widths, just like the Decoder does
+				wireBits, _ := strconv.Atoi(strings.TrimPrefix(unsigned, "uint"))
+				shift := wireBits - w.bytes*8 //nolint:gomnd
+				if shift > 0 && strings.Contains(read, " ") {
+					read = fmt.Sprintf("%s((%s)<<%d) >> %d", signed, read, shift, shift)
+				} else if shift > 0 {
+					read = fmt.Sprintf("%s(%s<<%d) >> %d", signed, read, shift, shift)
+				} else {
+					read = signed + "(" + read + ")"
+				}
+
+				g.assign(x, conv(typeName, signed, read))
+			} else {
+				g.assign(x, conv(typeName, unsigned, read))
+			}
+		}
+	}
+}
+
+// bufferOrder returns the unsigned expression x, which is written or read by the LittleEndianBuffer, with the
+// order of its w.bytes lower bytes reversed, if the field is big endian. Reading and writing through the buffer
+// methods keeps the bounds checks and the WriteMode of the buffer.
+func (g *generator) bufferOrder(x string, w wireInt, order string) string {
+	if w.bytes == 1 || order == ioutil.LittleEndian.String() {
+		return x
+	}
+
+	unsigned, _ := w.goTypes()
+	wireBits, _ := strconv.Atoi(strings.TrimPrefix(unsigned, "uint"))
+	g.imports["math/bits"] = true
+
+	if shift := wireBits - w.bytes*8; shift > 0 { //nolint:gomnd
+		return fmt.Sprintf("bits.ReverseBytes%d(%s) >> %d", wireBits, x, shift)
+	}
+
+	return fmt.Sprintf("bits.ReverseBytes%d(%s)", wireBits, x)
+}
+
+func (g *generator) boolean(m mode, x string, typeName string) {
+	switch m {
+	case encode:
+		g.printf("e.WriteBool(%s)", conv("bool", typeName, x))
+	case decode:
+		g.assign(x, conv(typeName, "bool", "d.ReadBool()"))
+	case encodeBuffer:
+		g.printf("if %s {", x)
+		g.printf("b.WriteUint8(1)")
+		g.printf("} else {")
+		g.printf("b.WriteUint8(0)")
+		g.printf("}")
+	case decodeBuffer:
+		g.checkRead(1)
+		g.assign(x, conv(typeName, "bool", "b.ReadUint8() != 0"))
+	}
+}
+
+func (g *generator) float(m mode, x string, typeName string, kind string, order string) {
+	bits, typ, size := "32", "float32", 4
+	if kind == "f64" {
+		bits, typ, size = "64", "float64", 8
+	}
+
+	w := wireInt{bytes: size}
+
+	switch m {
+	case encode:
+		g.printf("e.WriteFloat%s(ioutil.%s, %s)", bits, order, conv(typ, typeName, x))
+	case decode:
+		g.assign(x, conv(typeName, typ, fmt.Sprintf("d.ReadFloat%s(ioutil.%s)", bits, order)))
+	case encodeBuffer:
+		if order == ioutil.LittleEndian.String() {
+			g.printf("b.WriteFloat%s(%s)", bits, conv(typ, typeName, x))
+			return
+		}
+
+		g.imports["math"] = true
+		g.printf("b.WriteUint%s(%s)", bits, g.bufferOrder(fmt.Sprintf("math.Float%sbits(%s)", bits, conv(typ, typeName, x)), w, order))
+	case decodeBuffer:
+		g.checkRead(size)
+
+		if order == ioutil.LittleEndian.String() {
+			g.assign(x, conv(typeName, typ, fmt.Sprintf("b.ReadFloat%s()", bits)))
+			return
+		}
+
+		g.imports["math"] = true
+		g.assign(x, conv(typeName, typ,
+			fmt.Sprintf("math.Float%sfrombits(%s)", bits, g.bufferOrder("b.ReadUint"+bits+"()", w, order))))
+	}
+}
+
+func (g *generator) complex(m mode, x string, typeName string, kind string, order string) {
+	typ, method, part := "complex64", "Complex64", "float32"
+	if kind == "c128" {
+		typ, method, part = "complex128", "Complex128", "float64"
+	}
+
+	partKind := "f" + strings.TrimPrefix(part, "float")
+
+	switch m {
+	case encode:
+		g.printf("e.Write%s(ioutil.%s, %s)", method, order, conv(typ, typeName, x))
+	case decode:
+		g.assign(x, conv(typeName, typ, fmt.Sprintf("d.Read%s(ioutil.%s)", method, order)))
+	case encodeBuffer:
+		c := conv(typ, typeName, x)
+		g.float(m, "real("+c+")", part, partKind, order)
+		g.float(m, "imag("+c+")", part, partKind, order)
+	case decodeBuffer:
+		re, im := g.newVar("re"), g.newVar("im")
+		g.defines[re], g.defines[im] = true, true
+		g.float(m, re, part, partKind, order)
+		g.float(m, im, part, partKind, order)
+		g.assign(x, conv(typeName, typ, "complex("+re+", "+im+")"))
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files") //nolint:gochecknoglobals
+
+func TestGenerateGolden(t *testing.T) {
+	tests := []struct {
+		dir    string
+		order  string
+		types  []string
+		golden string
+	}{
+		{"testdata/basic", "be", []string{"Header"}, "testdata/basic.golden"},
+		{"../../internal/gentest", "le", []string{"Record", "Inner", "Embedded"}, "../../internal/gentest/record_ioutil.go"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(filepath.Base(tt.golden), func(t *testing.T) {
+			actual, err := Generate(tt.dir, tt.order, tt.types)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if *update {
+				if err := ioutil.WriteFile(tt.golden, actual, 0644); err != nil { //nolint:gosec
+					t.Fatal(err)
+				}
+			}
+
+			expected, err := ioutil.ReadFile(tt.golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(expected, actual) {
+				t.Fatalf("expected \n%s\n but got \n%s", expected, actual)
+			}
+		})
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	if _, err := Generate("testdata/basic", "le", []string{"Missing"}); err == nil {
+		t.Fatal("expected an error for an unknown type")
+	}
+
+	if _, err := Generate("testdata/basic", "xe", []string{"Header"}); err == nil {
+		t.Fatal("expected an error for an invalid byte order")
+	}
+
+	if _, err := Generate("testdata/basic", "le", []string{"Flags"}); err == nil {
+		t.Fatal("expected an error for a non struct type")
+	}
+
+	if _, err := Generate("testdata/basic", "le", []string{"Embedded"}); err == nil {
+		t.Fatal("expected an error for an embedded pointer")
+	}
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Command ioutilgen generates allocation free EncodeTo, DecodeFrom, EncodeToBuffer and DecodeFromBuffer methods
+// for structs, which are annotated with the same io struct tags as understood by ioutil.Marshal. It is
+// intended to be used with go generate, e.g.
+//
+//  //go:generate ioutilgen -type=Header,Entry
+//
+// The generated methods produce exactly the same bytes as ioutil.Marshal and the according hand-written
+// Encoder or LittleEndianBuffer calls. In contrast to Marshal, values are converted like hand-written calls
+// would do, so there are no range checks for fields which are wider than their wire kind. Lengths of strings,
+// blobs and slices are checked like Marshal does and an overflow is recorded by the Encoder or returned by
+// EncodeToBuffer.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma separated list of struct type names, required")
+	order := flag.String("order", "le", "default byte order of the Encoder and Decoder methods, le or be")
+	output := flag.String("output", "", "output file name, default is <dir>/<first type>_ioutil.go")
+	flag.Parse()
+
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if args := flag.Args(); len(args) > 0 {
+		dir = args[0]
+	}
+
+	types := strings.Split(*typeNames, ",")
+
+	src, err := Generate(dir, *order, types)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ioutilgen:", err)
+		os.Exit(1)
+	}
+
+	name := *output
+	if name == "" {
+		name = filepath.Join(dir, strings.ToLower(types[0])+"_ioutil.go")
+	}
+
+	if err := ioutil.WriteFile(name, src, 0644); err != nil { //nolint:gosec
+		fmt.Fprintln(os.Stderr, "ioutilgen:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,22 @@
+package basic
+
+type Flags uint8
+
+type Header struct {
+	Magic   [4]byte
+	Version uint16 `io:"u16,le"`
+	Flags   Flags
+	Size    int64   `io:"i40"`
+	Name    string  `io:"utf8,I16"`
+	Points  []Point `io:"I8"`
+	Payload []byte  `io:"blob,IVar"`
+}
+
+type Point struct {
+	X, Y int32 `io:"varint"`
+	Z    float64
+}
+
+type Embedded struct {
+	*Point
+}
@@ -109,6 +109,9 @@ type DataInput interface {
 	// Error returns the first occurred error. Each call to any Read* method may cause an error.
 	Error() error
 
+	// Position returns the amount of bytes, which have been read so far.
+	Position() int64
+
 	io.Reader
 	io.ByteReader
 }
@@ -118,6 +121,15 @@ func NewDataInput(order ByteOrder, reader io.Reader) DataInput {
 	return dataInputImpl{decoder: NewDecoder(reader, true), order: order}
 }
 
+// NewLimitedDataInput creates a new DataInput instance, which refuses to allocate more memory than allowed by
+// the given limits. See also Decoder.SetLimits.
+func NewLimitedDataInput(order ByteOrder, reader io.Reader, limits Limits) DataInput {
+	decoder := NewDecoder(reader, true)
+	decoder.SetLimits(limits)
+
+	return dataInputImpl{decoder: decoder, order: order}
+}
+
 var _ DataInput = (*dataInputImpl)(nil)
 
 type dataInputImpl struct {
@@ -260,3 +272,7 @@ func (d dataInputImpl) ReadFull(b []byte) int {
 func (d dataInputImpl) Error() error {
 	return d.decoder.Error()
 }
+
+func (d dataInputImpl) Position() int64 {
+	return d.decoder.Position()
+}
@@ -108,6 +108,9 @@ type DataOutput interface {
 	// any other call after the first error is a no-op.
 	Error() error
 
+	// Position returns the amount of bytes, which have been written so far.
+	Position() int64
+
 	io.Writer
 	io.ByteWriter
 }
@@ -232,6 +235,10 @@ func (d dataOutputImpl) Error() error {
 	return d.encoder.Error()
 }
 
+func (d dataOutputImpl) Position() int64 {
+	return d.encoder.Position()
+}
+
 func (d dataOutputImpl) Write(p []byte) (n int, err error) {
 	return d.encoder.Write(p)
 }
@@ -28,11 +28,15 @@ import (
 // A Decoder implements various decoding helpers for Little Endian and Big Endian. It may optimize some
 // paths in the future, so that the generic call with byte order may be slower than the direct invocation.
 // The implementation reuses an internal buffer to avoid heap allocations and is therefore not thread safe.
+// Any error is wrapped into a *DecodeError, which tells the offset and the failed operation.
 type Decoder struct {
 	buf8        []byte
 	in          io.Reader
 	firstErr    error
 	failOnError bool
+	limits      Limits
+	allocated   int64
+	pos         int64
 }
 
 // NewDecoder wraps a reader to provide the decoder functions. If failOnError is true, any subsequent call
@@ -51,53 +55,148 @@ func (r *Decoder) Reset() {
 	r.firstErr = nil
 }
 
+// Position returns the amount of bytes, which have been read from the wrapped reader so far.
+func (r *Decoder) Position() int64 {
+	return r.pos
+}
+
 func (r *Decoder) quickFail() bool {
 	return r.failOnError && r.firstErr != nil
 }
 
+// SetLimits configures the maximum size of each blob and the total budget of bytes, which this Decoder may
+// allocate for blobs, strings and byte slices. The budget is accounted from now on.
+func (r *Decoder) SetLimits(limits Limits) {
+	r.limits = limits
+	r.allocated = 0
+}
+
+// Allocated returns the amount of bytes, which have been accounted against the budget.
+func (r *Decoder) Allocated() int64 {
+	return r.allocated
+}
+
 // ReadBlob reads a prefixed byte slice
 func (r *Decoder) ReadBlob(order ByteOrder, storageClass IntSize) []byte {
+	return r.readBlob("ReadBlob", order, storageClass, 0)
+}
+
+// ReadBlobMax reads a prefixed byte slice but refuses to allocate more than max bytes, if max is not 0.
+// The limits of the Decoder are applied as well.
+func (r *Decoder) ReadBlobMax(order ByteOrder, storageClass IntSize, max int) []byte {
+	return r.readBlob("ReadBlobMax", order, storageClass, max)
+}
+
+func (r *Decoder) readBlob(op string, order ByteOrder, storageClass IntSize, max int) []byte {
 	if r.quickFail() {
 		return nil
 	}
 
+	offset := r.pos
+
+	bytesToRead, ok := r.readSize(op, order, storageClass)
+	if !ok {
+		return nil
+	}
+
+	if !r.allocate(op, order, offset, bytesToRead, max) {
+		return nil
+	}
+
+	buf := make([]byte, bytesToRead)
+	r.readFull(op, order, buf)
+
+	return buf
+}
+
+// allocate checks the amount of bytes against the per call max, the configured limits and the budget.
+func (r *Decoder) allocate(op string, order ByteOrder, offset int64, n int, max int) bool {
+	if max > 0 && n > max {
+		r.noteErr(op, order, offset, BlobTooLarge{Len: n, Max: max})
+		return false
+	}
+
+	if r.limits.MaxBlobSize > 0 && n > r.limits.MaxBlobSize {
+		r.noteErr(op, order, offset, BlobTooLarge{Len: n, Max: r.limits.MaxBlobSize})
+		return false
+	}
+
+	if r.limits.Budget > 0 && int64(n) > r.limits.Budget-r.allocated {
+		err := BudgetExceeded{Len: n, Remaining: r.limits.Budget - r.allocated, Budget: r.limits.Budget}
+		r.noteErr(op, order, offset, err)
+
+		return false
+	}
+
+	r.allocated += int64(n)
+
+	return true
+}
+
+// ReadSize reads a length prefix, e.g. of a slice, using the given storage class. The n*elemSize bytes, which
+// are required to allocate the slice at once, are checked against the limits like a blob.
+func (r *Decoder) ReadSize(o ByteOrder, p IntSize, elemSize int) int {
+	if r.quickFail() {
+		return 0
+	}
+
+	offset := r.pos
+
+	n, ok := r.readSize("ReadSize", o, p)
+	if !ok {
+		return 0
+	}
+
+	if elemSize > 0 && n > MaxInt/elemSize {
+		r.noteErr("ReadSize", o, offset, IntegerOverflow{Val: n, Max: MaxInt / elemSize})
+		return 0
+	}
+
+	if !r.allocate("ReadSize", o, offset, n*elemSize, 0) {
+		return 0
+	}
+
+	return n
+}
+
+// readSize reads a length prefix of the given storage class and returns false, if it could not be read or
+// does not fit into an int.
+func (r *Decoder) readSize(op string, order ByteOrder, storageClass IntSize) (int, bool) {
 	var bytesToRead uint64
 
+	offset := r.pos
+
 	switch storageClass {
 	case I8:
-		bytesToRead = uint64(r.ReadUint8())
+		bytesToRead = uint64(r.readUint8(op))
 	case I16:
-		bytesToRead = uint64(r.ReadUint16(order))
+		bytesToRead = uint64(r.readUint16(op, order))
 	case I24:
-		bytesToRead = uint64(r.ReadUint24(order))
+		bytesToRead = uint64(r.readUint24(op, order))
 	case I32:
-		bytesToRead = uint64(r.ReadUint32(order))
+		bytesToRead = uint64(r.readUint32(op, order))
 	case I40:
-		bytesToRead = r.ReadUint40(order)
+		bytesToRead = r.readUint40(op, order)
 	case I64:
-		bytesToRead = r.ReadUint64(order)
+		bytesToRead = r.readUint64(op, order)
 	case IVar:
-		t, err := binary.ReadUvarint(r)
-		if r.noteErr(err) {
-			return nil
-		}
-
-		bytesToRead = t
+		bytesToRead = r.readUvarint(op)
 	default:
 		panic("invalid IntSize " + strconv.Itoa(int(storageClass)))
 	}
 
+	if r.firstErr != nil {
+		return 0, false
+	}
+
 	if bytesToRead > MaxInt {
 		err := fmt.Errorf("decoded length %d is larger than allowed (%d)", bytesToRead, MaxInt)
-		if r.noteErr(err) {
-			return nil
+		if r.noteErr(op, order, offset, err) {
+			return 0, false
 		}
 	}
 
-	buf := make([]byte, int(bytesToRead))
-	r.ReadFull(buf)
-
-	return buf
+	return int(bytesToRead), true
 }
 
 // ReadBytes just reads a bunch of bytes into a newly allocated buffer
@@ -106,21 +205,30 @@ func (r *Decoder) ReadBytes(len int) []byte {
 		return nil
 	}
 
+	if !r.allocate("ReadBytes", nil, r.pos, len, 0) {
+		return nil
+	}
+
 	buf := make([]byte, len)
-	n := r.ReadFull(buf)
+	n := r.readFull("ReadBytes", nil, buf)
 
 	return buf[0:n]
 }
 
 // ReadUvarint reads a variable length integer, up to 10 bytes using zig-zag protobuf encoding.
 func (r *Decoder) ReadUvarint() uint64 {
+	return r.readUvarint("ReadUvarint")
+}
+
+func (r *Decoder) readUvarint(op string) uint64 {
 	if r.quickFail() {
 		return 0
 	}
 
-	t, err := binary.ReadUvarint(r)
+	offset := r.pos
 
-	if r.noteErr(err) {
+	t, err := binary.ReadUvarint((*decoderByteReader)(r))
+	if r.noteErr(op, nil, offset, err) {
 		return 0
 	}
 
@@ -133,261 +241,294 @@ func (r *Decoder) ReadVarint() int64 {
 		return 0
 	}
 
-	t, err := binary.ReadVarint(r)
-	if r.noteErr(err) {
+	offset := r.pos
+
+	t, err := binary.ReadVarint((*decoderByteReader)(r))
+	if r.noteErr("ReadVarint", nil, offset, err) {
 		return 0
 	}
 
 	return t
 }
 
+// decoderByteReader reads single bytes for the varint decoding, without noting errors.
+type decoderByteReader Decoder
+
+func (d *decoderByteReader) ReadByte() (byte, error) {
+	tmp := d.buf8[:1]
+	n, err := io.ReadFull(d.in, tmp)
+	d.pos += int64(n)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return tmp[0], nil
+}
+
 // ReadUTF8 provides a type safe conversion to avoid another heap allocation for the
 // returned string.
 func (r *Decoder) ReadUTF8(order ByteOrder, p IntSize) string {
-	tmp := r.ReadBlob(order, p) // do not change tmp anymore
+	tmp := r.readBlob("ReadUTF8", order, p, 0) // do not change tmp anymore
+	// this hack avoids another allocation for the string, see https://github.com/golang/go/issues/25484
+	return *(*string)(unsafe.Pointer(&tmp))
+}
+
+// ReadUTF8Max is like ReadUTF8 but refuses to allocate more than max bytes, if max is not 0.
+func (r *Decoder) ReadUTF8Max(order ByteOrder, p IntSize, max int) string {
+	tmp := r.readBlob("ReadUTF8Max", order, p, max) // do not change tmp anymore
 	// this hack avoids another allocation for the string, see https://github.com/golang/go/issues/25484
 	return *(*string)(unsafe.Pointer(&tmp))
 }
 
 // ReadBool reads one byte and returns 0 if the byte is zero, otherwise true
 func (r *Decoder) ReadBool() bool {
-	return r.ReadUint8() != 0
+	return r.readUint8("ReadBool") != 0
 }
 
 // ReadInt16 reads 2 bytes and interprets them as signed
 func (r *Decoder) ReadInt16(order ByteOrder) int16 {
-	return int16(r.ReadUint16(order))
+	return int16(r.readUint16("ReadInt16", order))
 }
 
 // ReadInt24 reads 3 bytes and interprets them as signed
 func (r *Decoder) ReadInt24(order ByteOrder) int32 {
-	return int32(r.ReadUint24(order))
+	return int32(r.readUint24("ReadInt24", order)<<8) >> 8
 }
 
 // ReadInt32 reads 4 bytes and interprets them as signed
 func (r *Decoder) ReadInt32(order ByteOrder) int32 {
-	return int32(r.ReadUint32(order))
+	return int32(r.readUint32("ReadInt32", order))
 }
 
 // ReadInt40 reads 5 bytes and interprets them as signed
 func (r *Decoder) ReadInt40(order ByteOrder) int64 {
-	return int64(r.ReadUint32(order))
+	return int64(r.readUint40("ReadInt40", order)<<24) >> 24
 }
 
 // ReadInt48 reads 6 bytes and interprets them as signed
 func (r *Decoder) ReadInt48(order ByteOrder) int64 {
-	return int64(r.ReadUint32(order))
+	return int64(r.readUint48("ReadInt48", order)<<16) >> 16
 }
 
 // ReadInt56 reads 7 bytes and interprets them as signed
 func (r *Decoder) ReadInt56(order ByteOrder) int64 {
-	return int64(r.ReadUint32(order))
+	return int64(r.readUint56("ReadInt56", order)<<8) >> 8
 }
 
-// ReadInt64 reads 7 bytes and interprets them as signed
+// ReadInt64 reads 8 bytes and interprets them as signed
 func (r *Decoder) ReadInt64(order ByteOrder) int64 {
-	return int64(r.ReadUint64(order))
+	return int64(r.readUint64("ReadInt64", order))
 }
 
 // ReadUint16 reads 2 bytes and interprets them as unsigned
 func (r *Decoder) ReadUint16(order ByteOrder) uint16 {
-	if r.quickFail() {
-		return 0
-	}
-
-	tmp := r.buf8[:2]
-	_, err := io.ReadFull(r.in, tmp)
-
-	if r.noteErr(err) {
-		return 0
-	}
-
-	return order.Uint16(tmp)
+	return r.readUint16("ReadUint16", order)
 }
 
 // ReadUint24 reads 3 bytes and interprets them as unsigned
 func (r *Decoder) ReadUint24(order ByteOrder) uint32 {
-	if r.quickFail() {
-		return 0
-	}
+	return r.readUint24("ReadUint24", order)
+}
 
-	tmp := r.buf8[:3]
-	_, err := io.ReadFull(r.in, tmp)
+// ReadUint32 reads 4 bytes and interprets them as unsigned
+func (r *Decoder) ReadUint32(order ByteOrder) uint32 {
+	return r.readUint32("ReadUint32", order)
+}
 
-	if r.noteErr(err) {
-		return 0
-	}
+// ReadUint40 reads 5 bytes and interprets them as unsigned
+func (r *Decoder) ReadUint40(order ByteOrder) uint64 {
+	return r.readUint40("ReadUint40", order)
+}
 
-	return order.Uint24(tmp)
+// ReadUint48 reads 6 bytes and interprets them as unsigned
+func (r *Decoder) ReadUint48(order ByteOrder) uint64 {
+	return r.readUint48("ReadUint48", order)
 }
 
-// ReadUint32 reads 4 bytes and interprets them as unsigned
-func (r *Decoder) ReadUint32(order ByteOrder) uint32 {
+// ReadUint56 reads 7 bytes and interprets them as unsigned
+func (r *Decoder) ReadUint56(order ByteOrder) uint64 {
+	return r.readUint56("ReadUint56", order)
+}
+
+// ReadUint64 reads 8 bytes and interprets them as unsigned
+func (r *Decoder) ReadUint64(order ByteOrder) uint64 {
+	return r.readUint64("ReadUint64", order)
+}
+
+// fill reads exactly n bytes into the internal buffer and returns nil, if the operation op failed.
+func (r *Decoder) fill(op string, order ByteOrder, n int) []byte {
 	if r.quickFail() {
-		return 0
+		return nil
 	}
 
-	tmp := r.buf8[:4]
-	_, err := io.ReadFull(r.in, tmp)
+	offset := r.pos
+	tmp := r.buf8[:n]
+	read, err := io.ReadFull(r.in, tmp)
+	r.pos += int64(read)
 
-	if r.noteErr(err) {
-		return 0
+	if r.noteErr(op, order, offset, err) {
+		return nil
 	}
 
-	return order.Uint32(tmp)
+	return tmp
 }
 
-// ReadUint40 reads 5 bytes and interprets them as unsigned
-func (r *Decoder) ReadUint40(order ByteOrder) uint64 {
-	if r.quickFail() {
-		return 0
+func (r *Decoder) readUint8(op string) uint8 {
+	if tmp := r.fill(op, nil, 1); tmp != nil {
+		return tmp[0]
 	}
 
-	tmp := r.buf8[:5]
-	_, err := io.ReadFull(r.in, tmp)
+	return 0
+}
 
-	if r.noteErr(err) {
-		return 0
+func (r *Decoder) readUint16(op string, order ByteOrder) uint16 {
+	if tmp := r.fill(op, order, 2); tmp != nil {
+		return order.Uint16(tmp)
 	}
 
-	return order.Uint40(tmp)
+	return 0
 }
 
-// ReadUint48 reads 6 bytes and interprets them as unsigned
-func (r *Decoder) ReadUint48(order ByteOrder) uint64 {
-	if r.quickFail() {
-		return 0
+func (r *Decoder) readUint24(op string, order ByteOrder) uint32 {
+	if tmp := r.fill(op, order, 3); tmp != nil {
+		return order.Uint24(tmp)
 	}
 
-	tmp := r.buf8[:6]
-	_, err := io.ReadFull(r.in, tmp)
+	return 0
+}
 
-	if r.noteErr(err) {
-		return 0
+func (r *Decoder) readUint32(op string, order ByteOrder) uint32 {
+	if tmp := r.fill(op, order, 4); tmp != nil {
+		return order.Uint32(tmp)
 	}
 
-	return order.Uint48(tmp)
+	return 0
 }
 
-// ReadUint56 reads 7 bytes and interprets them as unsigned
-func (r *Decoder) ReadUint56(order ByteOrder) uint64 {
-	if r.quickFail() {
-		return 0
+func (r *Decoder) readUint40(op string, order ByteOrder) uint64 {
+	if tmp := r.fill(op, order, 5); tmp != nil {
+		return order.Uint40(tmp)
 	}
 
-	tmp := r.buf8[:7]
-	_, err := io.ReadFull(r.in, tmp)
+	return 0
+}
 
-	if r.noteErr(err) {
-		return 0
+func (r *Decoder) readUint48(op string, order ByteOrder) uint64 {
+	if tmp := r.fill(op, order, 6); tmp != nil {
+		return order.Uint48(tmp)
 	}
 
-	return order.Uint56(tmp)
+	return 0
 }
 
-// ReadUint64 reads 8 bytes and interprets them as unsigned
-func (r *Decoder) ReadUint64(order ByteOrder) uint64 {
-	if r.quickFail() {
-		return 0
+func (r *Decoder) readUint56(op string, order ByteOrder) uint64 {
+	if tmp := r.fill(op, order, 7); tmp != nil {
+		return order.Uint56(tmp)
 	}
 
-	_, err := io.ReadFull(r.in, r.buf8)
-	if r.noteErr(err) {
-		return 0
+	return 0
+}
+
+func (r *Decoder) readUint64(op string, order ByteOrder) uint64 {
+	if tmp := r.fill(op, order, 8); tmp != nil {
+		return order.Uint64(tmp)
 	}
 
-	return order.Uint64(r.buf8)
+	return 0
 }
 
 // ReadFull reads exactly len(b) bytes. If an error occurs returns the number of read bytes.
 func (r *Decoder) ReadFull(b []byte) int {
+	return r.readFull("ReadFull", nil, b)
+}
+
+func (r *Decoder) readFull(op string, order ByteOrder, b []byte) int {
+	offset := r.pos
 	n, err := io.ReadFull(r.in, b)
-	if r.noteErr(err) {
-		return n
-	}
+	r.pos += int64(n)
+	r.noteErr(op, order, offset, err)
 
 	return n
 }
 
 // ReadUint8 reads one byte
 func (r *Decoder) ReadUint8() uint8 {
-	if r.quickFail() {
-		return 0
-	}
-
-	tmp := r.buf8[:1]
-	_, err := io.ReadFull(r.in, tmp)
-
-	if r.noteErr(err) {
-		return 0
-	}
-
-	return tmp[0]
+	return r.readUint8("ReadUint8")
 }
 
 // ReadInt8 reads one byte
 func (r *Decoder) ReadInt8() int8 {
-	return int8(r.ReadUint8())
+	return int8(r.readUint8("ReadInt8"))
 }
 
-// ReadByte reads one byte
+// ReadByte reads one byte. In contrast to the other methods, the returned error is not wrapped.
 func (r *Decoder) ReadByte() (byte, error) {
 	if r.quickFail() {
-		return 0, r.firstErr
+		return 0, r.cause()
 	}
 
+	offset := r.pos
 	tmp := r.buf8[:1]
-	_, err := io.ReadFull(r.in, tmp)
+	n, err := io.ReadFull(r.in, tmp)
+	r.pos += int64(n)
 
-	if r.noteErr(err) {
+	if r.noteErr("ReadByte", nil, offset, err) {
 		return 0, err
 	}
 
 	return tmp[0], nil
 }
 
-// Directly delegates the read
+// Directly delegates the read. In contrast to the other methods, the returned error is not wrapped.
 func (r *Decoder) Read(buf []byte) (int, error) {
 	if r.quickFail() {
-		return 0, r.firstErr
+		return 0, r.cause()
 	}
 
+	offset := r.pos
 	n, err := r.in.Read(buf)
-	r.noteErr(err)
+	r.pos += int64(n)
+	r.noteErr("Read", nil, offset, err)
 
 	return n, err
 }
 
 // ReadFloat64 reads 8 bytes and interprets them as a float64 IEEE 754 4 byte bit sequence.
 func (r *Decoder) ReadFloat64(order ByteOrder) float64 {
-	bits := r.ReadUint64(order)
+	bits := r.readUint64("ReadFloat64", order)
 	return math.Float64frombits(bits)
 }
 
 // ReadFloat32 reads 4 bytes and interprets them as a float32 IEEE 754 4 byte bit sequence.
 func (r *Decoder) ReadFloat32(order ByteOrder) float32 {
-	bits := r.ReadUint32(order)
+	bits := r.readUint32("ReadFloat32", order)
 	return math.Float32frombits(bits)
 }
 
 // ReadComplex64 reads two float32 IEEE 754 4 byte bit sequences for the real and imaginary parts.
 func (r *Decoder) ReadComplex64(order ByteOrder) complex64 {
-	rnum := r.ReadFloat32(order)
-	inum := r.ReadFloat32(order)
+	rnum := math.Float32frombits(r.readUint32("ReadComplex64", order))
+	inum := math.Float32frombits(r.readUint32("ReadComplex64", order))
 
 	return complex(rnum, inum)
 }
 
 // ReadComplex128 reads two float64 IEEE 754 8 byte bit sequences for the real and imaginary parts.
 func (r *Decoder) ReadComplex128(order ByteOrder) complex128 {
-	rnum := r.ReadFloat64(order)
-	inum := r.ReadFloat64(order)
+	rnum := math.Float64frombits(r.readUint64("ReadComplex128", order))
+	inum := math.Float64frombits(r.readUint64("ReadComplex128", order))
 
 	return complex(rnum, inum)
 }
 
-func (r *Decoder) noteErr(err error) bool {
+// noteErr records the first error as a *DecodeError and returns true, if any error has been recorded.
+func (r *Decoder) noteErr(op string, order ByteOrder, offset int64, err error) bool {
 	if err != nil && r.firstErr == nil {
+		if _, ok := err.(*DecodeError); !ok {
+			err = &DecodeError{Offset: offset, Op: op, Order: order, Err: err}
+		}
+
 		r.firstErr = err
 	}
 
@@ -398,6 +539,23 @@ func (r *Decoder) noteErr(err error) bool {
 	return false
 }
 
+// unexpectedEOF replaces a recorded io.EOF by io.ErrUnexpectedEOF, e.g. because a value is missing in the middle
+// of a structure.
+func (r *Decoder) unexpectedEOF() {
+	if err, ok := r.firstErr.(*DecodeError); ok && err.Err == io.EOF {
+		err.Err = io.ErrUnexpectedEOF
+	}
+}
+
+// cause returns the unwrapped first error.
+func (r *Decoder) cause() error {
+	if err, ok := r.firstErr.(*DecodeError); ok {
+		return err.Err
+	}
+
+	return r.firstErr
+}
+
 // Error returns the first occurred error. Each call to any Read* method may cause an error.
 func (r *Decoder) Error() error {
 	return r.firstErr
@@ -0,0 +1,125 @@
+package ioutil
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDecoder_Limits(t *testing.T) {
+	buf := &bytes.Buffer{}
+	dout := NewDataOutput(LittleEndian, buf)
+	dout.WriteBlob(I8, []byte{1, 2, 3})
+	dout.WriteUTF8(I16, "hello")
+	dout.WriteUint64(MaxUint64) // hostile I64 prefix
+
+	data := buf.Bytes()
+
+	din := NewLimitedDataInput(LittleEndian, bytes.NewReader(data), Limits{MaxBlobSize: 5})
+	if b := din.ReadBlob(I8); !bytes.Equal(b, []byte{1, 2, 3}) {
+		t.Fatalf("expected %v but got %v", []byte{1, 2, 3}, b)
+	}
+
+	if s := din.ReadUTF8(I16); s != "hello" {
+		t.Fatalf("expected hello but got %s", s)
+	}
+
+	if b := din.ReadBlob(I64); b != nil {
+		t.Fatalf("expected nil but got %d bytes", len(b))
+	}
+
+	if din.Error() == nil {
+		t.Fatal("expected an error")
+	}
+
+	dec := NewDecoder(bytes.NewReader(data), true)
+	dec.ReadBlobMax(LittleEndian, I8, 2)
+
+	var tooLarge BlobTooLarge
+	if !errors.As(dec.Error(), &tooLarge) || tooLarge.Len != 3 || tooLarge.Max != 2 {
+		t.Fatalf("expected BlobTooLarge but got %v", dec.Error())
+	}
+
+	dec = NewDecoder(bytes.NewReader(data), true)
+	dec.SetLimits(Limits{Budget: 7})
+	dec.ReadBlob(LittleEndian, I8)
+	dec.ReadUTF8(LittleEndian, I16)
+
+	var budget BudgetExceeded
+	if !errors.As(dec.Error(), &budget) || budget.Remaining != 4 {
+		t.Fatalf("expected BudgetExceeded but got %v", dec.Error())
+	}
+
+	if dec.Allocated() != 3 {
+		t.Fatalf("expected 3 allocated bytes but got %d", dec.Allocated())
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	dout := NewDataOutput(LittleEndian, buf)
+	dout.WriteUint32(42)
+	dout.WriteUvarint(300)
+	dout.WriteBytes(1, 2, 3)
+
+	din := NewDataInput(LittleEndian, bytes.NewReader(buf.Bytes()))
+	din.ReadUint32()
+	din.ReadUvarint()
+
+	if din.Position() != 6 {
+		t.Fatalf("expected position 6 but got %d", din.Position())
+	}
+
+	din.ReadInt40()
+
+	err := din.Error()
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected unexpected EOF but got %v", err)
+	}
+
+	var decErr *DecodeError
+	if !errors.As(err, &decErr) {
+		t.Fatalf("expected DecodeError but got %T", err)
+	}
+
+	if decErr.Offset != 6 || decErr.Op != "ReadInt40" || decErr.Order != LittleEndian {
+		t.Fatalf("unexpected error details: %+v", decErr)
+	}
+
+	if err.Error() != "ReadInt40 (LittleEndian) at offset 6: unexpected EOF" {
+		t.Fatalf("unexpected message: %s", err.Error())
+	}
+
+	// the io.Reader contract is kept
+	dec := NewDecoder(bytes.NewReader(nil), true)
+	if _, err := dec.ReadByte(); err != io.EOF {
+		t.Fatalf("expected EOF but got %v", err)
+	}
+
+	if _, err := dec.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected EOF but got %v", err)
+	}
+}
+
+func TestEncodeError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf, true)
+	enc.WriteUint16(BigEndian, 1)
+	enc.WriteUTF8(BigEndian, I8, strings.Repeat("a", 256))
+
+	var overflow IntegerOverflow
+	if !errors.As(enc.Error(), &overflow) {
+		t.Fatalf("expected IntegerOverflow but got %v", enc.Error())
+	}
+
+	var encErr *EncodeError
+	if !errors.As(enc.Error(), &encErr) || encErr.Offset != 2 || encErr.Op != "WriteUTF8" {
+		t.Fatalf("unexpected error: %v", enc.Error())
+	}
+
+	if enc.Position() != 2 {
+		t.Fatalf("expected position 2 but got %d", enc.Position())
+	}
+}
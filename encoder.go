@@ -28,11 +28,13 @@ import (
 
 // An Encoder implements various encoding helpers for Little Endian and Big Endian.
 // The implementation reuses an internal buffer to avoid heap allocations and is therefore not thread safe.
+// Any error is wrapped into an *EncodeError, which tells the offset and the failed operation.
 type Encoder struct {
 	buf10       []byte
 	out         io.Writer
 	firstErr    error
 	failOnError bool
+	pos         int64
 }
 
 // NewEncoder allocates a new encoder instance with a shared buffer
@@ -48,6 +50,11 @@ func (e *Encoder) Reset() {
 	e.firstErr = nil
 }
 
+// Position returns the amount of bytes, which have been written to the wrapped writer so far.
+func (e *Encoder) Position() int64 {
+	return e.pos
+}
+
 // quickFail returns true, if an error is already pending and we should not bother the writer again.
 func (e *Encoder) quickFail() bool {
 	return e.failOnError && e.firstErr != nil
@@ -56,19 +63,27 @@ func (e *Encoder) quickFail() bool {
 // WriteBytes just writes the slice out, without any prefix for the length.
 // If an error occurs returns the number of written bytes.
 func (e *Encoder) WriteBytes(v ...byte) int {
-	return e.WriteSlice(v)
+	return e.write("WriteBytes", nil, v)
 }
 
 // WriteSlice just writes the slice out, without any prefix for the length.
 // If an error occurs returns the number of written bytes.
 func (e *Encoder) WriteSlice(v []byte) int {
+	return e.write("WriteSlice", nil, v)
+}
+
+// write is the common implementation of all writing methods and notes any error for the operation op.
+func (e *Encoder) write(op string, o ByteOrder, v []byte) int {
 	if e.quickFail() {
 		return 0
 	}
 
+	offset := e.pos
 	n, err := e.out.Write(v)
-	if e.noteErr(err) || n != len(v) {
-		e.noteErr(fmt.Errorf("writer buffer underrun"))
+	e.pos += int64(n)
+
+	if e.noteErr(op, o, offset, err) || n != len(v) {
+		e.noteErr(op, o, offset, fmt.Errorf("writer buffer underrun"))
 	}
 
 	return n
@@ -76,57 +91,76 @@ func (e *Encoder) WriteSlice(v []byte) int {
 
 // WriteBlob writes a prefixed byte slice of variable length.
 func (e *Encoder) WriteBlob(o ByteOrder, p IntSize, v []byte) {
+	e.writeBlob("WriteBlob", o, p, v)
+}
+
+func (e *Encoder) writeBlob(op string, o ByteOrder, p IntSize, v []byte) {
 	if e.quickFail() {
 		return
 	}
 
+	if !e.writeSize(op, o, p, len(v)) {
+		return
+	}
+
+	e.write(op, o, v)
+}
+
+// WriteSize writes a length prefix, e.g. of a slice, using the given storage class. An IntegerOverflow is
+// recorded, if n does not fit into it.
+func (e *Encoder) WriteSize(o ByteOrder, p IntSize, n int) {
+	e.writeSize("WriteSize", o, p, n)
+}
+
+// writeSize writes the length prefix n using the given storage class and returns false if n overflows it.
+func (e *Encoder) writeSize(op string, o ByteOrder, p IntSize, n int) bool {
 	switch p {
 	case I8:
-		if len(v) > math.MaxUint8 {
-			e.noteErr(IntegerOverflow{Val: len(v), Max: math.MaxUint8})
-			return
+		if n > math.MaxUint8 {
+			e.noteErr(op, o, e.pos, IntegerOverflow{Val: n, Max: math.MaxUint8})
+			return false
 		}
 
-		e.WriteUint8(uint8(len(v)))
+		e.writeUint8(op, uint8(n))
 	case I16:
-		if len(v) > math.MaxUint16 {
-			e.noteErr(IntegerOverflow{Val: len(v), Max: math.MaxUint16})
-			return
+		if n > math.MaxUint16 {
+			e.noteErr(op, o, e.pos, IntegerOverflow{Val: n, Max: math.MaxUint16})
+			return false
 		}
 
-		e.WriteUint16(o, uint16(len(v)))
+		e.writeUint16(op, o, uint16(n))
 	case I24:
-		if uint32(len(v)) > MaxUint24 {
-			e.noteErr(IntegerOverflow{Val: len(v), Max: MaxUint24})
-			return
+		if uint32(n) > MaxUint24 {
+			e.noteErr(op, o, e.pos, IntegerOverflow{Val: n, Max: MaxUint24})
+			return false
 		}
 
-		e.WriteUint24(o, uint32(len(v)))
+		e.writeUint24(op, o, uint32(n))
 	case I32:
-		if len(v) > math.MaxUint32 {
-			e.noteErr(IntegerOverflow{Val: len(v), Max: math.MaxUint32})
-			return
+		if uint64(n) > math.MaxUint32 {
+			e.noteErr(op, o, e.pos, IntegerOverflow{Val: n, Max: uint64(math.MaxUint32)})
+			return false
 		}
 
-		e.WriteUint32(o, uint32(len(v)))
+		e.writeUint32(op, o, uint32(n))
 	case I40:
-		if uint64(len(v)) > MaxUint40 {
-			e.noteErr(IntegerOverflow{Val: len(v), Max: MaxUint40})
-			return
+		if uint64(n) > MaxUint40 {
+			e.noteErr(op, o, e.pos, IntegerOverflow{Val: n, Max: MaxUint40})
+			return false
 		}
 
-		e.WriteUint40(o, uint64(len(v)))
+		e.writeUint40(op, o, uint64(n))
 	case I64:
 		// overflow cannot happen, len is at most positive signed 64 bit value
-		e.WriteUint64(o, uint64(len(v)))
+		e.writeUint64(op, o, uint64(n))
 	case IVar:
 		// overflow cannot happen, len is at most positive signed 64 bit value
-		e.WriteUvarint(uint64(len(v)))
+		e.writeUvarint(op, uint64(n))
 	default:
 		panic("unknown IntSize: " + strconv.Itoa(int(p)))
 	}
 
-	e.WriteSlice(v)
+	return true
 }
 
 // WriteUTF8 writes a prefixed unmodified utf8 string sequence of variable length.
@@ -143,176 +177,222 @@ func (e *Encoder) WriteUTF8(o ByteOrder, p IntSize, v string) {
 		Cap:  str.Len,
 	}))
 
-	e.WriteBlob(o, p, slice)
+	e.writeBlob("WriteUTF8", o, p, slice)
 }
 
 // WriteBool writes one byte.
 func (e *Encoder) WriteBool(v bool) {
 	if v {
-		e.WriteUint8(1) //nolint:gomnd
+		e.writeUint8("WriteBool", 1) //nolint:gomnd
 	} else {
-		e.WriteUint8(0) //nolint:gomnd
+		e.writeUint8("WriteBool", 0) //nolint:gomnd
 	}
 }
 
 // WriteUint8 writes an unsigned byte
 func (e *Encoder) WriteUint8(v uint8) {
-	_ = e.WriteByte(v)
+	e.writeUint8("WriteUint8", v)
 }
 
 // WriteInt8 writes a signed byte
 func (e *Encoder) WriteInt8(v int8) {
-	e.WriteUint8(uint8(v))
+	e.writeUint8("WriteInt8", uint8(v))
 }
 
 // WriteUint16 writes an unsigned 2 byte integer.
 func (e *Encoder) WriteUint16(o ByteOrder, v uint16) {
-	tmp := e.buf10[:2]
-	o.PutUint16(tmp, v)
-	e.WriteSlice(tmp)
+	e.writeUint16("WriteUint16", o, v)
 }
 
 // WriteInt16 writes a signed 2 byte integer.
 func (e *Encoder) WriteInt16(o ByteOrder, v int16) {
-	e.WriteUint16(o, uint16(v))
+	e.writeUint16("WriteInt16", o, uint16(v))
 }
 
 // WriteUint24 writes an unsigned 3 byte integer.
 func (e *Encoder) WriteUint24(o ByteOrder, v uint32) {
-	tmp := e.buf10[:3]
-	o.PutUint24(tmp, v)
-	e.WriteSlice(tmp)
+	e.writeUint24("WriteUint24", o, v)
 }
 
 // WriteInt24 writes a signed 3 byte integer.
 func (e *Encoder) WriteInt24(o ByteOrder, v int32) {
-	e.WriteUint24(o, uint32(v))
+	e.writeUint24("WriteInt24", o, uint32(v))
 }
 
 // WriteUint32 writes an unsigned 4 byte integer.
 func (e *Encoder) WriteUint32(o ByteOrder, v uint32) {
-	tmp := e.buf10[:4]
-	o.PutUint32(tmp, v)
-	e.WriteSlice(tmp)
+	e.writeUint32("WriteUint32", o, v)
 }
 
 // WriteInt32 writes a signed 4 byte integer.
 func (e *Encoder) WriteInt32(o ByteOrder, v int32) {
-	e.WriteUint32(o, uint32(v))
+	e.writeUint32("WriteInt32", o, uint32(v))
 }
 
 // WriteInt40 writes a signed 5 byte integer.
 func (e *Encoder) WriteInt40(o ByteOrder, v int64) {
-	e.WriteUint40(o, uint64(v))
+	e.writeUint40("WriteInt40", o, uint64(v))
 }
 
 // WriteUint40 writes an unsigned 5 byte integer.
 func (e *Encoder) WriteUint40(o ByteOrder, v uint64) {
-	tmp := e.buf10[:5]
-	o.PutUint40(tmp, v)
-	e.WriteSlice(tmp)
+	e.writeUint40("WriteUint40", o, v)
 }
 
 // WriteInt48 writes a signed 6 byte integer.
 func (e *Encoder) WriteInt48(o ByteOrder, v int64) {
-	e.WriteUint48(o, uint64(v))
+	e.writeUint48("WriteInt48", o, uint64(v))
 }
 
 // WriteUint48 writes an unsigned 6 byte integer.
 func (e *Encoder) WriteUint48(o ByteOrder, v uint64) {
-	tmp := e.buf10[:6]
-	o.PutUint48(tmp, v)
-	e.WriteSlice(tmp)
+	e.writeUint48("WriteUint48", o, v)
 }
 
 // WriteInt56 writes a signed 7 byte integer.
 func (e *Encoder) WriteInt56(o ByteOrder, v int64) {
-	e.WriteUint56(o, uint64(v))
+	e.writeUint56("WriteInt56", o, uint64(v))
 }
 
 // WriteUint56 writes an unsigned 7 byte integer.
 func (e *Encoder) WriteUint56(o ByteOrder, v uint64) {
-	tmp := e.buf10[:7]
-	o.PutUint56(tmp, v)
-	e.WriteSlice(tmp)
+	e.writeUint56("WriteUint56", o, v)
 }
 
 // WriteUint64 writes an unsigned 8 byte integer.
 func (e *Encoder) WriteUint64(o ByteOrder, v uint64) {
-	tmp := e.buf10[:8]
-	o.PutUint64(tmp, v)
-	e.WriteSlice(tmp)
+	e.writeUint64("WriteUint64", o, v)
 }
 
 // WriteInt64 writes a signed 8 byte integer.
 func (e *Encoder) WriteInt64(o ByteOrder, v int64) {
-	e.WriteUint64(o, uint64(v))
+	e.writeUint64("WriteInt64", o, uint64(v))
+}
+
+func (e *Encoder) writeUint8(op string, v uint8) {
+	tmp := e.buf10[:1]
+	tmp[0] = v
+	e.write(op, nil, tmp)
+}
+
+func (e *Encoder) writeUint16(op string, o ByteOrder, v uint16) {
+	tmp := e.buf10[:2]
+	o.PutUint16(tmp, v)
+	e.write(op, o, tmp)
+}
+
+func (e *Encoder) writeUint24(op string, o ByteOrder, v uint32) {
+	tmp := e.buf10[:3]
+	o.PutUint24(tmp, v)
+	e.write(op, o, tmp)
+}
+
+func (e *Encoder) writeUint32(op string, o ByteOrder, v uint32) {
+	tmp := e.buf10[:4]
+	o.PutUint32(tmp, v)
+	e.write(op, o, tmp)
+}
+
+func (e *Encoder) writeUint40(op string, o ByteOrder, v uint64) {
+	tmp := e.buf10[:5]
+	o.PutUint40(tmp, v)
+	e.write(op, o, tmp)
+}
+
+func (e *Encoder) writeUint48(op string, o ByteOrder, v uint64) {
+	tmp := e.buf10[:6]
+	o.PutUint48(tmp, v)
+	e.write(op, o, tmp)
+}
+
+func (e *Encoder) writeUint56(op string, o ByteOrder, v uint64) {
+	tmp := e.buf10[:7]
+	o.PutUint56(tmp, v)
+	e.write(op, o, tmp)
+}
+
+func (e *Encoder) writeUint64(op string, o ByteOrder, v uint64) {
+	tmp := e.buf10[:8]
+	o.PutUint64(tmp, v)
+	e.write(op, o, tmp)
 }
 
 // WriteUvarint writes a variable length integer, up to 10 bytes using zig-zag protobuf encoding.
 func (e *Encoder) WriteUvarint(v uint64) {
+	e.writeUvarint("WriteUvarint", v)
+}
+
+func (e *Encoder) writeUvarint(op string, v uint64) {
 	n := binary.PutUvarint(e.buf10, v)
-	e.WriteBytes(e.buf10[:n]...)
+	e.write(op, nil, e.buf10[:n])
 }
 
 // WriteVarint writes a variable length and signed integer, up to 10 bytes using zig-zag protobuf encoding.
 func (e *Encoder) WriteVarint(v int64) {
 	n := binary.PutVarint(e.buf10, v)
-	e.WriteBytes(e.buf10[:n]...)
+	e.write("WriteVarint", nil, e.buf10[:n])
 }
 
 // WriteFloat32 writes a float32 IEEE 754 4 byte bit sequence.
 func (e *Encoder) WriteFloat32(o ByteOrder, v float32) {
-	bits := math.Float32bits(v)
-	e.WriteUint32(o, bits)
+	e.writeUint32("WriteFloat32", o, math.Float32bits(v))
 }
 
 // WriteFloat64 writes a float64 IEEE 754 8 byte bit sequence.
 func (e *Encoder) WriteFloat64(o ByteOrder, v float64) {
-	bits := math.Float64bits(v)
-	e.WriteUint64(o, bits)
+	e.writeUint64("WriteFloat64", o, math.Float64bits(v))
 }
 
 // WriteComplex64 writes two float32 IEEE 754 4 byte bit sequences.
 func (e *Encoder) WriteComplex64(o ByteOrder, v complex64) {
-	e.WriteFloat32(o, real(v))
-	e.WriteFloat32(o, imag(v))
+	e.writeUint32("WriteComplex64", o, math.Float32bits(real(v)))
+	e.writeUint32("WriteComplex64", o, math.Float32bits(imag(v)))
 }
 
 // WriteComplex128 writes two float32 IEEE 754 4 byte bit sequences.
 func (e *Encoder) WriteComplex128(o ByteOrder, v complex128) {
-	e.WriteFloat64(o, real(v))
-	e.WriteFloat64(o, imag(v))
+	e.writeUint64("WriteComplex128", o, math.Float64bits(real(v)))
+	e.writeUint64("WriteComplex128", o, math.Float64bits(imag(v)))
 }
 
-// Write follows the io.Writer contract.
+// Write follows the io.Writer contract. In contrast to the other methods, the returned error is not wrapped.
 func (e *Encoder) Write(p []byte) (int, error) {
 	if e.quickFail() {
-		return 0, e.firstErr
+		return 0, e.cause()
 	}
 
+	offset := e.pos
 	n, err := e.out.Write(p)
-	e.noteErr(err)
+	e.pos += int64(n)
+	e.noteErr("Write", nil, offset, err)
 
 	return n, err
 }
 
-// WriteByte follows the io.ByteWriter contract.
+// WriteByte follows the io.ByteWriter contract. In contrast to the other methods, the returned error is not
+// wrapped.
 func (e *Encoder) WriteByte(c byte) error {
 	if e.quickFail() {
-		return e.firstErr
+		return e.cause()
 	}
 
+	offset := e.pos
 	tmp := e.buf10[:1]
 	tmp[0] = c
-	_, err := e.out.Write(tmp)
-	e.noteErr(err)
+	n, err := e.out.Write(tmp)
+	e.pos += int64(n)
+	e.noteErr("WriteByte", nil, offset, err)
 
 	return err
 }
 
-func (e *Encoder) noteErr(err error) bool {
+// noteErr records the first error as an *EncodeError and returns true, if any error has been recorded.
+func (e *Encoder) noteErr(op string, o ByteOrder, offset int64, err error) bool {
 	if err != nil && e.firstErr == nil {
+		if _, ok := err.(*EncodeError); !ok {
+			err = &EncodeError{Offset: offset, Op: op, Order: o, Err: err}
+		}
+
 		e.firstErr = err
 	}
 
@@ -323,6 +403,15 @@ func (e *Encoder) noteErr(err error) bool {
 	return false
 }
 
+// cause returns the unwrapped first error.
+func (e *Encoder) cause() error {
+	if err, ok := e.firstErr.(*EncodeError); ok {
+		return err.Err
+	}
+
+	return e.firstErr
+}
+
 // Error returns the first occurred error. Each call to any Write* method may cause an error. Per definition,
 // any other call after the first error is a no-op.
 func (e *Encoder) Error() error {
@@ -28,3 +28,126 @@ type IntegerOverflow struct {
 func (i IntegerOverflow) Error() string {
 	return fmt.Sprintf("integer overflow: %d not in [0, %d]", i.Val, i.Max)
 }
+
+// A BlobTooLarge error is returned, if a Decoder refuses to allocate a blob or string, because the decoded length
+// exceeds the configured maximum.
+type BlobTooLarge struct {
+	Len int // Len is the decoded length.
+	Max int // Max is the maximum allowed length.
+}
+
+// Error reports the length/max message
+func (b BlobTooLarge) Error() string {
+	return fmt.Sprintf("blob too large: %d bytes exceed the maximum of %d", b.Len, b.Max)
+}
+
+// A BudgetExceeded error is returned, if a Decoder refuses to allocate a blob or string, because the total budget
+// of allocated bytes would be exceeded.
+type BudgetExceeded struct {
+	Len       int   // Len is the decoded length.
+	Remaining int64 // Remaining is the amount of bytes which are still available.
+	Budget    int64 // Budget is the configured total amount of bytes.
+}
+
+// Error reports the length/remaining message
+func (b BudgetExceeded) Error() string {
+	return fmt.Sprintf("budget exceeded: %d bytes requested but only %d of %d remaining", b.Len, b.Remaining, b.Budget)
+}
+
+// A DecodeError is returned by a Decoder and tells the offset in the stream at which the failed operation
+// has started.
+type DecodeError struct {
+	Offset int64     // Offset is the absolute position at which the failed operation started.
+	Op     string    // Op is the name of the failed operation, e.g. ReadUint32.
+	Order  ByteOrder // Order is the byte order of the operation or nil, if not applicable.
+	Err    error     // Err is the cause, e.g. io.ErrUnexpectedEOF.
+}
+
+// Error reports the operation, offset and cause
+func (e *DecodeError) Error() string {
+	return opError(e.Op, e.Order, e.Offset, e.Err)
+}
+
+// Unwrap returns the cause
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// An EncodeError is returned by an Encoder and tells the offset in the stream at which the failed operation
+// has started.
+type EncodeError struct {
+	Offset int64     // Offset is the absolute position at which the failed operation started.
+	Op     string    // Op is the name of the failed operation, e.g. WriteUint32.
+	Order  ByteOrder // Order is the byte order of the operation or nil, if not applicable.
+	Err    error     // Err is the cause, e.g. an IntegerOverflow.
+}
+
+// Error reports the operation, offset and cause
+func (e *EncodeError) Error() string {
+	return opError(e.Op, e.Order, e.Offset, e.Err)
+}
+
+// Unwrap returns the cause
+func (e *EncodeError) Unwrap() error {
+	return e.Err
+}
+
+func opError(op string, order ByteOrder, offset int64, err error) string {
+	if order == nil {
+		return fmt.Sprintf("%s at offset %d: %v", op, offset, err)
+	}
+
+	return fmt.Sprintf("%s (%s) at offset %d: %v", op, order, offset, err)
+}
+
+// A BufferOverrun is returned by a checked buffer, if a read or write would access bytes beyond the end of the
+// buffer.
+type BufferOverrun struct {
+	Pos     int // Pos is the position at which the access started.
+	Len     int // Len is the amount of bytes, which should have been accessed.
+	Missing int // Missing is the amount of bytes, which are not available.
+}
+
+// Error reports the position/missing message
+func (b BufferOverrun) Error() string {
+	return fmt.Sprintf("buffer overrun: accessing %d bytes at %d misses %d bytes", b.Len, b.Pos, b.Missing)
+}
+
+// A TypeMismatch is returned by a checked typed buffer, if the type prefix is not the expected one.
+type TypeMismatch struct {
+	Pos      int  // Pos is the position of the type prefix.
+	Expected Type // Expected is the type, which has been requested.
+	Actual   Type // Actual is the type, which has been found.
+}
+
+// Error reports the expected/actual message
+func (t TypeMismatch) Error() string {
+	return fmt.Sprintf("type mismatch at %d: expected %s but got %s", t.Pos, t.Expected, t.Actual)
+}
+
+// A ChecksumMismatch is returned by a VerifyingReader at the end of the stream, if the calculated digest is not
+// the expected one.
+type ChecksumMismatch struct {
+	Expected []byte // Expected is the digest, which has been announced.
+	Actual   []byte // Actual is the digest, which has been calculated from the stream.
+}
+
+// Error reports the expected/actual message
+func (c ChecksumMismatch) Error() string {
+	return fmt.Sprintf("checksum mismatch: expected %x but got %x", c.Expected, c.Actual)
+}
+
+// A LengthMismatch is returned by a VerifyingReader, if the stream is shorter or longer than expected.
+type LengthMismatch struct {
+	Expected int64 // Expected is the length, which has been announced.
+	Actual   int64 // Actual is the amount of bytes read so far, which is larger than Expected if the stream is too long.
+}
+
+// Error reports the expected/actual message
+func (l LengthMismatch) Error() string {
+	if l.Actual > l.Expected {
+		return fmt.Sprintf("length mismatch: expected %d bytes but the stream is longer", l.Expected)
+	}
+
+	return fmt.Sprintf("length mismatch: expected %d bytes but got %d", l.Expected, l.Actual)
+}
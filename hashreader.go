@@ -17,6 +17,8 @@
 package ioutil
 
 import (
+	"encoding"
+	"encoding/binary"
 	"fmt"
 	"hash"
 	"io"
@@ -39,6 +41,7 @@ func NewHashReader(h hash.Hash, reader io.Reader) *HashReader {
 func (h *HashReader) Read(p []byte) (n int, err error) {
 	n, err = h.reader.Read(p)
 	n2, err2 := h.hasher.Write(p[0:n])
+	h.count += uint64(n2)
 
 	if err != nil && err2 != nil {
 		return n, fmt.Errorf("failed to hash: %w", fmt.Errorf("failed to read: %w", err))
@@ -80,3 +83,46 @@ func (h *HashReader) Reset() {
 	h.count = 0
 	h.hasher.Reset()
 }
+
+// MarshalBinary serializes the byte count and the state of the hash, so that the hashing can be resumed later,
+// e.g. after a process restart. The hash must implement encoding.BinaryMarshaler, like the hashes of the
+// standard library do.
+func (h *HashReader) MarshalBinary() ([]byte, error) {
+	marshaler, ok := h.hasher.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("hash %T does not implement encoding.BinaryMarshaler", h.hasher)
+	}
+
+	state, err := marshaler.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal hash: %w", err)
+	}
+
+	buf := make([]byte, 8, 8+len(state))
+	binary.LittleEndian.PutUint64(buf, h.count)
+
+	return append(buf, state...), nil
+}
+
+// UnmarshalBinary restores the byte count and the state of the hash from data, which has been created by
+// MarshalBinary. The hash given to NewHashReader must be of the same kind as the marshalled one and must
+// implement encoding.BinaryUnmarshaler. The reader is not touched, so it must be positioned at Count() by
+// the caller, e.g. by seeking.
+func (h *HashReader) UnmarshalBinary(data []byte) error {
+	unmarshaler, ok := h.hasher.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return fmt.Errorf("hash %T does not implement encoding.BinaryUnmarshaler", h.hasher)
+	}
+
+	if len(data) < 8 {
+		return fmt.Errorf("invalid hash reader state: %w", io.ErrUnexpectedEOF)
+	}
+
+	if err := unmarshaler.UnmarshalBinary(data[8:]); err != nil {
+		return fmt.Errorf("failed to unmarshal hash: %w", err)
+	}
+
+	h.count = binary.LittleEndian.Uint64(data)
+
+	return nil
+}
@@ -3,7 +3,12 @@ package ioutil
 import (
 	"bytes"
 	"crypto/md5" //nolint
+	"crypto/sha256"
 	"encoding/hex"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
 	"reflect"
 	"testing"
 )
@@ -37,3 +42,130 @@ func TestHashingReader(t *testing.T) {
 		t.Fatalf("invalid sum")
 	}
 }
+
+func TestHashingReader_Count(t *testing.T) {
+	reader := NewHashReader(md5.New(), bytes.NewBuffer(make([]byte, 10))) //nolint
+	din := NewDataInput(LittleEndian, reader)
+	din.ReadUint32()
+	din.ReadUint16()
+
+	if reader.Count() != 6 {
+		t.Fatalf("expected 6 but got %d", reader.Count())
+	}
+
+	reader.Reset()
+
+	if reader.Count() != 0 {
+		t.Fatalf("expected 0 but got %d", reader.Count())
+	}
+}
+
+func TestHashWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writer := NewHashWriter(sha256.New(), buf)
+	dout := NewDataOutput(BigEndian, writer)
+	dout.WriteUTF8(I16, "hello world")
+	dout.WriteUint40(42)
+
+	if dout.Error() != nil {
+		t.Fatal(dout.Error())
+	}
+
+	expected := sha256.Sum256(buf.Bytes())
+	if !bytes.Equal(writer.Sum(), expected[:]) {
+		t.Fatalf("expected \n%x\n but got \n%x", expected, writer.Sum())
+	}
+
+	if writer.Count() != uint64(buf.Len()) {
+		t.Fatalf("expected %d but got %d", buf.Len(), writer.Count())
+	}
+}
+
+func TestMultiHashWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writer := NewMultiHashWriter(buf, sha256.New(), crc32.NewIEEE(), md5.New()) //nolint
+	enc := NewEncoder(writer, true)
+	enc.WriteBlob(LittleEndian, I32, []byte("abc"))
+	enc.WriteFloat64(LittleEndian, 3.5)
+
+	if enc.Error() != nil {
+		t.Fatal(enc.Error())
+	}
+
+	sha := sha256.Sum256(buf.Bytes())
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write(buf.Bytes())
+	md := md5.Sum(buf.Bytes()) //nolint
+
+	for i, expected := range [][]byte{sha[:], crc.Sum(nil), md[:]} {
+		if !bytes.Equal(writer.Sum(i), expected) || !bytes.Equal(writer.Sums()[i], expected) {
+			t.Fatalf("%d: expected \n%x\n but got \n%x", i, expected, writer.Sum(i))
+		}
+	}
+
+	writer.Reset()
+
+	empty := sha256.Sum256(nil)
+	if writer.Count() != 0 || !bytes.Equal(writer.Sum(0), empty[:]) {
+		t.Fatalf("expected a reset state")
+	}
+}
+
+// opaqueHash hides the encoding.BinaryMarshaler of the wrapped hash
+type opaqueHash struct {
+	hash.Hash
+}
+
+func TestHashReader_MarshalBinary(t *testing.T) {
+	src := make([]byte, 100000)
+	for i := range src {
+		src[i] = byte(i * 31)
+	}
+
+	for _, newHash := range []func() hash.Hash{sha256.New, md5.New, func() hash.Hash { return crc32.NewIEEE() }} {
+		single := newHash()
+		_, _ = single.Write(src)
+
+		reader := NewHashReader(newHash(), bytes.NewReader(src))
+		if _, err := io.CopyN(ioutil.Discard, reader, 12345); err != nil {
+			t.Fatal(err)
+		}
+
+		state, err := reader.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// continue with a fresh instance, like after a restart
+		file := bytes.NewReader(src)
+		resumed := NewHashReader(newHash(), file)
+
+		if err := resumed.UnmarshalBinary(state); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := file.Seek(int64(resumed.Count()), io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := io.Copy(ioutil.Discard, resumed); err != nil {
+			t.Fatal(err)
+		}
+
+		if resumed.Count() != uint64(len(src)) {
+			t.Fatalf("expected %d but got %d", len(src), resumed.Count())
+		}
+
+		if !bytes.Equal(resumed.Sum(), single.Sum(nil)) {
+			t.Fatalf("expected \n%x\n but got \n%x", single.Sum(nil), resumed.Sum())
+		}
+	}
+
+	if _, err := NewHashReader(opaqueHash{sha256.New()}, nil).MarshalBinary(); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if err := NewHashReader(sha256.New(), nil).UnmarshalBinary([]byte{1, 2}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"hash"
+	"io"
+)
+
+// A HashWriter calculates for every transferred byte the hash until Sum() is called. It is the counterpart of
+// the HashReader and can be passed e.g. to NewEncoder or NewDataOutput to hash a serialized stream on the fly.
+type HashWriter struct {
+	hasher hash.Hash
+	writer io.Writer
+	count  uint64
+}
+
+// NewHashWriter creates a new instance. The given hash instance is unchanged until the first write.
+func NewHashWriter(h hash.Hash, writer io.Writer) *HashWriter {
+	return &HashWriter{hasher: h, writer: writer}
+}
+
+// Write writes p into the wrapped writer and hashes only those bytes, which have been actually written.
+func (h *HashWriter) Write(p []byte) (n int, err error) {
+	n, err = h.writer.Write(p)
+	_, _ = h.hasher.Write(p[0:n]) // a hash.Hash never returns an error
+	h.count += uint64(n)
+
+	return n, err
+}
+
+// Sum returns the resulting slice.
+// It does not change the underlying hash state.
+func (h *HashWriter) Sum() []byte {
+	return h.hasher.Sum(nil)
+}
+
+// Hash returns the wrapped hasher
+func (h *HashWriter) Hash() hash.Hash {
+	return h.hasher
+}
+
+// Count returns the total amount of written bytes so far.
+func (h *HashWriter) Count() uint64 {
+	return h.count
+}
+
+// Reset sets the internal byte count to 0 and resets the hash
+func (h *HashWriter) Reset() {
+	h.count = 0
+	h.hasher.Reset()
+}
+
+// A MultiHashWriter calculates multiple hashes at once for every transferred byte, e.g. a SHA-256, a CRC32 and
+// a MD5 in a single pass.
+type MultiHashWriter struct {
+	hashers []hash.Hash
+	writer  io.Writer
+	count   uint64
+}
+
+// NewMultiHashWriter creates a new instance. The given hash instances are unchanged until the first write.
+func NewMultiHashWriter(writer io.Writer, hashers ...hash.Hash) *MultiHashWriter {
+	return &MultiHashWriter{hashers: hashers, writer: writer}
+}
+
+// Write writes p into the wrapped writer and hashes only those bytes, which have been actually written.
+func (h *MultiHashWriter) Write(p []byte) (n int, err error) {
+	n, err = h.writer.Write(p)
+	for _, hasher := range h.hashers {
+		_, _ = hasher.Write(p[0:n]) // a hash.Hash never returns an error
+	}
+
+	h.count += uint64(n)
+
+	return n, err
+}
+
+// Sum returns the resulting slice of the i-th hash, in the order given to NewMultiHashWriter.
+// It does not change the underlying hash state.
+func (h *MultiHashWriter) Sum(i int) []byte {
+	return h.hashers[i].Sum(nil)
+}
+
+// Sums returns the resulting slices of all hashes, in the order given to NewMultiHashWriter.
+func (h *MultiHashWriter) Sums() [][]byte {
+	sums := make([][]byte, len(h.hashers))
+	for i, hasher := range h.hashers {
+		sums[i] = hasher.Sum(nil)
+	}
+
+	return sums
+}
+
+// Hash returns the i-th wrapped hasher
+func (h *MultiHashWriter) Hash(i int) hash.Hash {
+	return h.hashers[i]
+}
+
+// Count returns the total amount of written bytes so far.
+func (h *MultiHashWriter) Count() uint64 {
+	return h.count
+}
+
+// Reset sets the internal byte count to 0 and resets all hashes
+func (h *MultiHashWriter) Reset() {
+	h.count = 0
+	for _, hasher := range h.hashers {
+		hasher.Reset()
+	}
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package gentest contains structs with ioutilgen generated codecs, to verify that they are compatible
+// with ioutil.Marshal.
+package gentest
+
+//go:generate go run ../../cmd/ioutilgen -type=Record,Inner,Embedded
+
+// Kind is a named integer type.
+type Kind uint16
+
+// Inner is a nested struct.
+type Inner struct {
+	A uint32 `io:"u24,be"`
+	B string `io:"utf8,I8"`
+}
+
+// Embedded is embedded into the Record.
+type Embedded struct {
+	E uint16 `io:"u24,be"`
+}
+
+// Record covers all supported wire kinds.
+type Record struct {
+	U8      uint8
+	I16     int16
+	K       Kind   `io:"u24"`
+	U24     uint32 `io:"u24,le"`
+	I24     int32  `io:"i24"`
+	U40     uint64 `io:"u40"`
+	I40     int64  `io:"i40"`
+	U48     uint64 `io:"u48,be"`
+	I48     int64  `io:"i48,be"`
+	U56     uint64 `io:"u56"`
+	I56     int64  `io:"i56"`
+	Var     int64  `io:"varint"`
+	UVar    int    `io:"uvarint"`
+	F32     float32
+	F64     float64 `io:"f64,be"`
+	C64     complex64
+	C128    complex128 `io:"c128,be"`
+	Flag    bool
+	Blob    []byte `io:"blob,I16"`
+	Name    string
+	Fixed   [3]uint16 `io:"u24"`
+	Values  []int32   `io:"i40,I8,be"`
+	Inner   Inner
+	Inners  []Inner `io:"I24"`
+	Skipped int     `io:"-"`
+	hidden  int
+	Embedded
+}
@@ -0,0 +1,414 @@
+// Code generated by ioutilgen. DO NOT EDIT.
+
+package gentest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+	"unsafe"
+
+	"github.com/worldiety/ioutil"
+)
+
+// EncodeTo writes all fields using the given Encoder.
+func (v *Record) EncodeTo(e *ioutil.Encoder) {
+	e.WriteUint8(v.U8)
+	e.WriteInt16(ioutil.LittleEndian, v.I16)
+	e.WriteUint24(ioutil.LittleEndian, uint32(v.K))
+	e.WriteUint24(ioutil.LittleEndian, v.U24)
+	e.WriteInt24(ioutil.LittleEndian, v.I24)
+	e.WriteUint40(ioutil.LittleEndian, v.U40)
+	e.WriteInt40(ioutil.LittleEndian, v.I40)
+	e.WriteUint48(ioutil.BigEndian, v.U48)
+	e.WriteInt48(ioutil.BigEndian, v.I48)
+	e.WriteUint56(ioutil.LittleEndian, v.U56)
+	e.WriteInt56(ioutil.LittleEndian, v.I56)
+	e.WriteVarint(v.Var)
+	e.WriteUvarint(uint64(v.UVar))
+	e.WriteFloat32(ioutil.LittleEndian, v.F32)
+	e.WriteFloat64(ioutil.BigEndian, v.F64)
+	e.WriteComplex64(ioutil.LittleEndian, v.C64)
+	e.WriteComplex128(ioutil.BigEndian, v.C128)
+	e.WriteBool(v.Flag)
+	e.WriteBlob(ioutil.LittleEndian, ioutil.I16, v.Blob)
+	e.WriteUTF8(ioutil.LittleEndian, ioutil.IVar, v.Name)
+	for i1 := range v.Fixed {
+		e.WriteUint24(ioutil.LittleEndian, uint32(v.Fixed[i1]))
+	}
+	e.WriteSize(ioutil.BigEndian, ioutil.I8, len(v.Values))
+	for i2 := range v.Values {
+		e.WriteInt40(ioutil.BigEndian, int64(v.Values[i2]))
+	}
+	e.WriteUint24(ioutil.BigEndian, v.Inner.A)
+	e.WriteUTF8(ioutil.LittleEndian, ioutil.I8, v.Inner.B)
+	e.WriteSize(ioutil.LittleEndian, ioutil.I24, len(v.Inners))
+	for i3 := range v.Inners {
+		e.WriteUint24(ioutil.BigEndian, v.Inners[i3].A)
+		e.WriteUTF8(ioutil.LittleEndian, ioutil.I8, v.Inners[i3].B)
+	}
+	e.WriteUint24(ioutil.BigEndian, uint32(v.Embedded.E))
+}
+
+// DecodeFrom reads all fields using the given Decoder.
+func (v *Record) DecodeFrom(d *ioutil.Decoder) {
+	v.U8 = d.ReadUint8()
+	v.I16 = d.ReadInt16(ioutil.LittleEndian)
+	v.K = Kind(d.ReadUint24(ioutil.LittleEndian))
+	v.U24 = d.ReadUint24(ioutil.LittleEndian)
+	v.I24 = d.ReadInt24(ioutil.LittleEndian)
+	v.U40 = d.ReadUint40(ioutil.LittleEndian)
+	v.I40 = d.ReadInt40(ioutil.LittleEndian)
+	v.U48 = d.ReadUint48(ioutil.BigEndian)
+	v.I48 = d.ReadInt48(ioutil.BigEndian)
+	v.U56 = d.ReadUint56(ioutil.LittleEndian)
+	v.I56 = d.ReadInt56(ioutil.LittleEndian)
+	v.Var = d.ReadVarint()
+	v.UVar = int(d.ReadUvarint())
+	v.F32 = d.ReadFloat32(ioutil.LittleEndian)
+	v.F64 = d.ReadFloat64(ioutil.BigEndian)
+	v.C64 = d.ReadComplex64(ioutil.LittleEndian)
+	v.C128 = d.ReadComplex128(ioutil.BigEndian)
+	v.Flag = d.ReadBool()
+	v.Blob = d.ReadBlob(ioutil.LittleEndian, ioutil.I16)
+	v.Name = d.ReadUTF8(ioutil.LittleEndian, ioutil.IVar)
+	for i1 := range v.Fixed {
+		v.Fixed[i1] = uint16(d.ReadUint24(ioutil.LittleEndian))
+	}
+	n3 := d.ReadSize(ioutil.BigEndian, ioutil.I8, int(unsafe.Sizeof(v.Values[0])))
+	v.Values = make([]int32, 0)
+	for i2 := 0; i2 < n3 && d.Error() == nil; i2++ {
+		var e4 int32
+		e4 = int32(d.ReadInt40(ioutil.BigEndian))
+		v.Values = append(v.Values, e4)
+	}
+	v.Inner.A = d.ReadUint24(ioutil.BigEndian)
+	v.Inner.B = d.ReadUTF8(ioutil.LittleEndian, ioutil.I8)
+	n6 := d.ReadSize(ioutil.LittleEndian, ioutil.I24, int(unsafe.Sizeof(v.Inners[0])))
+	v.Inners = make([]Inner, 0)
+	for i5 := 0; i5 < n6 && d.Error() == nil; i5++ {
+		var e7 Inner
+		e7.A = d.ReadUint24(ioutil.BigEndian)
+		e7.B = d.ReadUTF8(ioutil.LittleEndian, ioutil.I8)
+		v.Inners = append(v.Inners, e7)
+	}
+	v.Embedded.E = uint16(d.ReadUint24(ioutil.BigEndian))
+}
+
+// EncodeToBuffer writes all fields at the current buffer position, according to its WriteMode, and returns
+// an IntegerOverflow, if a length does not fit into its prefix.
+func (v *Record) EncodeToBuffer(b *ioutil.LittleEndianBuffer) error {
+	b.WriteUint8(v.U8)
+	b.WriteUint16(uint16(v.I16))
+	b.WriteUint24(uint32(v.K))
+	b.WriteUint24(v.U24)
+	b.WriteUint24(uint32(v.I24))
+	b.WriteUint40(v.U40)
+	b.WriteUint40(uint64(v.I40))
+	b.WriteUint48(bits.ReverseBytes64(v.U48) >> 16)
+	b.WriteUint48(bits.ReverseBytes64(uint64(v.I48)) >> 16)
+	b.WriteUint56(v.U56)
+	b.WriteUint56(uint64(v.I56))
+	b.WriteVarint(v.Var)
+	b.WriteUvarint(uint64(v.UVar))
+	b.WriteFloat32(v.F32)
+	b.WriteUint64(bits.ReverseBytes64(math.Float64bits(v.F64)))
+	b.WriteFloat32(real(v.C64))
+	b.WriteFloat32(imag(v.C64))
+	b.WriteUint64(bits.ReverseBytes64(math.Float64bits(real(v.C128))))
+	b.WriteUint64(bits.ReverseBytes64(math.Float64bits(imag(v.C128))))
+	if v.Flag {
+		b.WriteUint8(1)
+	} else {
+		b.WriteUint8(0)
+	}
+	if uint64(len(v.Blob)) > uint64(math.MaxUint16) {
+		return ioutil.IntegerOverflow{Val: len(v.Blob), Max: math.MaxUint16}
+	}
+	b.WriteUint16(uint16(len(v.Blob)))
+	b.WriteSlice(v.Blob)
+	b.WriteUvarint(uint64(len(v.Name)))
+	b.WriteStringBytes(v.Name)
+	for i1 := range v.Fixed {
+		b.WriteUint24(uint32(v.Fixed[i1]))
+	}
+	if uint64(len(v.Values)) > uint64(math.MaxUint8) {
+		return ioutil.IntegerOverflow{Val: len(v.Values), Max: math.MaxUint8}
+	}
+	b.WriteUint8(uint8(len(v.Values)))
+	for i2 := range v.Values {
+		b.WriteUint40(bits.ReverseBytes64(uint64(v.Values[i2])) >> 24)
+	}
+	b.WriteUint24(bits.ReverseBytes32(v.Inner.A) >> 8)
+	if uint64(len(v.Inner.B)) > uint64(math.MaxUint8) {
+		return ioutil.IntegerOverflow{Val: len(v.Inner.B), Max: math.MaxUint8}
+	}
+	b.WriteUint8(uint8(len(v.Inner.B)))
+	b.WriteStringBytes(v.Inner.B)
+	if uint64(len(v.Inners)) > uint64(ioutil.MaxUint24) {
+		return ioutil.IntegerOverflow{Val: len(v.Inners), Max: ioutil.MaxUint24}
+	}
+	b.WriteUint24(uint32(len(v.Inners)))
+	for i3 := range v.Inners {
+		b.WriteUint24(bits.ReverseBytes32(v.Inners[i3].A) >> 8)
+		if uint64(len(v.Inners[i3].B)) > uint64(math.MaxUint8) {
+			return ioutil.IntegerOverflow{Val: len(v.Inners[i3].B), Max: math.MaxUint8}
+		}
+		b.WriteUint8(uint8(len(v.Inners[i3].B)))
+		b.WriteStringBytes(v.Inners[i3].B)
+	}
+	b.WriteUint24(bits.ReverseBytes32(uint32(v.Embedded.E)) >> 8)
+	return nil
+}
+
+// DecodeFromBuffer reads all fields from the current buffer position. A truncated or malformed buffer results
+// in an error.
+func (v *Record) DecodeFromBuffer(b *ioutil.LittleEndianBuffer) error {
+	if len(b.Bytes)-b.Pos < 1 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 1, Missing: 1 - (len(b.Bytes) - b.Pos)}
+	}
+	v.U8 = b.ReadUint8()
+	if len(b.Bytes)-b.Pos < 2 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 2, Missing: 2 - (len(b.Bytes) - b.Pos)}
+	}
+	v.I16 = int16(b.ReadUint16())
+	if len(b.Bytes)-b.Pos < 3 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 3, Missing: 3 - (len(b.Bytes) - b.Pos)}
+	}
+	v.K = Kind(b.ReadUint24())
+	if len(b.Bytes)-b.Pos < 3 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 3, Missing: 3 - (len(b.Bytes) - b.Pos)}
+	}
+	v.U24 = b.ReadUint24()
+	if len(b.Bytes)-b.Pos < 3 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 3, Missing: 3 - (len(b.Bytes) - b.Pos)}
+	}
+	v.I24 = int32(b.ReadUint24()<<8) >> 8
+	if len(b.Bytes)-b.Pos < 5 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 5, Missing: 5 - (len(b.Bytes) - b.Pos)}
+	}
+	v.U40 = b.ReadUint40()
+	if len(b.Bytes)-b.Pos < 5 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 5, Missing: 5 - (len(b.Bytes) - b.Pos)}
+	}
+	v.I40 = int64(b.ReadUint40()<<24) >> 24
+	if len(b.Bytes)-b.Pos < 6 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 6, Missing: 6 - (len(b.Bytes) - b.Pos)}
+	}
+	v.U48 = bits.ReverseBytes64(b.ReadUint48()) >> 16
+	if len(b.Bytes)-b.Pos < 6 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 6, Missing: 6 - (len(b.Bytes) - b.Pos)}
+	}
+	v.I48 = int64((bits.ReverseBytes64(b.ReadUint48())>>16)<<16) >> 16
+	if len(b.Bytes)-b.Pos < 7 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 7, Missing: 7 - (len(b.Bytes) - b.Pos)}
+	}
+	v.U56 = b.ReadUint56()
+	if len(b.Bytes)-b.Pos < 7 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 7, Missing: 7 - (len(b.Bytes) - b.Pos)}
+	}
+	v.I56 = int64(b.ReadUint56()<<8) >> 8
+	v1, n2 := binary.Varint(b.Bytes[b.Pos:])
+	if n2 == 0 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: len(b.Bytes) - b.Pos + 1, Missing: 1}
+	}
+	if n2 < 0 {
+		return fmt.Errorf("malformed varint at offset %d", b.Pos)
+	}
+	b.Pos += n2
+	v.Var = v1
+	v3, n4 := binary.Uvarint(b.Bytes[b.Pos:])
+	if n4 == 0 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: len(b.Bytes) - b.Pos + 1, Missing: 1}
+	}
+	if n4 < 0 {
+		return fmt.Errorf("malformed varint at offset %d", b.Pos)
+	}
+	b.Pos += n4
+	v.UVar = int(v3)
+	if len(b.Bytes)-b.Pos < 4 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 4, Missing: 4 - (len(b.Bytes) - b.Pos)}
+	}
+	v.F32 = b.ReadFloat32()
+	if len(b.Bytes)-b.Pos < 8 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 8, Missing: 8 - (len(b.Bytes) - b.Pos)}
+	}
+	v.F64 = math.Float64frombits(bits.ReverseBytes64(b.ReadUint64()))
+	if len(b.Bytes)-b.Pos < 4 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 4, Missing: 4 - (len(b.Bytes) - b.Pos)}
+	}
+	re5 := b.ReadFloat32()
+	if len(b.Bytes)-b.Pos < 4 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 4, Missing: 4 - (len(b.Bytes) - b.Pos)}
+	}
+	im6 := b.ReadFloat32()
+	v.C64 = complex(re5, im6)
+	if len(b.Bytes)-b.Pos < 8 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 8, Missing: 8 - (len(b.Bytes) - b.Pos)}
+	}
+	re7 := math.Float64frombits(bits.ReverseBytes64(b.ReadUint64()))
+	if len(b.Bytes)-b.Pos < 8 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 8, Missing: 8 - (len(b.Bytes) - b.Pos)}
+	}
+	im8 := math.Float64frombits(bits.ReverseBytes64(b.ReadUint64()))
+	v.C128 = complex(re7, im8)
+	if len(b.Bytes)-b.Pos < 1 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 1, Missing: 1 - (len(b.Bytes) - b.Pos)}
+	}
+	v.Flag = b.ReadUint8() != 0
+	if len(b.Bytes)-b.Pos < 2 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 2, Missing: 2 - (len(b.Bytes) - b.Pos)}
+	}
+	n9 := int(b.ReadUint16())
+	if n9 < 0 || n9 > len(b.Bytes)-b.Pos {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: n9, Missing: n9 - (len(b.Bytes) - b.Pos)}
+	}
+	v.Blob = make([]byte, n9)
+	b.ReadSlice(v.Blob)
+	v11, n12 := binary.Uvarint(b.Bytes[b.Pos:])
+	if n12 == 0 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: len(b.Bytes) - b.Pos + 1, Missing: 1}
+	}
+	if n12 < 0 {
+		return fmt.Errorf("malformed varint at offset %d", b.Pos)
+	}
+	b.Pos += n12
+	n10 := int(v11)
+	if n10 < 0 || n10 > len(b.Bytes)-b.Pos {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: n10, Missing: n10 - (len(b.Bytes) - b.Pos)}
+	}
+	v.Name = string(b.Bytes[b.Pos : b.Pos+n10])
+	b.Pos += n10
+	for i13 := range v.Fixed {
+		if len(b.Bytes)-b.Pos < 3 {
+			return ioutil.BufferOverrun{Pos: b.Pos, Len: 3, Missing: 3 - (len(b.Bytes) - b.Pos)}
+		}
+		v.Fixed[i13] = uint16(b.ReadUint24())
+	}
+	if len(b.Bytes)-b.Pos < 1 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 1, Missing: 1 - (len(b.Bytes) - b.Pos)}
+	}
+	n15 := int(b.ReadUint8())
+	if n15 < 0 || n15 > len(b.Bytes)-b.Pos {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: n15, Missing: n15 - (len(b.Bytes) - b.Pos)}
+	}
+	v.Values = make([]int32, n15)
+	for i14 := 0; i14 < n15; i14++ {
+		if len(b.Bytes)-b.Pos < 5 {
+			return ioutil.BufferOverrun{Pos: b.Pos, Len: 5, Missing: 5 - (len(b.Bytes) - b.Pos)}
+		}
+		v.Values[i14] = int32(int64((bits.ReverseBytes64(b.ReadUint40())>>24)<<24) >> 24)
+	}
+	if len(b.Bytes)-b.Pos < 3 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 3, Missing: 3 - (len(b.Bytes) - b.Pos)}
+	}
+	v.Inner.A = bits.ReverseBytes32(b.ReadUint24()) >> 8
+	if len(b.Bytes)-b.Pos < 1 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 1, Missing: 1 - (len(b.Bytes) - b.Pos)}
+	}
+	n16 := int(b.ReadUint8())
+	if n16 < 0 || n16 > len(b.Bytes)-b.Pos {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: n16, Missing: n16 - (len(b.Bytes) - b.Pos)}
+	}
+	v.Inner.B = string(b.Bytes[b.Pos : b.Pos+n16])
+	b.Pos += n16
+	if len(b.Bytes)-b.Pos < 3 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 3, Missing: 3 - (len(b.Bytes) - b.Pos)}
+	}
+	n18 := int(b.ReadUint24())
+	if n18 < 0 || n18 > len(b.Bytes)-b.Pos {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: n18, Missing: n18 - (len(b.Bytes) - b.Pos)}
+	}
+	v.Inners = make([]Inner, n18)
+	for i17 := 0; i17 < n18; i17++ {
+		if len(b.Bytes)-b.Pos < 3 {
+			return ioutil.BufferOverrun{Pos: b.Pos, Len: 3, Missing: 3 - (len(b.Bytes) - b.Pos)}
+		}
+		v.Inners[i17].A = bits.ReverseBytes32(b.ReadUint24()) >> 8
+		if len(b.Bytes)-b.Pos < 1 {
+			return ioutil.BufferOverrun{Pos: b.Pos, Len: 1, Missing: 1 - (len(b.Bytes) - b.Pos)}
+		}
+		n19 := int(b.ReadUint8())
+		if n19 < 0 || n19 > len(b.Bytes)-b.Pos {
+			return ioutil.BufferOverrun{Pos: b.Pos, Len: n19, Missing: n19 - (len(b.Bytes) - b.Pos)}
+		}
+		v.Inners[i17].B = string(b.Bytes[b.Pos : b.Pos+n19])
+		b.Pos += n19
+	}
+	if len(b.Bytes)-b.Pos < 3 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 3, Missing: 3 - (len(b.Bytes) - b.Pos)}
+	}
+	v.Embedded.E = uint16(bits.ReverseBytes32(b.ReadUint24()) >> 8)
+	return nil
+}
+
+// EncodeTo writes all fields using the given Encoder.
+func (v *Inner) EncodeTo(e *ioutil.Encoder) {
+	e.WriteUint24(ioutil.BigEndian, v.A)
+	e.WriteUTF8(ioutil.LittleEndian, ioutil.I8, v.B)
+}
+
+// DecodeFrom reads all fields using the given Decoder.
+func (v *Inner) DecodeFrom(d *ioutil.Decoder) {
+	v.A = d.ReadUint24(ioutil.BigEndian)
+	v.B = d.ReadUTF8(ioutil.LittleEndian, ioutil.I8)
+}
+
+// EncodeToBuffer writes all fields at the current buffer position, according to its WriteMode, and returns
+// an IntegerOverflow, if a length does not fit into its prefix.
+func (v *Inner) EncodeToBuffer(b *ioutil.LittleEndianBuffer) error {
+	b.WriteUint24(bits.ReverseBytes32(v.A) >> 8)
+	if uint64(len(v.B)) > uint64(math.MaxUint8) {
+		return ioutil.IntegerOverflow{Val: len(v.B), Max: math.MaxUint8}
+	}
+	b.WriteUint8(uint8(len(v.B)))
+	b.WriteStringBytes(v.B)
+	return nil
+}
+
+// DecodeFromBuffer reads all fields from the current buffer position. A truncated or malformed buffer results
+// in an error.
+func (v *Inner) DecodeFromBuffer(b *ioutil.LittleEndianBuffer) error {
+	if len(b.Bytes)-b.Pos < 3 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 3, Missing: 3 - (len(b.Bytes) - b.Pos)}
+	}
+	v.A = bits.ReverseBytes32(b.ReadUint24()) >> 8
+	if len(b.Bytes)-b.Pos < 1 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 1, Missing: 1 - (len(b.Bytes) - b.Pos)}
+	}
+	n1 := int(b.ReadUint8())
+	if n1 < 0 || n1 > len(b.Bytes)-b.Pos {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: n1, Missing: n1 - (len(b.Bytes) - b.Pos)}
+	}
+	v.B = string(b.Bytes[b.Pos : b.Pos+n1])
+	b.Pos += n1
+	return nil
+}
+
+// EncodeTo writes all fields using the given Encoder.
+func (v *Embedded) EncodeTo(e *ioutil.Encoder) {
+	e.WriteUint24(ioutil.BigEndian, uint32(v.E))
+}
+
+// DecodeFrom reads all fields using the given Decoder.
+func (v *Embedded) DecodeFrom(d *ioutil.Decoder) {
+	v.E = uint16(d.ReadUint24(ioutil.BigEndian))
+}
+
+// EncodeToBuffer writes all fields at the current buffer position, according to its WriteMode, and returns
+// an IntegerOverflow, if a length does not fit into its prefix.
+func (v *Embedded) EncodeToBuffer(b *ioutil.LittleEndianBuffer) error {
+	b.WriteUint24(bits.ReverseBytes32(uint32(v.E)) >> 8)
+	return nil
+}
+
+// DecodeFromBuffer reads all fields from the current buffer position. A truncated or malformed buffer results
+// in an error.
+func (v *Embedded) DecodeFromBuffer(b *ioutil.LittleEndianBuffer) error {
+	if len(b.Bytes)-b.Pos < 3 {
+		return ioutil.BufferOverrun{Pos: b.Pos, Len: 3, Missing: 3 - (len(b.Bytes) - b.Pos)}
+	}
+	v.E = uint16(bits.ReverseBytes32(b.ReadUint24()) >> 8)
+	return nil
+}
@@ -0,0 +1,273 @@
+package gentest
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/worldiety/ioutil"
+)
+
+func newRecord() Record {
+	return Record{
+		U8:     1,
+		I16:    -2,
+		K:      0xFFFF,
+		U24:    ioutil.MaxUint24,
+		I24:    ioutil.MinInt24,
+		U40:    ioutil.MaxUint40,
+		I40:    ioutil.MinInt40,
+		U48:    ioutil.MaxUint48,
+		I48:    -3,
+		U56:    ioutil.MaxUint56,
+		I56:    ioutil.MinInt56,
+		Var:    -4,
+		UVar:   300,
+		F32:    1.5,
+		F64:    -2.25,
+		C64:    complex(1, 2),
+		C128:   complex(-3, 4),
+		Flag:   true,
+		Blob:   []byte{1, 2, 3},
+		Name:   "hello",
+		Fixed:  [3]uint16{5, 6, 7},
+		Values: []int32{-1, 0, 1},
+		Inner:  Inner{A: 8, B: "world"},
+		Inners: []Inner{{A: 9, B: "x"}, {A: 10, B: "y"}},
+
+		Embedded: Embedded{E: 11},
+	}
+}
+
+func TestGeneratedEncoder(t *testing.T) {
+	src := newRecord()
+
+	expected := &bytes.Buffer{}
+	if err := ioutil.Marshal(ioutil.LittleEndian, expected, &src); err != nil {
+		t.Fatal(err)
+	}
+
+	actual := &bytes.Buffer{}
+	enc := ioutil.NewEncoder(actual, true)
+	src.EncodeTo(enc)
+
+	if enc.Error() != nil {
+		t.Fatal(enc.Error())
+	}
+
+	if !bytes.Equal(expected.Bytes(), actual.Bytes()) {
+		t.Fatalf("expected \n%v\n but got \n%v", expected.Bytes(), actual.Bytes())
+	}
+
+	var dst Record
+
+	dec := ioutil.NewDecoder(bytes.NewReader(actual.Bytes()), true)
+	dst.DecodeFrom(dec)
+
+	if dec.Error() != nil {
+		t.Fatal(dec.Error())
+	}
+
+	if !reflect.DeepEqual(src, dst) {
+		t.Fatalf("expected \n%+v\n but got \n%+v", src, dst)
+	}
+}
+
+func TestGeneratedBuffer(t *testing.T) {
+	src := newRecord()
+
+	expected := &bytes.Buffer{}
+	if err := ioutil.Marshal(ioutil.LittleEndian, expected, &src); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &ioutil.LittleEndianBuffer{Bytes: make([]byte, expected.Len())}
+	if err := src.EncodeToBuffer(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(expected.Bytes(), buf.Bytes) {
+		t.Fatalf("expected \n%v\n but got \n%v", expected.Bytes(), buf.Bytes)
+	}
+
+	var dst Record
+
+	buf.Pos = 0
+	if err := dst.DecodeFromBuffer(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.Pos != len(buf.Bytes) {
+		t.Fatalf("expected to read %d bytes but got %d", len(buf.Bytes), buf.Pos)
+	}
+
+	if !reflect.DeepEqual(src, dst) {
+		t.Fatalf("expected \n%+v\n but got \n%+v", src, dst)
+	}
+
+	// the generated code respects the WriteMode of the buffer
+	measure := &ioutil.LittleEndianBuffer{Mode: ioutil.WriteMeasure}
+	if err := src.EncodeToBuffer(measure); err != nil || measure.Pos != expected.Len() || measure.Bytes != nil {
+		t.Fatalf("expected to measure %d bytes but got %d and %v", expected.Len(), measure.Pos, err)
+	}
+
+	grow := &ioutil.LittleEndianBuffer{Mode: ioutil.WriteGrow}
+	if err := src.EncodeToBuffer(grow); err != nil || !bytes.Equal(expected.Bytes(), grow.Bytes) {
+		t.Fatalf("expected \n%v\n but got \n%v and %v", expected.Bytes(), grow.Bytes, err)
+	}
+}
+
+func TestGeneratedHandWritten(t *testing.T) {
+	src := Inner{A: 0x010203, B: "ab"}
+
+	actual := &bytes.Buffer{}
+	enc := ioutil.NewEncoder(actual, true)
+	src.EncodeTo(enc)
+
+	expected := &bytes.Buffer{}
+	hand := ioutil.NewEncoder(expected, true)
+	hand.WriteUint24(ioutil.BigEndian, src.A)
+	hand.WriteUTF8(ioutil.LittleEndian, ioutil.I8, src.B)
+
+	if !bytes.Equal(expected.Bytes(), actual.Bytes()) {
+		t.Fatalf("expected \n%v\n but got \n%v", expected.Bytes(), actual.Bytes())
+	}
+
+	var dst Inner
+
+	dst.DecodeFrom(ioutil.NewDecoder(bytes.NewReader(expected.Bytes()), true))
+
+	if dst != src {
+		t.Fatalf("expected %+v but got %+v", src, dst)
+	}
+}
+
+func TestGeneratedOverflow(t *testing.T) {
+	src := newRecord()
+	src.Values = make([]int32, 256)
+
+	var overflow ioutil.IntegerOverflow
+
+	if err := ioutil.Marshal(ioutil.LittleEndian, &bytes.Buffer{}, &src); !errors.As(err, &overflow) {
+		t.Fatalf("expected IntegerOverflow but got %v", err)
+	}
+
+	enc := ioutil.NewEncoder(&bytes.Buffer{}, true)
+	src.EncodeTo(enc)
+
+	if !errors.As(enc.Error(), &overflow) {
+		t.Fatalf("expected IntegerOverflow but got %v", enc.Error())
+	}
+
+	buf := &ioutil.LittleEndianBuffer{Bytes: make([]byte, 4096)}
+	if err := src.EncodeToBuffer(buf); !errors.As(err, &overflow) {
+		t.Fatalf("expected IntegerOverflow but got %v", err)
+	}
+}
+
+func TestGeneratedMalformed(t *testing.T) {
+	src := newRecord()
+
+	encoded := &bytes.Buffer{}
+	if err := ioutil.Marshal(ioutil.LittleEndian, encoded, &src); err != nil {
+		t.Fatal(err)
+	}
+
+	// every truncation must result in an error instead of a panic
+	for i := 0; i < encoded.Len(); i++ {
+		var dst Record
+
+		buf := &ioutil.LittleEndianBuffer{Bytes: encoded.Bytes()[:i]}
+		if err := dst.DecodeFromBuffer(buf); err == nil {
+			t.Fatalf("%d: expected an error", i)
+		}
+
+		dec := ioutil.NewDecoder(bytes.NewReader(encoded.Bytes()[:i]), true)
+		if dst.DecodeFrom(dec); dec.Error() == nil {
+			t.Fatalf("%d: expected an error", i)
+		}
+	}
+
+	// a hostile length must neither allocate nor panic
+	var dst Inner
+
+	buf := &ioutil.LittleEndianBuffer{Bytes: []byte{1, 2, 3, 0xFF}}
+	if err := dst.DecodeFromBuffer(buf); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// the u24 prefix of Inners is followed by the u24 of Embedded
+	src.Inners = nil
+	encoded.Reset()
+
+	if err := ioutil.Marshal(ioutil.LittleEndian, encoded, &src); err != nil {
+		t.Fatal(err)
+	}
+
+	hostile := encoded.Bytes()
+	copy(hostile[len(hostile)-6:], []byte{0xFF, 0xFF, 0xFF})
+
+	dec := ioutil.NewDecoder(bytes.NewReader(hostile), true)
+	dec.SetLimits(ioutil.Limits{MaxBlobSize: 1024})
+
+	var record Record
+
+	var tooLarge ioutil.BlobTooLarge
+	if record.DecodeFrom(dec); !errors.As(dec.Error(), &tooLarge) {
+		t.Fatalf("expected BlobTooLarge but got %v", dec.Error())
+	}
+
+	if err := record.DecodeFromBuffer(&ioutil.LittleEndianBuffer{Bytes: hostile}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// without limits, the slice only grows with the decoded elements instead of trusting the prefix
+	var before, after runtime.MemStats
+
+	runtime.ReadMemStats(&before)
+
+	dec = ioutil.NewDecoder(bytes.NewReader(hostile), true)
+	if record.DecodeFrom(dec); dec.Error() == nil {
+		t.Fatal("expected an error")
+	}
+
+	runtime.ReadMemStats(&after)
+
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Fatalf("expected to allocate less than 1 MiB but got %d bytes", allocated)
+	}
+}
+
+func TestGeneratedCorrupted(t *testing.T) {
+	src := newRecord()
+
+	encoded := &bytes.Buffer{}
+	if err := ioutil.Marshal(ioutil.LittleEndian, encoded, &src); err != nil {
+		t.Fatal(err)
+	}
+
+	// corrupted bytes, e.g. within varints or length prefixes, must result in an error or a value but never panic
+	for i := 0; i < encoded.Len(); i++ {
+		for _, corruption := range []byte{0x00, 0x80, 0xFF} {
+			corrupted := append([]byte(nil), encoded.Bytes()...)
+			corrupted[i] = corruption
+
+			var dst Record
+
+			_ = dst.DecodeFromBuffer(&ioutil.LittleEndianBuffer{Bytes: corrupted})
+		}
+	}
+
+	// a varint, which overflows 64 bit, is malformed
+	var dst Record
+
+	varintOffset := 1 + 2 + 3 + 3 + 3 + 5 + 5 + 6 + 6 + 7 + 7
+	corrupted := append([]byte(nil), encoded.Bytes()[:varintOffset]...)
+	corrupted = append(corrupted, bytes.Repeat([]byte{0xFF}, 11)...)
+
+	if err := dst.DecodeFromBuffer(&ioutil.LittleEndianBuffer{Bytes: corrupted}); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,492 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"errors"
+	"math"
+)
+
+// SortOrder defines whether a key component is sorted ascending or descending.
+type SortOrder int
+
+const (
+	// Ascending encodes a key component, so that smaller values sort first.
+	Ascending SortOrder = 0
+
+	// Descending encodes a key component with inverted bytes, so that larger values sort first.
+	Descending SortOrder = 1
+)
+
+const (
+	keyEscape     = 0x00 // keyEscape introduces an escaped zero byte or the terminator
+	keyEscapedNul = 0xFF // keyEscapedNul follows keyEscape for a zero byte within a string or blob
+	keyTerminator = 0x01 // keyTerminator follows keyEscape to mark the end of a string or blob
+)
+
+var errInvalidKeyEscape = errors.New("invalid escape sequence in key")
+
+var zeroKeyBytes [8]byte
+
+// A KeyEncoder appends memcomparable key components to Bytes. The lexicographic byte order of two keys, which
+// have been encoded with the same sequence of methods and sort orders, is the same as the natural order of the
+// encoded values, so the keys can be used for sorted key-value stores. Integers are encoded in big endian with
+// a flipped sign bit and floats are encoded as sortable IEEE 754 bit sequences. Strings and blobs are not length
+// prefixed, instead each zero byte is escaped as 0x00 0xFF and the end is marked by 0x00 0x01. Descending
+// components are encoded like ascending ones but all bytes are inverted. The zero value is ready to use.
+type KeyEncoder struct {
+	Bytes []byte // Bytes contains the encoded key
+}
+
+// Reset truncates the encoded key but keeps the allocated memory.
+func (e *KeyEncoder) Reset() {
+	e.Bytes = e.Bytes[:0]
+}
+
+// WriteBool writes false as 0 and true as 1.
+func (e *KeyEncoder) WriteBool(s SortOrder, v bool) {
+	if v {
+		e.writeUint(s, 1, 1)
+	} else {
+		e.writeUint(s, 0, 1)
+	}
+}
+
+// WriteUint8 writes an unsigned byte.
+func (e *KeyEncoder) WriteUint8(s SortOrder, v uint8) {
+	e.writeUint(s, uint64(v), 1)
+}
+
+// WriteUint16 writes an unsigned 2 byte integer.
+func (e *KeyEncoder) WriteUint16(s SortOrder, v uint16) {
+	e.writeUint(s, uint64(v), 2)
+}
+
+// WriteUint24 writes an unsigned 3 byte integer.
+func (e *KeyEncoder) WriteUint24(s SortOrder, v uint32) {
+	e.writeUint(s, uint64(v), 3)
+}
+
+// WriteUint32 writes an unsigned 4 byte integer.
+func (e *KeyEncoder) WriteUint32(s SortOrder, v uint32) {
+	e.writeUint(s, uint64(v), 4)
+}
+
+// WriteUint40 writes an unsigned 5 byte integer.
+func (e *KeyEncoder) WriteUint40(s SortOrder, v uint64) {
+	e.writeUint(s, v, 5)
+}
+
+// WriteUint48 writes an unsigned 6 byte integer.
+func (e *KeyEncoder) WriteUint48(s SortOrder, v uint64) {
+	e.writeUint(s, v, 6)
+}
+
+// WriteUint56 writes an unsigned 7 byte integer.
+func (e *KeyEncoder) WriteUint56(s SortOrder, v uint64) {
+	e.writeUint(s, v, 7)
+}
+
+// WriteUint64 writes an unsigned 8 byte integer.
+func (e *KeyEncoder) WriteUint64(s SortOrder, v uint64) {
+	e.writeUint(s, v, 8)
+}
+
+// WriteInt8 writes a signed byte.
+func (e *KeyEncoder) WriteInt8(s SortOrder, v int8) {
+	e.writeInt(s, int64(v), 1)
+}
+
+// WriteInt16 writes a signed 2 byte integer.
+func (e *KeyEncoder) WriteInt16(s SortOrder, v int16) {
+	e.writeInt(s, int64(v), 2)
+}
+
+// WriteInt24 writes a signed 3 byte integer.
+func (e *KeyEncoder) WriteInt24(s SortOrder, v int32) {
+	e.writeInt(s, int64(v), 3)
+}
+
+// WriteInt32 writes a signed 4 byte integer.
+func (e *KeyEncoder) WriteInt32(s SortOrder, v int32) {
+	e.writeInt(s, int64(v), 4)
+}
+
+// WriteInt40 writes a signed 5 byte integer.
+func (e *KeyEncoder) WriteInt40(s SortOrder, v int64) {
+	e.writeInt(s, v, 5)
+}
+
+// WriteInt48 writes a signed 6 byte integer.
+func (e *KeyEncoder) WriteInt48(s SortOrder, v int64) {
+	e.writeInt(s, v, 6)
+}
+
+// WriteInt56 writes a signed 7 byte integer.
+func (e *KeyEncoder) WriteInt56(s SortOrder, v int64) {
+	e.writeInt(s, v, 7)
+}
+
+// WriteInt64 writes a signed 8 byte integer.
+func (e *KeyEncoder) WriteInt64(s SortOrder, v int64) {
+	e.writeInt(s, v, 8)
+}
+
+// WriteFloat32 writes a sortable float32 IEEE 754 4 byte bit sequence. NaN sorts after positive infinity.
+func (e *KeyEncoder) WriteFloat32(s SortOrder, v float32) {
+	bits := math.Float32bits(v)
+	if bits&(1<<31) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 31
+	}
+
+	e.writeUint(s, uint64(bits), 4)
+}
+
+// WriteFloat64 writes a sortable float64 IEEE 754 8 byte bit sequence. NaN sorts after positive infinity.
+func (e *KeyEncoder) WriteFloat64(s SortOrder, v float64) {
+	bits := math.Float64bits(v)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+
+	e.writeUint(s, bits, 8)
+}
+
+// WriteUTF8 writes an escaped and terminated string.
+func (e *KeyEncoder) WriteUTF8(s SortOrder, v string) {
+	start := len(e.Bytes)
+
+	for i := 0; i < len(v); i++ {
+		if v[i] == keyEscape {
+			e.Bytes = append(e.Bytes, keyEscape, keyEscapedNul)
+		} else {
+			e.Bytes = append(e.Bytes, v[i])
+		}
+	}
+
+	e.Bytes = append(e.Bytes, keyEscape, keyTerminator)
+	e.invert(s, start)
+}
+
+// WriteBlob writes an escaped and terminated byte slice.
+func (e *KeyEncoder) WriteBlob(s SortOrder, v []byte) {
+	start := len(e.Bytes)
+
+	for _, b := range v {
+		if b == keyEscape {
+			e.Bytes = append(e.Bytes, keyEscape, keyEscapedNul)
+		} else {
+			e.Bytes = append(e.Bytes, b)
+		}
+	}
+
+	e.Bytes = append(e.Bytes, keyEscape, keyTerminator)
+	e.invert(s, start)
+}
+
+// writeInt flips the sign bit, so that negative values sort before positive values.
+func (e *KeyEncoder) writeInt(s SortOrder, v int64, size int) {
+	e.writeUint(s, uint64(v)^1<<(uint(size)*8-1), size)
+}
+
+// writeUint appends size bytes of v in big endian.
+func (e *KeyEncoder) writeUint(s SortOrder, v uint64, size int) {
+	start := len(e.Bytes)
+	e.Bytes = append(e.Bytes, zeroKeyBytes[:size]...)
+	tmp := e.Bytes[start:]
+
+	switch size {
+	case 1:
+		tmp[0] = byte(v)
+	case 2:
+		BigEndian.PutUint16(tmp, uint16(v))
+	case 3:
+		BigEndian.PutUint24(tmp, uint32(v))
+	case 4:
+		BigEndian.PutUint32(tmp, uint32(v))
+	case 5:
+		BigEndian.PutUint40(tmp, v)
+	case 6:
+		BigEndian.PutUint48(tmp, v)
+	case 7:
+		BigEndian.PutUint56(tmp, v)
+	case 8:
+		BigEndian.PutUint64(tmp, v)
+	}
+
+	e.invert(s, start)
+}
+
+// invert flips all bytes from start on, if the sort order is descending.
+func (e *KeyEncoder) invert(s SortOrder, start int) {
+	if s != Descending {
+		return
+	}
+
+	for i := start; i < len(e.Bytes); i++ {
+		e.Bytes[i] = ^e.Bytes[i]
+	}
+}
+
+// A KeyDecoder reads the key components, which have been written by a KeyEncoder. The same sequence of methods
+// and sort orders must be used. As soon as any error occurred, any call is a no-op and will result in the same
+// error state. Any error is a *DecodeError.
+type KeyDecoder struct {
+	buf      []byte
+	pos      int
+	firstErr error
+}
+
+// NewKeyDecoder creates a new KeyDecoder, which reads from the given key.
+func NewKeyDecoder(key []byte) *KeyDecoder {
+	return &KeyDecoder{buf: key}
+}
+
+// Position returns the amount of bytes, which have been read so far.
+func (d *KeyDecoder) Position() int64 {
+	return int64(d.pos)
+}
+
+// Remaining returns the amount of unread bytes.
+func (d *KeyDecoder) Remaining() int {
+	return len(d.buf) - d.pos
+}
+
+// ReadBool reads one byte and returns true, if it is not zero.
+func (d *KeyDecoder) ReadBool(s SortOrder) bool {
+	return d.readUint("ReadBool", s, 1) != 0
+}
+
+// ReadUint8 reads an unsigned byte.
+func (d *KeyDecoder) ReadUint8(s SortOrder) uint8 {
+	return uint8(d.readUint("ReadUint8", s, 1))
+}
+
+// ReadUint16 reads an unsigned 2 byte integer.
+func (d *KeyDecoder) ReadUint16(s SortOrder) uint16 {
+	return uint16(d.readUint("ReadUint16", s, 2))
+}
+
+// ReadUint24 reads an unsigned 3 byte integer.
+func (d *KeyDecoder) ReadUint24(s SortOrder) uint32 {
+	return uint32(d.readUint("ReadUint24", s, 3))
+}
+
+// ReadUint32 reads an unsigned 4 byte integer.
+func (d *KeyDecoder) ReadUint32(s SortOrder) uint32 {
+	return uint32(d.readUint("ReadUint32", s, 4))
+}
+
+// ReadUint40 reads an unsigned 5 byte integer.
+func (d *KeyDecoder) ReadUint40(s SortOrder) uint64 {
+	return d.readUint("ReadUint40", s, 5)
+}
+
+// ReadUint48 reads an unsigned 6 byte integer.
+func (d *KeyDecoder) ReadUint48(s SortOrder) uint64 {
+	return d.readUint("ReadUint48", s, 6)
+}
+
+// ReadUint56 reads an unsigned 7 byte integer.
+func (d *KeyDecoder) ReadUint56(s SortOrder) uint64 {
+	return d.readUint("ReadUint56", s, 7)
+}
+
+// ReadUint64 reads an unsigned 8 byte integer.
+func (d *KeyDecoder) ReadUint64(s SortOrder) uint64 {
+	return d.readUint("ReadUint64", s, 8)
+}
+
+// ReadInt8 reads a signed byte.
+func (d *KeyDecoder) ReadInt8(s SortOrder) int8 {
+	return int8(d.readInt("ReadInt8", s, 1))
+}
+
+// ReadInt16 reads a signed 2 byte integer.
+func (d *KeyDecoder) ReadInt16(s SortOrder) int16 {
+	return int16(d.readInt("ReadInt16", s, 2))
+}
+
+// ReadInt24 reads a signed 3 byte integer.
+func (d *KeyDecoder) ReadInt24(s SortOrder) int32 {
+	return int32(d.readInt("ReadInt24", s, 3))
+}
+
+// ReadInt32 reads a signed 4 byte integer.
+func (d *KeyDecoder) ReadInt32(s SortOrder) int32 {
+	return int32(d.readInt("ReadInt32", s, 4))
+}
+
+// ReadInt40 reads a signed 5 byte integer.
+func (d *KeyDecoder) ReadInt40(s SortOrder) int64 {
+	return d.readInt("ReadInt40", s, 5)
+}
+
+// ReadInt48 reads a signed 6 byte integer.
+func (d *KeyDecoder) ReadInt48(s SortOrder) int64 {
+	return d.readInt("ReadInt48", s, 6)
+}
+
+// ReadInt56 reads a signed 7 byte integer.
+func (d *KeyDecoder) ReadInt56(s SortOrder) int64 {
+	return d.readInt("ReadInt56", s, 7)
+}
+
+// ReadInt64 reads a signed 8 byte integer.
+func (d *KeyDecoder) ReadInt64(s SortOrder) int64 {
+	return d.readInt("ReadInt64", s, 8)
+}
+
+// ReadFloat32 reads a sortable float32 IEEE 754 4 byte bit sequence.
+func (d *KeyDecoder) ReadFloat32(s SortOrder) float32 {
+	bits := uint32(d.readUint("ReadFloat32", s, 4))
+	if bits&(1<<31) != 0 {
+		bits &^= 1 << 31
+	} else {
+		bits = ^bits
+	}
+
+	return math.Float32frombits(bits)
+}
+
+// ReadFloat64 reads a sortable float64 IEEE 754 8 byte bit sequence.
+func (d *KeyDecoder) ReadFloat64(s SortOrder) float64 {
+	bits := d.readUint("ReadFloat64", s, 8)
+	if bits&(1<<63) != 0 {
+		bits &^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+
+	return math.Float64frombits(bits)
+}
+
+// ReadUTF8 reads an escaped and terminated string.
+func (d *KeyDecoder) ReadUTF8(s SortOrder) string {
+	return string(d.readEscaped("ReadUTF8", s))
+}
+
+// ReadBlob reads an escaped and terminated byte slice into a newly allocated buffer.
+func (d *KeyDecoder) ReadBlob(s SortOrder) []byte {
+	return d.readEscaped("ReadBlob", s)
+}
+
+// Error returns the first occurred error. Each call to any Read* method may cause an error.
+func (d *KeyDecoder) Error() error {
+	return d.firstErr
+}
+
+func (d *KeyDecoder) readEscaped(op string, s SortOrder) []byte {
+	if d.firstErr != nil {
+		return nil
+	}
+
+	var mask byte
+	if s == Descending {
+		mask = 0xFF
+	}
+
+	offset := d.pos
+	res := make([]byte, 0)
+
+	for i := d.pos; i < len(d.buf); i++ {
+		b := d.buf[i] ^ mask
+		if b != keyEscape {
+			res = append(res, b)
+			continue
+		}
+
+		if i+1 == len(d.buf) {
+			break
+		}
+
+		i++
+
+		switch d.buf[i] ^ mask {
+		case keyEscapedNul:
+			res = append(res, keyEscape)
+		case keyTerminator:
+			d.pos = i + 1
+			return res
+		default:
+			d.noteErr(op, i-1, errInvalidKeyEscape)
+			return nil
+		}
+	}
+
+	d.noteErr(op, offset, eofErr(d.Remaining()))
+
+	return nil
+}
+
+func (d *KeyDecoder) readInt(op string, s SortOrder, size int) int64 {
+	shift := 64 - uint(size)*8
+	v := d.readUint(op, s, size) ^ 1<<(uint(size)*8-1)
+
+	return int64(v<<shift) >> shift
+}
+
+func (d *KeyDecoder) readUint(op string, s SortOrder, size int) uint64 {
+	if d.firstErr != nil {
+		return 0
+	}
+
+	if d.Remaining() < size {
+		d.noteErr(op, d.pos, eofErr(d.Remaining()))
+		return 0
+	}
+
+	tmp := d.buf[d.pos : d.pos+size]
+	d.pos += size
+
+	var v uint64
+
+	switch size {
+	case 1:
+		v = uint64(tmp[0])
+	case 2:
+		v = uint64(BigEndian.Uint16(tmp))
+	case 3:
+		v = uint64(BigEndian.Uint24(tmp))
+	case 4:
+		v = uint64(BigEndian.Uint32(tmp))
+	case 5:
+		v = BigEndian.Uint40(tmp)
+	case 6:
+		v = BigEndian.Uint48(tmp)
+	case 7:
+		v = BigEndian.Uint56(tmp)
+	case 8:
+		v = BigEndian.Uint64(tmp)
+	}
+
+	if s == Descending {
+		v = ^v & (1<<(uint(size)*8) - 1)
+	}
+
+	return v
+}
+
+func (d *KeyDecoder) noteErr(op string, offset int, err error) {
+	if d.firstErr == nil {
+		d.firstErr = &DecodeError{Offset: int64(offset), Op: op, Order: BigEndian, Err: err}
+	}
+}
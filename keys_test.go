@@ -0,0 +1,214 @@
+package ioutil
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// keyCase describes one key component type with a comparison of the natural order.
+type keyCase struct {
+	name   string
+	random func(r *rand.Rand) interface{}
+	less   func(a, b interface{}) bool
+	write  func(e *KeyEncoder, s SortOrder, v interface{})
+	read   func(d *KeyDecoder, s SortOrder) interface{}
+}
+
+func signedKeyCase(name string, bits uint, write func(e *KeyEncoder, s SortOrder, v int64),
+	read func(d *KeyDecoder, s SortOrder) int64) keyCase {
+	return keyCase{
+		name: name,
+		random: func(r *rand.Rand) interface{} {
+			return int64(r.Uint64()<<(64-bits)) >> (64 - bits)
+		},
+		less:  func(a, b interface{}) bool { return a.(int64) < b.(int64) },
+		write: func(e *KeyEncoder, s SortOrder, v interface{}) { write(e, s, v.(int64)) },
+		read:  func(d *KeyDecoder, s SortOrder) interface{} { return read(d, s) },
+	}
+}
+
+func unsignedKeyCase(name string, bits uint, write func(e *KeyEncoder, s SortOrder, v uint64),
+	read func(d *KeyDecoder, s SortOrder) uint64) keyCase {
+	return keyCase{
+		name: name,
+		random: func(r *rand.Rand) interface{} {
+			return r.Uint64() >> (64 - bits)
+		},
+		less:  func(a, b interface{}) bool { return a.(uint64) < b.(uint64) },
+		write: func(e *KeyEncoder, s SortOrder, v interface{}) { write(e, s, v.(uint64)) },
+		read:  func(d *KeyDecoder, s SortOrder) interface{} { return read(d, s) },
+	}
+}
+
+//nolint:funlen
+func keyCases() []keyCase {
+	return []keyCase{
+		unsignedKeyCase("uint8", 8,
+			func(e *KeyEncoder, s SortOrder, v uint64) { e.WriteUint8(s, uint8(v)) },
+			func(d *KeyDecoder, s SortOrder) uint64 { return uint64(d.ReadUint8(s)) }),
+		unsignedKeyCase("uint16", 16,
+			func(e *KeyEncoder, s SortOrder, v uint64) { e.WriteUint16(s, uint16(v)) },
+			func(d *KeyDecoder, s SortOrder) uint64 { return uint64(d.ReadUint16(s)) }),
+		unsignedKeyCase("uint24", 24,
+			func(e *KeyEncoder, s SortOrder, v uint64) { e.WriteUint24(s, uint32(v)) },
+			func(d *KeyDecoder, s SortOrder) uint64 { return uint64(d.ReadUint24(s)) }),
+		unsignedKeyCase("uint32", 32,
+			func(e *KeyEncoder, s SortOrder, v uint64) { e.WriteUint32(s, uint32(v)) },
+			func(d *KeyDecoder, s SortOrder) uint64 { return uint64(d.ReadUint32(s)) }),
+		unsignedKeyCase("uint40", 40, (*KeyEncoder).WriteUint40, (*KeyDecoder).ReadUint40),
+		unsignedKeyCase("uint48", 48, (*KeyEncoder).WriteUint48, (*KeyDecoder).ReadUint48),
+		unsignedKeyCase("uint56", 56, (*KeyEncoder).WriteUint56, (*KeyDecoder).ReadUint56),
+		unsignedKeyCase("uint64", 64, (*KeyEncoder).WriteUint64, (*KeyDecoder).ReadUint64),
+		signedKeyCase("int8", 8,
+			func(e *KeyEncoder, s SortOrder, v int64) { e.WriteInt8(s, int8(v)) },
+			func(d *KeyDecoder, s SortOrder) int64 { return int64(d.ReadInt8(s)) }),
+		signedKeyCase("int16", 16,
+			func(e *KeyEncoder, s SortOrder, v int64) { e.WriteInt16(s, int16(v)) },
+			func(d *KeyDecoder, s SortOrder) int64 { return int64(d.ReadInt16(s)) }),
+		signedKeyCase("int24", 24,
+			func(e *KeyEncoder, s SortOrder, v int64) { e.WriteInt24(s, int32(v)) },
+			func(d *KeyDecoder, s SortOrder) int64 { return int64(d.ReadInt24(s)) }),
+		signedKeyCase("int32", 32,
+			func(e *KeyEncoder, s SortOrder, v int64) { e.WriteInt32(s, int32(v)) },
+			func(d *KeyDecoder, s SortOrder) int64 { return int64(d.ReadInt32(s)) }),
+		signedKeyCase("int40", 40, (*KeyEncoder).WriteInt40, (*KeyDecoder).ReadInt40),
+		signedKeyCase("int48", 48, (*KeyEncoder).WriteInt48, (*KeyDecoder).ReadInt48),
+		signedKeyCase("int56", 56, (*KeyEncoder).WriteInt56, (*KeyDecoder).ReadInt56),
+		signedKeyCase("int64", 64, (*KeyEncoder).WriteInt64, (*KeyDecoder).ReadInt64),
+		{
+			name: "float32",
+			random: func(r *rand.Rand) interface{} {
+				specials := []float32{0, float32(math.Copysign(0, -1)), float32(math.Inf(1)), float32(math.Inf(-1)),
+					math.SmallestNonzeroFloat32, -math.SmallestNonzeroFloat32, math.MaxFloat32, -math.MaxFloat32}
+				if r.Intn(4) == 0 {
+					return specials[r.Intn(len(specials))]
+				}
+
+				return float32(r.NormFloat64() * math.Pow(10, float64(r.Intn(60)-30)))
+			},
+			less:  func(a, b interface{}) bool { return a.(float32) < b.(float32) },
+			write: func(e *KeyEncoder, s SortOrder, v interface{}) { e.WriteFloat32(s, v.(float32)) },
+			read:  func(d *KeyDecoder, s SortOrder) interface{} { return d.ReadFloat32(s) },
+		},
+		{
+			name: "float64",
+			random: func(r *rand.Rand) interface{} {
+				specials := []float64{0, math.Copysign(0, -1), math.Inf(1), math.Inf(-1),
+					math.SmallestNonzeroFloat64, -math.SmallestNonzeroFloat64, math.MaxFloat64, -math.MaxFloat64}
+				if r.Intn(4) == 0 {
+					return specials[r.Intn(len(specials))]
+				}
+
+				return r.NormFloat64() * math.Pow(10, float64(r.Intn(600)-300))
+			},
+			less:  func(a, b interface{}) bool { return a.(float64) < b.(float64) },
+			write: func(e *KeyEncoder, s SortOrder, v interface{}) { e.WriteFloat64(s, v.(float64)) },
+			read:  func(d *KeyDecoder, s SortOrder) interface{} { return d.ReadFloat64(s) },
+		},
+		{
+			name:   "bool",
+			random: func(r *rand.Rand) interface{} { return r.Intn(2) == 1 },
+			less:   func(a, b interface{}) bool { return !a.(bool) && b.(bool) },
+			write:  func(e *KeyEncoder, s SortOrder, v interface{}) { e.WriteBool(s, v.(bool)) },
+			read:   func(d *KeyDecoder, s SortOrder) interface{} { return d.ReadBool(s) },
+		},
+		{
+			name: "utf8",
+			random: func(r *rand.Rand) interface{} {
+				// a small alphabet including the escape bytes provokes common prefixes
+				alphabet := []byte{0x00, 0x01, 'a', 'b', 0xFE, 0xFF}
+				b := make([]byte, r.Intn(6))
+				for i := range b {
+					b[i] = alphabet[r.Intn(len(alphabet))]
+				}
+
+				return string(b)
+			},
+			less:  func(a, b interface{}) bool { return a.(string) < b.(string) },
+			write: func(e *KeyEncoder, s SortOrder, v interface{}) { e.WriteUTF8(s, v.(string)) },
+			read:  func(d *KeyDecoder, s SortOrder) interface{} { return d.ReadUTF8(s) },
+		},
+		{
+			name: "blob",
+			random: func(r *rand.Rand) interface{} {
+				alphabet := []byte{0x00, 0x01, 'a', 0xFF}
+				b := make([]byte, r.Intn(6))
+				for i := range b {
+					b[i] = alphabet[r.Intn(len(alphabet))]
+				}
+
+				return string(b)
+			},
+			less:  func(a, b interface{}) bool { return a.(string) < b.(string) },
+			write: func(e *KeyEncoder, s SortOrder, v interface{}) { e.WriteBlob(s, []byte(v.(string))) },
+			read:  func(d *KeyDecoder, s SortOrder) interface{} { return string(d.ReadBlob(s)) },
+		},
+	}
+}
+
+func TestKeyEncoder_Order(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for _, kc := range keyCases() {
+		for _, order := range []SortOrder{Ascending, Descending} {
+			// each key consists of the value and a trailing component, which must not influence the order
+			values := make([]interface{}, 200)
+			keys := make([][]byte, len(values))
+
+			for i := range values {
+				values[i] = kc.random(r)
+				e := &KeyEncoder{}
+				kc.write(e, order, values[i])
+				e.WriteUint8(Ascending, uint8(r.Intn(256)))
+				keys[i] = e.Bytes
+
+				d := NewKeyDecoder(keys[i])
+				if v := kc.read(d, order); v != values[i] {
+					t.Fatalf("%s: expected %v but got %v", kc.name, values[i], v)
+				}
+
+				if d.ReadUint8(Ascending); d.Error() != nil || d.Remaining() != 0 {
+					t.Fatalf("%s: unexpected decoder state %v", kc.name, d.Error())
+				}
+			}
+
+			idx := make([]int, len(values))
+			for i := range idx {
+				idx[i] = i
+			}
+
+			sort.Slice(idx, func(i, j int) bool { return bytes.Compare(keys[idx[i]], keys[idx[j]]) < 0 })
+
+			for i := 1; i < len(idx); i++ {
+				a, b := values[idx[i-1]], values[idx[i]]
+				if order == Descending {
+					a, b = b, a
+				}
+
+				if kc.less(b, a) {
+					t.Fatalf("%s %d: %v sorted before %v", kc.name, order, values[idx[i-1]], values[idx[i]])
+				}
+			}
+		}
+	}
+}
+
+func TestKeyDecoder_Invalid(t *testing.T) {
+	d := NewKeyDecoder([]byte{'a', 0x00, 0x02})
+	if d.ReadUTF8(Ascending) != "" || d.Error() == nil {
+		t.Fatal("expected an invalid escape sequence")
+	}
+
+	d = NewKeyDecoder([]byte{'a', 'b'})
+	if d.ReadUTF8(Ascending) != "" || d.Error() == nil {
+		t.Fatal("expected a missing terminator")
+	}
+
+	d = NewKeyDecoder([]byte{1, 2, 3})
+	if d.ReadUint32(Ascending) != 0 || d.Error() == nil {
+		t.Fatal("expected unexpected EOF")
+	}
+}
@@ -1,16 +1,38 @@
 package ioutil
 
 import (
+	"encoding/binary"
 	"math"
 	"reflect"
 	"strconv"
 	"unsafe"
 )
 
+// WriteMode defines how a LittleEndianBuffer behaves, when writing beyond the end of its Bytes. The checked
+// buffers always behave like WriteFixed and record a BufferOverrun instead.
+type WriteMode int
+
+const (
+	// WriteFixed writes into the given Bytes and panics, if the buffer is too small. This is the default.
+	WriteFixed WriteMode = 0
+
+	// WriteGrow enlarges Bytes as required. The capacity either doubles or uses the exact size, whatever is
+	// larger. The length of Bytes is the largest position ever written.
+	WriteGrow WriteMode = 1
+
+	// WriteMeasure does not touch Bytes at all and just advances Pos. Write a sequence of values starting at
+	// position 0 to calculate the exact encoded length, e.g. to allocate a fixed buffer afterwards.
+	WriteMeasure WriteMode = 2
+)
+
 // LittleEndianBuffer is a light weight helper to modify bytes within a buffer in little endian format.
+// Reading is not affected by the Mode.
 type LittleEndianBuffer struct {
 	Bytes []byte
 	Pos   int
+	Mode  WriteMode
+
+	scratch [8]byte // scratch receives the fixed size writes in WriteMeasure mode
 }
 
 func (f *LittleEndianBuffer) ReadUint8() uint8 {
@@ -20,8 +42,50 @@ func (f *LittleEndianBuffer) ReadUint8() uint8 {
 }
 
 func (f *LittleEndianBuffer) WriteUint8(v uint8) {
-	f.Bytes[f.Pos] = v
-	f.Pos++
+	f.reserve(1)[0] = v
+}
+
+// reserve advances Pos by n and returns the n bytes to write into. The fast path is inlined for WriteFixed and
+// panics, if less than n bytes are available.
+func (f *LittleEndianBuffer) reserve(n int) []byte {
+	if f.Mode != WriteFixed {
+		return f.reserveSlow(n)
+	}
+
+	b := f.Bytes[f.Pos : f.Pos+n]
+	f.Pos += n
+	return b
+}
+
+//go:noinline
+func (f *LittleEndianBuffer) reserveSlow(n int) []byte {
+	pos := f.Pos
+	f.Pos += n
+
+	if f.Mode == WriteMeasure {
+		return f.scratch[:n]
+	}
+
+	f.grow(f.Pos)
+	return f.Bytes[pos:f.Pos]
+}
+
+// grow ensures the required size. New capacity either doubles or uses the exact size, whatever is larger.
+func (f *LittleEndianBuffer) grow(size int) {
+	if size > cap(f.Bytes) {
+		newCap := 2 * cap(f.Bytes)
+		if size > newCap {
+			newCap = size
+		}
+
+		tmp := make([]byte, len(f.Bytes), newCap)
+		copy(tmp, f.Bytes)
+		f.Bytes = tmp
+	}
+
+	if size > len(f.Bytes) {
+		f.Bytes = f.Bytes[:size]
+	}
 }
 
 func (f *LittleEndianBuffer) postInc() int {
@@ -43,8 +107,7 @@ func (f *LittleEndianBuffer) ReadUint16() uint16 {
 }
 
 func (f *LittleEndianBuffer) WriteUint16(v uint16) {
-	b := f.Bytes[f.Pos:]
-	f.Pos += 2
+	b := f.reserve(2)
 	_ = b[1] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = byte(v)
 	b[1] = byte(v >> 8)
@@ -59,8 +122,7 @@ func (f *LittleEndianBuffer) ReadUint24() uint32 {
 }
 
 func (f *LittleEndianBuffer) WriteUint24(v uint32) {
-	b := f.Bytes[f.Pos:]
-	f.Pos += 3
+	b := f.reserve(3)
 
 	_ = b[2] // early bounds check to guarantee safety of writes below
 	b[0] = byte(v)
@@ -81,8 +143,7 @@ func (f *LittleEndianBuffer) ReadUint32() uint32 {
 }
 
 func (f *LittleEndianBuffer) WriteUint32(v uint32) {
-	b := f.Bytes[f.Pos:]
-	f.Pos += 4
+	b := f.reserve(4)
 
 	_ = b[3] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = byte(v)
@@ -100,8 +161,7 @@ func (f *LittleEndianBuffer) ReadUint40() uint64 {
 }
 
 func (f *LittleEndianBuffer) WriteUint40(v uint64) {
-	b := f.Bytes[f.Pos:]
-	f.Pos += 5
+	b := f.reserve(5)
 
 	_ = b[4] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = byte(v)
@@ -121,8 +181,7 @@ func (f *LittleEndianBuffer) ReadUint48() uint64 {
 }
 
 func (f *LittleEndianBuffer) WriteUint48(v uint64) {
-	b := f.Bytes[f.Pos:]
-	f.Pos += 6
+	b := f.reserve(6)
 
 	_ = b[5] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = byte(v)
@@ -143,8 +202,7 @@ func (f *LittleEndianBuffer) ReadUint56() uint64 {
 }
 
 func (f *LittleEndianBuffer) WriteUint56(v uint64) {
-	b := f.Bytes[f.Pos:]
-	f.Pos += 7
+	b := f.reserve(7)
 
 	_ = b[6] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = byte(v)
@@ -166,8 +224,7 @@ func (f *LittleEndianBuffer) ReadUint64() uint64 {
 }
 
 func (f *LittleEndianBuffer) WriteUint64(v uint64) {
-	b := f.Bytes[f.Pos:]
-	f.Pos += 8
+	b := f.reserve(8)
 
 	_ = b[7] // bounds check hint to compiler; see golang.org/issue/14808
 	b[0] = byte(v)
@@ -182,9 +239,23 @@ func (f *LittleEndianBuffer) WriteUint64(v uint64) {
 
 // WriteSlice copies the content of the given buffer into the destination
 func (f *LittleEndianBuffer) WriteSlice(v []byte) {
-	b := f.Bytes[f.Pos : f.Pos+len(v)]
-	copy(b, v)
-	f.Pos += len(v)
+	if f.Mode == WriteMeasure {
+		f.Pos += len(v)
+		return
+	}
+
+	copy(f.reserve(len(v)), v)
+}
+
+// WriteStringBytes copies the bytes of the string into the destination, like WriteSlice, but without converting
+// the string into a slice first.
+func (f *LittleEndianBuffer) WriteStringBytes(v string) {
+	if f.Mode == WriteMeasure {
+		f.Pos += len(v)
+		return
+	}
+
+	copy(f.reserve(len(v)), v)
 }
 
 // ReadSlice reads fully into the given buffer
@@ -382,6 +453,64 @@ func (f *LittleEndianBuffer) WriteFloat64(v float64) {
 	f.WriteUint64(bits)
 }
 
+// ReadUvarint reads a variable length integer, up to 10 bytes using zig-zag protobuf encoding.
+func (f *LittleEndianBuffer) ReadUvarint() uint64 {
+	v, n := binary.Uvarint(f.Bytes[f.Pos:])
+	if n <= 0 {
+		panic("invalid uvarint at " + strconv.Itoa(f.Pos))
+	}
+
+	f.Pos += n
+	return v
+}
+
+// WriteUvarint writes a variable length integer, up to 10 bytes using zig-zag protobuf encoding.
+func (f *LittleEndianBuffer) WriteUvarint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	f.WriteSlice(tmp[:n])
+}
+
+// ReadVarint reads a variable length and signed integer, up to 10 bytes using zig-zag protobuf encoding.
+func (f *LittleEndianBuffer) ReadVarint() int64 {
+	v, n := binary.Varint(f.Bytes[f.Pos:])
+	if n <= 0 {
+		panic("invalid varint at " + strconv.Itoa(f.Pos))
+	}
+
+	f.Pos += n
+	return v
+}
+
+// WriteVarint writes a variable length and signed integer, up to 10 bytes using zig-zag protobuf encoding.
+func (f *LittleEndianBuffer) WriteVarint(v int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(tmp[:], v)
+	f.WriteSlice(tmp[:n])
+}
+
+// ReadComplex64 reads two float32 IEEE 754 4 byte bit sequences for the real and imaginary parts.
+func (f *LittleEndianBuffer) ReadComplex64() complex64 {
+	return complex(f.ReadFloat32(), f.ReadFloat32())
+}
+
+// ReadComplex128 reads two float64 IEEE 754 8 byte bit sequences for the real and imaginary parts.
+func (f *LittleEndianBuffer) ReadComplex128() complex128 {
+	return complex(f.ReadFloat64(), f.ReadFloat64())
+}
+
+// WriteComplex64 writes two float32 IEEE 754 4 byte bit sequences.
+func (f *LittleEndianBuffer) WriteComplex64(v complex64) {
+	f.WriteFloat32(real(v))
+	f.WriteFloat32(imag(v))
+}
+
+// WriteComplex128 writes two float64 IEEE 754 8 byte bit sequences.
+func (f *LittleEndianBuffer) WriteComplex128(v complex128) {
+	f.WriteFloat64(real(v))
+	f.WriteFloat64(imag(v))
+}
+
 // WriteType writes the type as uint8
 func (f *LittleEndianBuffer) WriteType(typ Type) {
 	f.WriteUint8(uint8(typ))
@@ -391,7 +520,9 @@ func (f *LittleEndianBuffer) ReadType() Type {
 	return Type(f.ReadUint8())
 }
 
-var drainJumpTable = [29]int{
+// drainJumpTable contains the fixed size of each type or 0, if the size must be determined by Drain. It covers
+// every possible byte, so that invalid types do not cause an out of bounds access.
+var drainJumpTable = [256]int{
 	0, // undefined
 	1, // TUint8      Type = 1
 	2, // TUint16     Type = 2
@@ -426,6 +557,13 @@ var drainJumpTable = [29]int{
 	8,  // TComplex64  Type = 27
 	16, // TComplex128 Type = 28
 
+	1, // TBool       Type = 29
+	0, // TNil        Type = 30
+	0, // TVarint     Type = 31
+	0, // TUvarint    Type = 32
+	0, // TArray      Type = 33
+	0, // TMap        Type = 34
+	0, // TRecord     Type = 35
 }
 
 // DrainFast uses an inlineable jump table for fixed types and returns -1 for unsupported types. In that case, you
@@ -440,8 +578,14 @@ func (f *LittleEndianBuffer) DrainFast(t Type) int {
 	return -1
 }
 
-// Drain moves the buffer position the right amount of bytes without actually parsing it
+// Drain moves the buffer position the right amount of bytes without actually parsing it. Containers are skipped
+// including all nested values, so that unknown fields can be ignored.
 func (f *LittleEndianBuffer) Drain(t Type) int {
+	return f.drain(t, 0)
+}
+
+// drain implements Drain for a value nested in depth containers.
+func (f *LittleEndianBuffer) drain(t Type, depth int) int {
 	oldPos := f.Pos
 	switch t {
 	case TInt8:
@@ -460,6 +604,18 @@ func (f *LittleEndianBuffer) Drain(t Type) int {
 		fallthrough
 	case TUint32:
 		f.Pos += 4
+	case TInt40:
+		fallthrough
+	case TUint40:
+		f.Pos += 5
+	case TInt48:
+		fallthrough
+	case TUint48:
+		f.Pos += 6
+	case TInt56:
+		fallthrough
+	case TUint56:
+		f.Pos += 7
 	case TInt64:
 		fallthrough
 	case TUint64:
@@ -488,8 +644,45 @@ func (f *LittleEndianBuffer) Drain(t Type) int {
 		f.Pos += 4
 	case TFloat64:
 		f.Pos += 8
+	case TComplex64:
+		f.Pos += 8
+	case TComplex128:
+		f.Pos += 16
+	case TBool:
+		f.Pos++
+	case TNil:
+	case TVarint:
+		fallthrough
+	case TUvarint:
+		f.ReadUvarint()
+	case TArray:
+		f.drainValues(f.ReadUvarint(), depth)
+	case TMap:
+		n := f.ReadUvarint()
+		if n > math.MaxUint64/2 {
+			panic("invalid map size " + strconv.FormatUint(n, 10))
+		}
+
+		f.drainValues(2*n, depth)
+	case TRecord:
+		vLen := int(f.ReadUint32())
+		f.Pos += vLen
 	default:
 		panic("not implemented " + strconv.Itoa(int(t)))
 	}
 	return f.Pos - oldPos
 }
+
+// drainValues skips n type prefixed values of a container nested in depth other containers.
+func (f *LittleEndianBuffer) drainValues(n uint64, depth int) {
+	if depth >= maxTypedDepth {
+		panic("containers nested deeper than " + strconv.Itoa(maxTypedDepth))
+	}
+
+	for i := uint64(0); i < n; i++ {
+		t := f.ReadType()
+		if f.DrainFast(t) < 0 {
+			f.drain(t, depth+1)
+		}
+	}
+}
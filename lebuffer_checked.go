@@ -0,0 +1,609 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unsafe"
+)
+
+// CheckedLittleEndianBuffer has the same methods and the same format as the LittleEndianBuffer, but checks any
+// access instead of panicking. The first error is recorded, like a Decoder does, and all subsequent calls
+// are a no-op, so that a bunch of values can be read and the error is inspected only once at the end. The
+// unchecked fast path is still available through the embedded LittleEndianBuffer.
+type CheckedLittleEndianBuffer struct {
+	LittleEndianBuffer
+	firstErr error
+}
+
+// NewCheckedLittleEndianBuffer creates a checked buffer, which reads from and writes into b.
+func NewCheckedLittleEndianBuffer(b []byte) *CheckedLittleEndianBuffer {
+	return &CheckedLittleEndianBuffer{LittleEndianBuffer: LittleEndianBuffer{Bytes: b}}
+}
+
+// Error returns the first occurred error, which is either a BufferOverrun, a BlobTooLarge or a malformed value.
+func (f *CheckedLittleEndianBuffer) Error() error {
+	return f.firstErr
+}
+
+// Reset removes any error state.
+func (f *CheckedLittleEndianBuffer) Reset() {
+	f.firstErr = nil
+}
+
+// ensure returns true, if n bytes are available at the current position. Otherwise a BufferOverrun is recorded.
+func (f *CheckedLittleEndianBuffer) ensure(n int) bool {
+	if f.firstErr != nil {
+		return false
+	}
+
+	if f.Pos < 0 || n < 0 || n > len(f.Bytes)-f.Pos {
+		f.noteErr(BufferOverrun{Pos: f.Pos, Len: n, Missing: f.Pos + n - len(f.Bytes)})
+		return false
+	}
+
+	return true
+}
+
+func (f *CheckedLittleEndianBuffer) noteErr(err error) {
+	if f.firstErr == nil {
+		f.firstErr = err
+	}
+}
+
+func (f *CheckedLittleEndianBuffer) ReadUint8() uint8 {
+	if !f.ensure(1) {
+		return 0
+	}
+
+	return f.LittleEndianBuffer.ReadUint8()
+}
+
+func (f *CheckedLittleEndianBuffer) WriteUint8(v uint8) {
+	if f.ensure(1) {
+		f.LittleEndianBuffer.WriteUint8(v)
+	}
+}
+
+// ReadUint16 reads 2 bytes.
+func (f *CheckedLittleEndianBuffer) ReadUint16() uint16 {
+	if !f.ensure(2) {
+		return 0
+	}
+
+	return f.LittleEndianBuffer.ReadUint16()
+}
+
+// WriteUint16 writes 2 bytes.
+func (f *CheckedLittleEndianBuffer) WriteUint16(v uint16) {
+	if f.ensure(2) {
+		f.LittleEndianBuffer.WriteUint16(v)
+	}
+}
+
+// ReadUint24 reads 3 bytes.
+func (f *CheckedLittleEndianBuffer) ReadUint24() uint32 {
+	if !f.ensure(3) {
+		return 0
+	}
+
+	return f.LittleEndianBuffer.ReadUint24()
+}
+
+// WriteUint24 writes 3 bytes.
+func (f *CheckedLittleEndianBuffer) WriteUint24(v uint32) {
+	if f.ensure(3) {
+		f.LittleEndianBuffer.WriteUint24(v)
+	}
+}
+
+// ReadUint32 reads 4 bytes.
+func (f *CheckedLittleEndianBuffer) ReadUint32() uint32 {
+	if !f.ensure(4) {
+		return 0
+	}
+
+	return f.LittleEndianBuffer.ReadUint32()
+}
+
+// WriteUint32 writes 4 bytes.
+func (f *CheckedLittleEndianBuffer) WriteUint32(v uint32) {
+	if f.ensure(4) {
+		f.LittleEndianBuffer.WriteUint32(v)
+	}
+}
+
+// ReadUint40 reads 5 bytes.
+func (f *CheckedLittleEndianBuffer) ReadUint40() uint64 {
+	if !f.ensure(5) {
+		return 0
+	}
+
+	return f.LittleEndianBuffer.ReadUint40()
+}
+
+// WriteUint40 writes 5 bytes.
+func (f *CheckedLittleEndianBuffer) WriteUint40(v uint64) {
+	if f.ensure(5) {
+		f.LittleEndianBuffer.WriteUint40(v)
+	}
+}
+
+// ReadUint48 reads 6 bytes.
+func (f *CheckedLittleEndianBuffer) ReadUint48() uint64 {
+	if !f.ensure(6) {
+		return 0
+	}
+
+	return f.LittleEndianBuffer.ReadUint48()
+}
+
+// WriteUint48 writes 6 bytes.
+func (f *CheckedLittleEndianBuffer) WriteUint48(v uint64) {
+	if f.ensure(6) {
+		f.LittleEndianBuffer.WriteUint48(v)
+	}
+}
+
+// ReadUint56 reads 7 bytes.
+func (f *CheckedLittleEndianBuffer) ReadUint56() uint64 {
+	if !f.ensure(7) {
+		return 0
+	}
+
+	return f.LittleEndianBuffer.ReadUint56()
+}
+
+// WriteUint56 writes 7 bytes.
+func (f *CheckedLittleEndianBuffer) WriteUint56(v uint64) {
+	if f.ensure(7) {
+		f.LittleEndianBuffer.WriteUint56(v)
+	}
+}
+
+// ReadUint64 reads 8 bytes.
+func (f *CheckedLittleEndianBuffer) ReadUint64() uint64 {
+	if !f.ensure(8) {
+		return 0
+	}
+
+	return f.LittleEndianBuffer.ReadUint64()
+}
+
+// WriteUint64 writes 8 bytes.
+func (f *CheckedLittleEndianBuffer) WriteUint64(v uint64) {
+	if f.ensure(8) {
+		f.LittleEndianBuffer.WriteUint64(v)
+	}
+}
+
+// ReadFloat32 reads 4 bytes and interprets them as a float32 IEEE 754 4 byte bit sequence.
+func (f *CheckedLittleEndianBuffer) ReadFloat32() float32 {
+	if !f.ensure(4) {
+		return 0
+	}
+
+	return f.LittleEndianBuffer.ReadFloat32()
+}
+
+// WriteFloat32 writes a float32 IEEE 754 4 byte bit sequence.
+func (f *CheckedLittleEndianBuffer) WriteFloat32(v float32) {
+	if f.ensure(4) {
+		f.LittleEndianBuffer.WriteFloat32(v)
+	}
+}
+
+// ReadFloat64 reads 8 bytes and interprets them as a float64 IEEE 754 8 byte bit sequence.
+func (f *CheckedLittleEndianBuffer) ReadFloat64() float64 {
+	if !f.ensure(8) {
+		return 0
+	}
+
+	return f.LittleEndianBuffer.ReadFloat64()
+}
+
+// WriteFloat64 writes a float64 IEEE 754 8 byte bit sequence.
+func (f *CheckedLittleEndianBuffer) WriteFloat64(v float64) {
+	if f.ensure(8) {
+		f.LittleEndianBuffer.WriteFloat64(v)
+	}
+}
+
+// ReadComplex64 reads two float32 IEEE 754 4 byte bit sequences for the real and imaginary parts.
+func (f *CheckedLittleEndianBuffer) ReadComplex64() complex64 {
+	if !f.ensure(8) {
+		return 0
+	}
+
+	return f.LittleEndianBuffer.ReadComplex64()
+}
+
+// WriteComplex64 writes two float32 IEEE 754 4 byte bit sequences.
+func (f *CheckedLittleEndianBuffer) WriteComplex64(v complex64) {
+	if f.ensure(8) {
+		f.LittleEndianBuffer.WriteComplex64(v)
+	}
+}
+
+// ReadComplex128 reads two float64 IEEE 754 8 byte bit sequences for the real and imaginary parts.
+func (f *CheckedLittleEndianBuffer) ReadComplex128() complex128 {
+	if !f.ensure(16) {
+		return 0
+	}
+
+	return f.LittleEndianBuffer.ReadComplex128()
+}
+
+// WriteComplex128 writes two float64 IEEE 754 8 byte bit sequences.
+func (f *CheckedLittleEndianBuffer) WriteComplex128(v complex128) {
+	if f.ensure(16) {
+		f.LittleEndianBuffer.WriteComplex128(v)
+	}
+}
+
+// ReadUvarint reads a variable length integer, up to 10 bytes using zig-zag protobuf encoding.
+func (f *CheckedLittleEndianBuffer) ReadUvarint() uint64 {
+	if !f.ensure(0) {
+		return 0
+	}
+
+	v, n := binary.Uvarint(f.Bytes[f.Pos:])
+	if !f.checkVarint(n) {
+		return 0
+	}
+
+	f.Pos += n
+
+	return v
+}
+
+// WriteUvarint writes a variable length integer, up to 10 bytes using zig-zag protobuf encoding.
+func (f *CheckedLittleEndianBuffer) WriteUvarint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	f.WriteSlice(tmp[:binary.PutUvarint(tmp[:], v)])
+}
+
+// ReadVarint reads a variable length and signed integer, up to 10 bytes using zig-zag protobuf encoding.
+func (f *CheckedLittleEndianBuffer) ReadVarint() int64 {
+	if !f.ensure(0) {
+		return 0
+	}
+
+	v, n := binary.Varint(f.Bytes[f.Pos:])
+	if !f.checkVarint(n) {
+		return 0
+	}
+
+	f.Pos += n
+
+	return v
+}
+
+// WriteVarint writes a variable length and signed integer, up to 10 bytes using zig-zag protobuf encoding.
+func (f *CheckedLittleEndianBuffer) WriteVarint(v int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	f.WriteSlice(tmp[:binary.PutVarint(tmp[:], v)])
+}
+
+// checkVarint interprets the result of binary.Uvarint and binary.Varint.
+func (f *CheckedLittleEndianBuffer) checkVarint(n int) bool {
+	switch {
+	case n == 0:
+		// the amount of missing bytes is unknown but at least one is missing
+		remaining := len(f.Bytes) - f.Pos
+		f.noteErr(BufferOverrun{Pos: f.Pos, Len: remaining + 1, Missing: 1})
+
+		return false
+	case n < 0:
+		f.noteErr(fmt.Errorf("malformed varint at %d: %w", f.Pos, errVarintOverflow))
+		return false
+	default:
+		return true
+	}
+}
+
+// WriteSlice copies the content of the given buffer into the destination
+func (f *CheckedLittleEndianBuffer) WriteSlice(v []byte) {
+	if f.ensure(len(v)) {
+		f.LittleEndianBuffer.WriteSlice(v)
+	}
+}
+
+// WriteStringBytes copies the bytes of the string into the destination, like WriteSlice.
+func (f *CheckedLittleEndianBuffer) WriteStringBytes(v string) {
+	if f.ensure(len(v)) {
+		f.LittleEndianBuffer.WriteStringBytes(v)
+	}
+}
+
+// ReadSlice reads fully into the given buffer
+func (f *CheckedLittleEndianBuffer) ReadSlice(v []byte) {
+	if f.ensure(len(v)) {
+		f.LittleEndianBuffer.ReadSlice(v)
+	}
+}
+
+// ReadBlob8 reads up to 255 bytes. In contrast to the unchecked variant, a BlobTooLarge is recorded if the
+// blob does not fit into v.
+func (f *CheckedLittleEndianBuffer) ReadBlob8(v []byte) int {
+	return f.readBlob(v, int(f.ReadUint8()))
+}
+
+// WriteBlob8 writes up to 255 bytes. The blob is truncated.
+func (f *CheckedLittleEndianBuffer) WriteBlob8(v []byte) {
+	if f.ensure(1 + minLen(v, int(MaxUint8))) {
+		f.LittleEndianBuffer.WriteBlob8(v)
+	}
+}
+
+// ReadBlob16 reads up to 65535 bytes. In contrast to the unchecked variant, a BlobTooLarge is recorded if the
+// blob does not fit into v.
+func (f *CheckedLittleEndianBuffer) ReadBlob16(v []byte) int {
+	return f.readBlob(v, int(f.ReadUint16()))
+}
+
+// WriteBlob16 writes up to 65535 bytes. The blob is truncated.
+func (f *CheckedLittleEndianBuffer) WriteBlob16(v []byte) {
+	if f.ensure(2 + minLen(v, int(MaxUint16))) {
+		f.LittleEndianBuffer.WriteBlob16(v)
+	}
+}
+
+// ReadBlob24 reads up to 16777215 bytes. In contrast to the unchecked variant, a BlobTooLarge is recorded if the
+// blob does not fit into v.
+func (f *CheckedLittleEndianBuffer) ReadBlob24(v []byte) int {
+	return f.readBlob(v, int(f.ReadUint24()))
+}
+
+// WriteBlob24 writes up to 16777215 bytes. The blob is truncated.
+func (f *CheckedLittleEndianBuffer) WriteBlob24(v []byte) {
+	if f.ensure(3 + minLen(v, int(MaxUint24))) {
+		f.LittleEndianBuffer.WriteBlob24(v)
+	}
+}
+
+// ReadBlob32 reads up to 4294967295 bytes. In contrast to the unchecked variant, a BlobTooLarge is recorded if
+// the blob does not fit into v.
+func (f *CheckedLittleEndianBuffer) ReadBlob32(v []byte) int {
+	return f.readBlob(v, int(f.ReadUint32()))
+}
+
+// WriteBlob32 writes up to 4294967295 bytes. The blob is truncated.
+func (f *CheckedLittleEndianBuffer) WriteBlob32(v []byte) {
+	if f.ensure(4 + minLen(v, int(MaxUint32))) {
+		f.LittleEndianBuffer.WriteBlob32(v)
+	}
+}
+
+// readBlob reads the payload of a blob, whose length prefix has already been read.
+func (f *CheckedLittleEndianBuffer) readBlob(v []byte, vLen int) int {
+	if !f.ensure(vLen) {
+		return 0
+	}
+
+	if vLen > len(v) {
+		f.noteErr(BlobTooLarge{Len: vLen, Max: len(v)})
+		return 0
+	}
+
+	f.LittleEndianBuffer.ReadSlice(v[:vLen])
+
+	return vLen
+}
+
+// minLen returns the length of v but at most max.
+func minLen(v []byte, max int) int {
+	if len(v) > max {
+		return max
+	}
+
+	return len(v)
+}
+
+// WriteString8 writes the string into a blob, avoiding another allocation.
+func (f *CheckedLittleEndianBuffer) WriteString8(v string) {
+	if f.ensure(1 + minStrLen(v, int(MaxUint8))) {
+		f.LittleEndianBuffer.WriteString8(v)
+	}
+}
+
+// ReadString8 creates a (mutable) string, by using the strBuffer.
+func (f *CheckedLittleEndianBuffer) ReadString8(strBuffer []byte) string {
+	return unsafeString(strBuffer[:f.ReadBlob8(strBuffer)])
+}
+
+// WriteString16 writes the string into a blob, avoiding another allocation.
+func (f *CheckedLittleEndianBuffer) WriteString16(v string) {
+	if f.ensure(2 + minStrLen(v, int(MaxUint16))) {
+		f.LittleEndianBuffer.WriteString16(v)
+	}
+}
+
+// ReadString16 creates a (mutable) string, by using the strBuffer.
+func (f *CheckedLittleEndianBuffer) ReadString16(strBuffer []byte) string {
+	return unsafeString(strBuffer[:f.ReadBlob16(strBuffer)])
+}
+
+// WriteString24 writes the string into a blob, avoiding another allocation.
+func (f *CheckedLittleEndianBuffer) WriteString24(v string) {
+	if f.ensure(3 + minStrLen(v, int(MaxUint24))) {
+		f.LittleEndianBuffer.WriteString24(v)
+	}
+}
+
+// ReadString24 creates a (mutable) string, by using the strBuffer.
+func (f *CheckedLittleEndianBuffer) ReadString24(strBuffer []byte) string {
+	return unsafeString(strBuffer[:f.ReadBlob24(strBuffer)])
+}
+
+// WriteString32 writes the string into a blob, avoiding another allocation.
+func (f *CheckedLittleEndianBuffer) WriteString32(v string) {
+	if f.ensure(4 + minStrLen(v, int(MaxUint32))) {
+		f.LittleEndianBuffer.WriteString32(v)
+	}
+}
+
+// ReadString32 creates a (mutable) string, by using the strBuffer.
+func (f *CheckedLittleEndianBuffer) ReadString32(strBuffer []byte) string {
+	return unsafeString(strBuffer[:f.ReadBlob32(strBuffer)])
+}
+
+// minStrLen returns the length of v but at most max.
+func minStrLen(v string, max int) int {
+	if len(v) > max {
+		return max
+	}
+
+	return len(v)
+}
+
+// unsafeString returns a string which shares the memory with b.
+func unsafeString(b []byte) string {
+	// this hack avoids another allocation for the string, see https://github.com/golang/go/issues/25484
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+// WriteType writes the type as uint8
+func (f *CheckedLittleEndianBuffer) WriteType(typ Type) {
+	f.WriteUint8(uint8(typ))
+}
+
+// ReadType reads the type as uint8
+func (f *CheckedLittleEndianBuffer) ReadType() Type {
+	return Type(f.ReadUint8())
+}
+
+// DrainFast uses the jump table for fixed types and returns -1 for unsupported types. In that case, you
+// have to fallback into Drain. If the buffer is too short, the error is recorded and 0 is returned.
+func (f *CheckedLittleEndianBuffer) DrainFast(t Type) int {
+	x := drainJumpTable[t]
+	if x == 0 {
+		return -1
+	}
+
+	if !f.ensure(x) {
+		return 0
+	}
+
+	f.Pos += x
+
+	return x
+}
+
+// Drain moves the buffer position the right amount of bytes without actually parsing it. Containers are skipped
+// including all nested values. Unknown types are recorded as an error.
+func (f *CheckedLittleEndianBuffer) Drain(t Type) int {
+	return f.drain(t, 0)
+}
+
+// drain implements Drain for a value nested in depth containers.
+func (f *CheckedLittleEndianBuffer) drain(t Type, depth int) int {
+	oldPos := f.Pos
+
+	switch t {
+	case TArray:
+		f.drainValues(f.ReadUvarint(), depth)
+	case TMap:
+		n := f.ReadUvarint()
+		if n > math.MaxUint64/2 {
+			f.noteErr(IntegerOverflow{Val: n, Max: uint64(math.MaxUint64 / 2)})
+			break
+		}
+
+		f.drainValues(2*n, depth)
+	default:
+		if n, ok := f.valueLen(t); ok && f.ensure(n) {
+			f.Pos += n
+		}
+	}
+
+	return f.Pos - oldPos
+}
+
+// drainValues skips n type prefixed values of a container nested in depth other containers.
+func (f *CheckedLittleEndianBuffer) drainValues(n uint64, depth int) {
+	if depth >= maxTypedDepth {
+		f.noteErr(fmt.Errorf("containers nested deeper than %d at %d", maxTypedDepth, f.Pos))
+		return
+	}
+
+	for i := uint64(0); i < n && f.firstErr == nil; i++ {
+		t := f.ReadType()
+		if f.DrainFast(t) < 0 {
+			f.drain(t, depth+1)
+		}
+	}
+}
+
+// valueLen returns the length of the value at the current position, whose type prefix has already been read.
+// For arrays and maps only the header is considered and for records the length of all fields is included.
+func (f *CheckedLittleEndianBuffer) valueLen(t Type) (int, bool) {
+	if x := drainJumpTable[t]; x != 0 {
+		return x, true
+	}
+
+	var (
+		prefix int
+		vLen   uint64
+	)
+
+	switch t {
+	case TNil:
+		return 0, true
+	case TBlob8, TString8:
+		prefix, vLen = 1, f.peekUint(1)
+	case TBlob16, TString16:
+		prefix, vLen = 2, f.peekUint(2)
+	case TBlob24, TString24:
+		prefix, vLen = 3, f.peekUint(3)
+	case TBlob32, TString32, TRecord:
+		prefix, vLen = 4, f.peekUint(4)
+	case TVarint, TUvarint, TArray, TMap:
+		if !f.ensure(0) {
+			return 0, false
+		}
+
+		_, n := binary.Uvarint(f.Bytes[f.Pos:])
+
+		return n, f.checkVarint(n)
+	default:
+		f.noteErr(fmt.Errorf("unsupported type %s at %d", t, f.Pos))
+		return 0, false
+	}
+
+	if f.firstErr != nil {
+		return 0, false
+	}
+
+	return prefix + int(vLen), true
+}
+
+// peekUint reads an unsigned little endian integer of n bytes without moving the position.
+func (f *CheckedLittleEndianBuffer) peekUint(n int) uint64 {
+	if !f.ensure(n) {
+		return 0
+	}
+
+	var v uint64
+	for i := n - 1; i >= 0; i-- {
+		v = v<<8 | uint64(f.Bytes[f.Pos+i])
+	}
+
+	return v
+}
@@ -0,0 +1,135 @@
+package ioutil
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckedLittleEndianBuffer(t *testing.T) {
+	buf := NewCheckedLittleEndianBuffer(make([]byte, 6))
+	buf.WriteUint32(0xCAFEBABE)
+	buf.WriteUint24(1)
+
+	var overrun BufferOverrun
+	if !errors.As(buf.Error(), &overrun) || overrun.Pos != 4 || overrun.Len != 3 || overrun.Missing != 1 {
+		t.Fatalf("unexpected error %v", buf.Error())
+	}
+
+	if buf.Pos != 4 {
+		t.Fatalf("expected position 4 but got %d", buf.Pos)
+	}
+
+	buf.Reset()
+	buf.Pos = 0
+
+	if v := buf.ReadUint32(); v != 0xCAFEBABE {
+		t.Fatalf("expected %x but got %x", 0xCAFEBABE, v)
+	}
+
+	// a blob, which does not fit into the destination
+	buf = NewCheckedLittleEndianBuffer(make([]byte, 16))
+	buf.WriteBlob8([]byte{1, 2, 3, 4})
+	buf.Pos = 0
+
+	var tooLarge BlobTooLarge
+	if n := buf.ReadBlob8(make([]byte, 2)); n != 0 || !errors.As(buf.Error(), &tooLarge) {
+		t.Fatalf("expected BlobTooLarge but got %d and %v", n, buf.Error())
+	}
+
+	// the unchecked fast path is still available and panics
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+
+	buf.LittleEndianBuffer.Pos = 15
+	buf.LittleEndianBuffer.ReadUint16()
+}
+
+func TestCheckedTypedLittleEndianBuffer(t *testing.T) {
+	data := writeNestedRecord(true)
+
+	// every truncation must be recorded instead of panicking
+	for i := 0; i <= len(data); i++ {
+		buf := NewCheckedTypedLittleEndianBuffer(data[:i])
+		for buf.HasNext() {
+			buf.Next()
+		}
+
+		// the record ends at len(data)-2 and is followed by a bool
+		complete := i == 0 || i == len(data)-2 || i == len(data)
+		if complete != (buf.Error() == nil) {
+			t.Fatalf("%d: unexpected error state %v", i, buf.Error())
+		}
+
+		buf = NewCheckedTypedLittleEndianBuffer(data[:i])
+		buf.Skip()
+		buf.ReadBool()
+
+		if (i == len(data)) != (buf.Error() == nil) {
+			t.Fatalf("%d: unexpected error state after skip %v", i, buf.Error())
+		}
+	}
+
+	buf := NewCheckedTypedLittleEndianBuffer(data)
+	end := buf.ReadRecord()
+
+	if s := buf.ReadString(nil); s != "name" {
+		t.Fatalf("expected name but got %s", s)
+	}
+
+	buf.ReadMap()
+
+	var mismatch TypeMismatch
+	if !errors.As(buf.Error(), &mismatch) || mismatch.Expected != TMap || mismatch.Actual != TArray {
+		t.Fatalf("expected a TypeMismatch but got %v", buf.Error())
+	}
+
+	buf.Reset()
+
+	if n := buf.ReadArray(); n != 3 || end != len(data)-2 {
+		t.Fatalf("unexpected array of %d elements in record ending at %d", n, end)
+	}
+
+	// a hostile count is rejected immediately
+	hostile := NewCheckedTypedLittleEndianBuffer(make([]byte, 16))
+	hostile.WriteArray(1 << 40)
+	hostile.Pos = 0
+
+	if n := hostile.ReadArray(); n != 0 || hostile.Error() == nil {
+		t.Fatalf("expected an error but got %d", n)
+	}
+
+	// hostile nesting and map sizes are rejected while draining
+	deep := NewCheckedLittleEndianBuffer(nestedArrays(maxTypedDepth))
+	if deep.Drain(deep.ReadType()); deep.Error() != nil || deep.Pos != len(deep.Bytes) {
+		t.Fatalf("unexpected error %v at %d", deep.Error(), deep.Pos)
+	}
+
+	deep = NewCheckedLittleEndianBuffer(nestedArrays(maxTypedDepth + 1))
+	if deep.Drain(deep.ReadType()); deep.Error() == nil {
+		t.Fatal("expected an error for excessive nesting")
+	}
+
+	huge := NewCheckedLittleEndianBuffer([]byte{byte(TMap), 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01})
+	if huge.Drain(huge.ReadType()); !errors.As(huge.Error(), &IntegerOverflow{}) {
+		t.Fatalf("expected an IntegerOverflow but got %v", huge.Error())
+	}
+
+	// writes are checked as well
+	out := NewCheckedTypedLittleEndianBuffer(make([]byte, 4))
+	out.WriteInt(-1)
+	out.WriteInt(1 << 20)
+
+	if !errors.As(out.Error(), &BufferOverrun{}) || out.Pos != 2 {
+		t.Fatalf("expected a BufferOverrun at 2 but got %v at %d", out.Error(), out.Pos)
+	}
+
+	raw := NewCheckedLittleEndianBuffer(make([]byte, 2))
+	raw.WriteStringBytes("hello")
+
+	if !errors.As(raw.Error(), &BufferOverrun{}) || raw.Pos != 0 {
+		t.Fatalf("expected a BufferOverrun at 0 but got %v at %d", raw.Error(), raw.Pos)
+	}
+}
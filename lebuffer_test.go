@@ -1,6 +1,8 @@
 package ioutil
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -60,3 +62,127 @@ func BenchmarkLittleEndianBuffer_ReadUint32(b *testing.B) {
 		le.Pos = 0
 	}
 }
+
+func writeGrowSamples(t *TypedLittleEndianBuffer) {
+	for _, v := range []int64{0, -1, 200, -300, 70000, MinInt40, MaxInt64} {
+		t.WriteInt(v)
+	}
+
+	for _, v := range []float64{1.5, 3.14159265, -1e300} {
+		t.WriteFloat(v)
+	}
+
+	for _, n := range []int{0, 255, 256, 70000} {
+		t.WriteString(strings.Repeat("x", n))
+	}
+
+	rec := t.BeginRecord()
+	t.WriteVarint(-12345)
+	t.WriteUvarint(MaxUint64)
+	t.WriteBlob(make([]byte, 300))
+	t.EndRecord(rec)
+}
+
+func TestLittleEndianBuffer_Mode(t *testing.T) {
+	measure := &TypedLittleEndianBuffer{Bytes: []byte{42}, Mode: WriteMeasure}
+	writeGrowSamples(measure)
+
+	if !bytes.Equal(measure.Bytes, []byte{42}) {
+		t.Fatalf("measuring must not touch the buffer")
+	}
+
+	fixed := &TypedLittleEndianBuffer{Bytes: make([]byte, measure.Pos)}
+	writeGrowSamples(fixed)
+
+	if fixed.Pos != measure.Pos {
+		t.Fatalf("expected %d but measured %d", fixed.Pos, measure.Pos)
+	}
+
+	grow := &TypedLittleEndianBuffer{Mode: WriteGrow}
+	writeGrowSamples(grow)
+
+	if !bytes.Equal(fixed.Bytes, grow.Bytes) {
+		t.Fatalf("expected\n%x\nbut got\n%x", fixed.Bytes, grow.Bytes)
+	}
+
+	// a grown buffer can be overwritten in place, without changing its length
+	le := &LittleEndianBuffer{Bytes: make([]byte, 0, 1), Mode: WriteGrow}
+	le.WriteUint64(1)
+	le.WriteUint8(2)
+	le.Pos = 0
+	le.WriteUint16(3)
+
+	if len(le.Bytes) != 9 || cap(le.Bytes) < 9 || le.Bytes[0] != 3 || le.Bytes[8] != 2 {
+		t.Fatalf("unexpected buffer %x", le.Bytes)
+	}
+}
+
+// nestedArrays returns depth arrays, each containing the next one and the innermost one containing a uint8.
+func nestedArrays(depth int) []byte {
+	buf := &TypedLittleEndianBuffer{Mode: WriteGrow}
+	for i := 0; i < depth; i++ {
+		buf.WriteArray(1)
+	}
+
+	buf.WriteUint8(1)
+
+	return buf.Bytes
+}
+
+func TestLittleEndianBuffer_Drain(t *testing.T) {
+	buf := &LittleEndianBuffer{Bytes: nestedArrays(maxTypedDepth)}
+	if n := buf.Drain(buf.ReadType()); buf.Pos != len(buf.Bytes) || n != len(buf.Bytes)-1 {
+		t.Fatalf("expected to drain %d bytes but got %d", len(buf.Bytes)-1, n)
+	}
+
+	invalid := [][]byte{
+		nestedArrays(maxTypedDepth + 1),
+		{byte(TMap), 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}, // 1<<63 entries
+	}
+
+	for _, data := range invalid {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("expected a panic for %x", data[:11])
+				}
+			}()
+
+			buf := &LittleEndianBuffer{Bytes: data}
+			buf.Drain(buf.ReadType())
+		}()
+	}
+}
+
+func TestLittleEndianBuffer_Overrun(t *testing.T) {
+	writes := []func(le *LittleEndianBuffer){
+		func(le *LittleEndianBuffer) { le.WriteSlice([]byte{1, 2, 3, 4, 5}) },
+		func(le *LittleEndianBuffer) { le.WriteStringBytes("hello") },
+		func(le *LittleEndianBuffer) { le.WriteUint32(1) },
+		func(le *LittleEndianBuffer) { le.WriteUvarint(1 << 32) },
+	}
+
+	for i, write := range writes {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("%d: expected a panic", i)
+				}
+			}()
+
+			write(&LittleEndianBuffer{Bytes: make([]byte, 2)})
+		}()
+	}
+}
+
+func BenchmarkLittleEndianBuffer_WriteUint32(b *testing.B) {
+	le := LittleEndianBuffer{Bytes: make([]byte, 20)}
+	for n := 0; n < b.N; n++ {
+		le.WriteUint32(1)
+		le.WriteUint32(2)
+		le.WriteUint32(3)
+		le.WriteUint32(4)
+		le.WriteUint32(5)
+		le.Pos = 0
+	}
+}
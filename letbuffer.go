@@ -5,7 +5,8 @@ import (
 )
 
 // TypedLittleEndianBuffer is a light weight helper to modify bytes within a buffer in little endian format and
-// each written type has a type prefix.
+// each written type has a type prefix. Its Mode applies to the variable width writes as well, so the
+// encoded length of e.g. WriteInt or WriteString can be measured upfront.
 type TypedLittleEndianBuffer LittleEndianBuffer
 
 // WriteFloat inspects the value and chooses automatically between int 1/2/3/4/5/6/7/8 byte signed or signed
@@ -51,7 +52,7 @@ func (t *TypedLittleEndianBuffer) ReadFloat() float64 {
 	case TUint24:
 		return float64(f.ReadUint24())
 	case TInt24:
-		return float64(int32(f.ReadUint24()))
+		return float64(int32(f.ReadUint24()<<8) >> 8)
 
 	case TUint32:
 		return float64(f.ReadUint32())
@@ -61,23 +62,28 @@ func (t *TypedLittleEndianBuffer) ReadFloat() float64 {
 	case TUint40:
 		return float64(f.ReadUint40())
 	case TInt40:
-		return float64(int64(f.ReadUint40()))
+		return float64(int64(f.ReadUint40()<<24) >> 24)
 
 	case TUint48:
 		return float64(f.ReadUint48())
 	case TInt48:
-		return float64(int64(f.ReadUint48()))
+		return float64(int64(f.ReadUint48()<<16) >> 16)
 
 	case TUint56:
 		return float64(f.ReadUint56())
 	case TInt56:
-		return float64(int64(f.ReadUint56()))
+		return float64(int64(f.ReadUint56()<<8) >> 8)
 
 	case TUint64:
 		return float64(f.ReadUint64())
 	case TInt64:
 		return float64(int64(f.ReadUint64()))
 
+	case TVarint:
+		return float64(f.ReadVarint())
+	case TUvarint:
+		return float64(f.ReadUvarint())
+
 	case TFloat32:
 		return float64(f.ReadFloat32())
 	case TFloat64:
@@ -106,7 +112,7 @@ func (t *TypedLittleEndianBuffer) ReadInt() int64 {
 	case TUint24:
 		return int64(f.ReadUint24())
 	case TInt24:
-		return int64(int32(f.ReadUint24()))
+		return int64(int32(f.ReadUint24()<<8) >> 8)
 
 	case TUint32:
 		return int64(f.ReadUint32())
@@ -116,23 +122,28 @@ func (t *TypedLittleEndianBuffer) ReadInt() int64 {
 	case TUint40:
 		return int64(f.ReadUint40())
 	case TInt40:
-		return int64(int64(f.ReadUint40()))
+		return int64(f.ReadUint40()<<24) >> 24
 
 	case TUint48:
 		return int64(f.ReadUint48())
 	case TInt48:
-		return int64(int64(f.ReadUint48()))
+		return int64(f.ReadUint48()<<16) >> 16
 
 	case TUint56:
 		return int64(f.ReadUint56())
 	case TInt56:
-		return int64(int64(f.ReadUint56()))
+		return int64(f.ReadUint56()<<8) >> 8
 
 	case TUint64:
 		return int64(f.ReadUint64())
 	case TInt64:
 		return int64(int64(f.ReadUint64()))
 
+	case TVarint:
+		return f.ReadVarint()
+	case TUvarint:
+		return int64(f.ReadUvarint())
+
 	case TFloat32:
 		return int64(f.ReadFloat32())
 	case TFloat64:
@@ -434,6 +445,18 @@ func (t *TypedLittleEndianBuffer) WriteFloat64(v float64) {
 	f.WriteFloat64(v)
 }
 
+func (t *TypedLittleEndianBuffer) WriteComplex64(v complex64) {
+	f := (*LittleEndianBuffer)(t)
+	f.WriteType(TComplex64)
+	f.WriteComplex64(v)
+}
+
+func (t *TypedLittleEndianBuffer) WriteComplex128(v complex128) {
+	f := (*LittleEndianBuffer)(t)
+	f.WriteType(TComplex128)
+	f.WriteComplex128(v)
+}
+
 func (t *TypedLittleEndianBuffer) WriteBlob8(v []byte) {
 	f := (*LittleEndianBuffer)(t)
 	f.WriteType(TBlob8)
@@ -491,7 +514,7 @@ func (t *TypedLittleEndianBuffer) ReadUint24() uint32 {
 func (t *TypedLittleEndianBuffer) ReadInt24() int32 {
 	f := (*LittleEndianBuffer)(t)
 	t.assertType(TInt24)
-	return int32(f.ReadUint24())
+	return int32(f.ReadUint24()<<8) >> 8
 }
 
 func (t *TypedLittleEndianBuffer) ReadUint32() uint32 {
@@ -506,6 +529,42 @@ func (t *TypedLittleEndianBuffer) ReadInt32() int32 {
 	return int32(f.ReadUint32())
 }
 
+func (t *TypedLittleEndianBuffer) ReadUint40() uint64 {
+	f := (*LittleEndianBuffer)(t)
+	t.assertType(TUint40)
+	return f.ReadUint40()
+}
+
+func (t *TypedLittleEndianBuffer) ReadInt40() int64 {
+	f := (*LittleEndianBuffer)(t)
+	t.assertType(TInt40)
+	return int64(f.ReadUint40()<<24) >> 24
+}
+
+func (t *TypedLittleEndianBuffer) ReadUint48() uint64 {
+	f := (*LittleEndianBuffer)(t)
+	t.assertType(TUint48)
+	return f.ReadUint48()
+}
+
+func (t *TypedLittleEndianBuffer) ReadInt48() int64 {
+	f := (*LittleEndianBuffer)(t)
+	t.assertType(TInt48)
+	return int64(f.ReadUint48()<<16) >> 16
+}
+
+func (t *TypedLittleEndianBuffer) ReadUint56() uint64 {
+	f := (*LittleEndianBuffer)(t)
+	t.assertType(TUint56)
+	return f.ReadUint56()
+}
+
+func (t *TypedLittleEndianBuffer) ReadInt56() int64 {
+	f := (*LittleEndianBuffer)(t)
+	t.assertType(TInt56)
+	return int64(f.ReadUint56()<<8) >> 8
+}
+
 func (t *TypedLittleEndianBuffer) ReadUint64() uint64 {
 	f := (*LittleEndianBuffer)(t)
 	t.assertType(TUint64)
@@ -554,6 +613,144 @@ func (t *TypedLittleEndianBuffer) ReadFloat64() float64 {
 	return f.ReadFloat64()
 }
 
+func (t *TypedLittleEndianBuffer) ReadComplex64() complex64 {
+	f := (*LittleEndianBuffer)(t)
+	t.assertType(TComplex64)
+	return f.ReadComplex64()
+}
+
+func (t *TypedLittleEndianBuffer) ReadComplex128() complex128 {
+	f := (*LittleEndianBuffer)(t)
+	t.assertType(TComplex128)
+	return f.ReadComplex128()
+}
+
+func (t *TypedLittleEndianBuffer) WriteBool(v bool) {
+	f := (*LittleEndianBuffer)(t)
+	f.WriteType(TBool)
+	if v {
+		f.WriteUint8(1)
+	} else {
+		f.WriteUint8(0)
+	}
+}
+
+func (t *TypedLittleEndianBuffer) ReadBool() bool {
+	f := (*LittleEndianBuffer)(t)
+	t.assertType(TBool)
+	return f.ReadUint8() != 0
+}
+
+// WriteNil writes a value without any payload, e.g. to denote an absent optional field.
+func (t *TypedLittleEndianBuffer) WriteNil() {
+	f := (*LittleEndianBuffer)(t)
+	f.WriteType(TNil)
+}
+
+func (t *TypedLittleEndianBuffer) ReadNil() {
+	t.assertType(TNil)
+}
+
+func (t *TypedLittleEndianBuffer) WriteVarint(v int64) {
+	f := (*LittleEndianBuffer)(t)
+	f.WriteType(TVarint)
+	f.WriteVarint(v)
+}
+
+func (t *TypedLittleEndianBuffer) ReadVarint() int64 {
+	f := (*LittleEndianBuffer)(t)
+	t.assertType(TVarint)
+	return f.ReadVarint()
+}
+
+func (t *TypedLittleEndianBuffer) WriteUvarint(v uint64) {
+	f := (*LittleEndianBuffer)(t)
+	f.WriteType(TUvarint)
+	f.WriteUvarint(v)
+}
+
+func (t *TypedLittleEndianBuffer) ReadUvarint() uint64 {
+	f := (*LittleEndianBuffer)(t)
+	t.assertType(TUvarint)
+	return f.ReadUvarint()
+}
+
+// WriteArray writes the header of an array with n elements. The caller must write exactly n typed values
+// afterwards, which may be of different types and may be containers again.
+func (t *TypedLittleEndianBuffer) WriteArray(n int) {
+	f := (*LittleEndianBuffer)(t)
+	f.WriteType(TArray)
+	f.WriteUvarint(uint64(n))
+}
+
+// ReadArray reads the header of an array and returns the amount of elements, which follow.
+func (t *TypedLittleEndianBuffer) ReadArray() int {
+	f := (*LittleEndianBuffer)(t)
+	t.assertType(TArray)
+	return int(f.ReadUvarint())
+}
+
+// WriteMap writes the header of a map with n entries. The caller must write exactly n typed keys and values
+// afterwards, alternating key and value.
+func (t *TypedLittleEndianBuffer) WriteMap(n int) {
+	f := (*LittleEndianBuffer)(t)
+	f.WriteType(TMap)
+	f.WriteUvarint(uint64(n))
+}
+
+// ReadMap reads the header of a map and returns the amount of entries, which follow.
+func (t *TypedLittleEndianBuffer) ReadMap() int {
+	f := (*LittleEndianBuffer)(t)
+	t.assertType(TMap)
+	return int(f.ReadUvarint())
+}
+
+// BeginRecord writes the header of a record with a placeholder for its length and returns the position
+// which must be passed to EndRecord, after all fields have been written.
+func (t *TypedLittleEndianBuffer) BeginRecord() int {
+	f := (*LittleEndianBuffer)(t)
+	f.WriteType(TRecord)
+	start := f.Pos
+	f.WriteUint32(0)
+	return start
+}
+
+// EndRecord patches the length of the record, which has been started at the given position.
+func (t *TypedLittleEndianBuffer) EndRecord(start int) {
+	f := (*LittleEndianBuffer)(t)
+	end := f.Pos
+	f.Pos = start
+	f.WriteUint32(uint32(end - start - 4))
+	f.Pos = end
+}
+
+// ReadRecord reads the header of a record and returns the position after its last field. A reader should
+// read the known fields and skip all unknown fields until the end has been reached, so that new fields can
+// be appended to a record without breaking older readers:
+//
+//	end := buf.ReadRecord()
+//	for buf.Pos < end {
+//		buf.Skip()
+//	}
+func (t *TypedLittleEndianBuffer) ReadRecord() int {
+	f := (*LittleEndianBuffer)(t)
+	t.assertType(TRecord)
+	n := int(f.ReadUint32())
+	return f.Pos + n
+}
+
+// Skip reads the type of the next value and drains it, including all nested values of containers. It returns
+// the amount of skipped bytes.
+func (t *TypedLittleEndianBuffer) Skip() int {
+	f := (*LittleEndianBuffer)(t)
+	oldPos := f.Pos
+	typ := f.ReadType()
+	if f.DrainFast(typ) < 0 {
+		f.Drain(typ)
+	}
+	return f.Pos - oldPos
+}
+
 func (t *TypedLittleEndianBuffer) assertType(kind Type) {
 	if debug{
 		f := (*LittleEndianBuffer)(t)
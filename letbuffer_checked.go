@@ -0,0 +1,720 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// CheckedTypedLittleEndianBuffer has the same methods and the same format as the TypedLittleEndianBuffer, but
+// checks any access and each type prefix instead of panicking. The first error is recorded, which is either a
+// BufferOverrun, a TypeMismatch, a BlobTooLarge or a malformed value, and all subsequent calls are a no-op.
+type CheckedTypedLittleEndianBuffer CheckedLittleEndianBuffer
+
+// NewCheckedTypedLittleEndianBuffer creates a checked typed buffer, which reads from and writes into b.
+func NewCheckedTypedLittleEndianBuffer(b []byte) *CheckedTypedLittleEndianBuffer {
+	return &CheckedTypedLittleEndianBuffer{LittleEndianBuffer: LittleEndianBuffer{Bytes: b}}
+}
+
+// Error returns the first occurred error.
+func (t *CheckedTypedLittleEndianBuffer) Error() error {
+	return t.firstErr
+}
+
+// Reset removes any error state.
+func (t *CheckedTypedLittleEndianBuffer) Reset() {
+	t.firstErr = nil
+}
+
+// HasNext returns true, if the buffer contains more values and no error occurred.
+func (t *CheckedTypedLittleEndianBuffer) HasNext() bool {
+	return t.firstErr == nil && t.Pos < len(t.Bytes)
+}
+
+// Next reads the type prefix and the following value, see also TypedLittleEndianBuffer.Next. If the value is
+// malformed or truncated, the error is recorded and 0 and nil are returned.
+func (t *CheckedTypedLittleEndianBuffer) Next() (Type, Value) {
+	if _, ok := t.checkNext(); !ok {
+		return 0, nil
+	}
+
+	return t.unchecked().Next()
+}
+
+// Skip reads the type of the next value and drains it, including all nested values of containers. It returns
+// the amount of skipped bytes.
+func (t *CheckedTypedLittleEndianBuffer) Skip() int {
+	f := t.checked()
+	oldPos := f.Pos
+
+	typ := f.ReadType()
+	if f.DrainFast(typ) < 0 {
+		f.Drain(typ)
+	}
+
+	return f.Pos - oldPos
+}
+
+// ReadInt reads any number into an integer.
+func (t *CheckedTypedLittleEndianBuffer) ReadInt() int64 {
+	if !t.checkNumber() {
+		return 0
+	}
+
+	return t.unchecked().ReadInt()
+}
+
+// ReadFloat reads any number into a float.
+func (t *CheckedTypedLittleEndianBuffer) ReadFloat() float64 {
+	if !t.checkNumber() {
+		return 0
+	}
+
+	return t.unchecked().ReadFloat()
+}
+
+// WriteInt chooses the smallest representation, see also TypedLittleEndianBuffer.WriteInt.
+func (t *CheckedTypedLittleEndianBuffer) WriteInt(v int64) {
+	var tmp [9]byte
+
+	scratch := &TypedLittleEndianBuffer{Bytes: tmp[:]}
+	scratch.WriteInt(v)
+	t.checked().WriteSlice(tmp[:scratch.Pos])
+}
+
+// WriteFloat chooses the smallest representation, see also TypedLittleEndianBuffer.WriteFloat.
+func (t *CheckedTypedLittleEndianBuffer) WriteFloat(v float64) {
+	var tmp [9]byte
+
+	scratch := &TypedLittleEndianBuffer{Bytes: tmp[:]}
+	scratch.WriteFloat(v)
+	t.checked().WriteSlice(tmp[:scratch.Pos])
+}
+
+// WriteString chooses the smallest length prefix, see also TypedLittleEndianBuffer.WriteString.
+func (t *CheckedTypedLittleEndianBuffer) WriteString(str string) {
+	if t.checked().ensure(1 + blobPrefixSize(stringType(len(str))) + len(str)) {
+		t.unchecked().WriteString(str)
+	}
+}
+
+// ReadString reads a string8/16/24 or 32 string into the strBuffer and returns a mutable string from it. If
+// strBuffer is nil, a buffer of the required size is allocated.
+func (t *CheckedTypedLittleEndianBuffer) ReadString(strBuffer []byte) string {
+	b := t.readBlob(strBuffer, TString8)
+	return unsafeString(b)
+}
+
+// WriteBlob chooses the smallest length prefix, see also TypedLittleEndianBuffer.WriteBlob.
+func (t *CheckedTypedLittleEndianBuffer) WriteBlob(b []byte) {
+	if t.checked().ensure(1 + blobPrefixSize(stringType(len(b))) + len(b)) {
+		t.unchecked().WriteBlob(b)
+	}
+}
+
+// ReadBlob reads a blob8/16/24 or 32 into the buffer and returns the length.
+func (t *CheckedTypedLittleEndianBuffer) ReadBlob(b []byte) int {
+	return len(t.readBlob(b, TBlob8))
+}
+
+// stringType returns the smallest string type for the length n.
+func stringType(n int) Type {
+	switch {
+	case n <= int(MaxUint8):
+		return TString8
+	case n <= int(MaxUint16):
+		return TString16
+	case n <= int(MaxUint24):
+		return TString24
+	default:
+		return TString32
+	}
+}
+
+// readBlob reads any blob or string type, depending on the given base type. If dst is nil, a new buffer
+// is allocated.
+func (t *CheckedTypedLittleEndianBuffer) readBlob(dst []byte, base Type) []byte {
+	f := t.checked()
+	if !f.ensure(1) {
+		return nil
+	}
+
+	typ := Type(f.Bytes[f.Pos])
+	if typ < base || typ > base+3 {
+		f.noteErr(TypeMismatch{Pos: f.Pos, Expected: base, Actual: typ})
+		return nil
+	}
+
+	f.Pos++
+
+	var vLen int
+
+	switch typ - base {
+	case 0:
+		vLen = int(f.ReadUint8())
+	case 1:
+		vLen = int(f.ReadUint16())
+	case 2:
+		vLen = int(f.ReadUint24())
+	default:
+		vLen = int(f.ReadUint32())
+	}
+
+	if dst == nil && f.ensure(vLen) {
+		dst = make([]byte, vLen)
+	}
+
+	return dst[:f.readBlob(dst, vLen)]
+}
+
+// WriteArray writes the header of an array with n elements.
+func (t *CheckedTypedLittleEndianBuffer) WriteArray(n int) {
+	t.writeHeader(TArray, uint64(n))
+}
+
+// ReadArray reads the header of an array and returns the amount of elements, which follow. A count which
+// cannot be satisfied by the remaining bytes is recorded as BufferOverrun.
+func (t *CheckedTypedLittleEndianBuffer) ReadArray() int {
+	return t.readHeader(TArray, 1)
+}
+
+// WriteMap writes the header of a map with n entries.
+func (t *CheckedTypedLittleEndianBuffer) WriteMap(n int) {
+	t.writeHeader(TMap, uint64(n))
+}
+
+// ReadMap reads the header of a map and returns the amount of entries, which follow. A count which cannot be
+// satisfied by the remaining bytes is recorded as BufferOverrun.
+func (t *CheckedTypedLittleEndianBuffer) ReadMap() int {
+	return t.readHeader(TMap, 2)
+}
+
+func (t *CheckedTypedLittleEndianBuffer) writeHeader(typ Type, n uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	if t.checked().ensure(1 + binary.PutUvarint(tmp[:], n)) {
+		f := &t.LittleEndianBuffer
+		f.WriteType(typ)
+		f.WriteUvarint(n)
+	}
+}
+
+// readHeader reads the count of an array or map, where each element occupies at least minLen bytes.
+func (t *CheckedTypedLittleEndianBuffer) readHeader(typ Type, minLen uint64) int {
+	f := t.checked()
+	if !t.expect(typ) {
+		return 0
+	}
+
+	n := f.ReadUvarint()
+	if remaining := uint64(len(f.Bytes) - f.Pos); n > remaining/minLen {
+		f.noteErr(BufferOverrun{Pos: f.Pos, Len: int(n * minLen), Missing: int(n*minLen - remaining)})
+		return 0
+	}
+
+	return int(n)
+}
+
+// BeginRecord writes the header of a record and returns the position which must be passed to EndRecord.
+func (t *CheckedTypedLittleEndianBuffer) BeginRecord() int {
+	if !t.checked().ensure(5) {
+		return -1
+	}
+
+	return t.unchecked().BeginRecord()
+}
+
+// EndRecord patches the length of the record, which has been started at the given position.
+func (t *CheckedTypedLittleEndianBuffer) EndRecord(start int) {
+	if t.firstErr == nil {
+		t.unchecked().EndRecord(start)
+	}
+}
+
+// ReadRecord reads the header of a record and returns the position after its last field.
+func (t *CheckedTypedLittleEndianBuffer) ReadRecord() int {
+	f := t.checked()
+	if !t.expect(TRecord) {
+		return f.Pos
+	}
+
+	n := int(f.ReadUint32())
+	if !f.ensure(n) {
+		return f.Pos
+	}
+
+	return f.Pos + n
+}
+
+// expect consumes the type prefix, if it equals the given kind. Otherwise a TypeMismatch is recorded.
+func (t *CheckedTypedLittleEndianBuffer) expect(kind Type) bool {
+	f := t.checked()
+	if !f.ensure(1) {
+		return false
+	}
+
+	if actual := Type(f.Bytes[f.Pos]); actual != kind {
+		f.noteErr(TypeMismatch{Pos: f.Pos, Expected: kind, Actual: actual})
+		return false
+	}
+
+	f.Pos++
+
+	return true
+}
+
+// checkNext validates the type and the header of the next value without moving the position.
+func (t *CheckedTypedLittleEndianBuffer) checkNext() (Type, bool) {
+	f := t.checked()
+	if !f.ensure(1) {
+		return 0, false
+	}
+
+	typ := Type(f.Bytes[f.Pos])
+	f.Pos++
+	n, ok := f.valueLen(typ)
+	ok = ok && f.ensure(n)
+	f.Pos--
+
+	return typ, ok
+}
+
+// checkNumber validates that the next value is a complete number.
+func (t *CheckedTypedLittleEndianBuffer) checkNumber() bool {
+	typ, ok := t.checkNext()
+	if ok && !typ.IsNumber() {
+		t.checked().noteErr(fmt.Errorf("expected a number at %d but got %s", t.Pos, typ))
+		return false
+	}
+
+	return ok
+}
+
+func (t *CheckedTypedLittleEndianBuffer) checked() *CheckedLittleEndianBuffer {
+	return (*CheckedLittleEndianBuffer)(t)
+}
+
+func (t *CheckedTypedLittleEndianBuffer) unchecked() *TypedLittleEndianBuffer {
+	return (*TypedLittleEndianBuffer)(&t.LittleEndianBuffer)
+}
+
+// readFixed returns true, if the next value has the type typ and its fixed size value is available.
+func (t *CheckedTypedLittleEndianBuffer) readFixed(typ Type) bool {
+	f := t.checked()
+	if !f.ensure(1) {
+		return false
+	}
+
+	if actual := Type(f.Bytes[f.Pos]); actual != typ {
+		f.noteErr(TypeMismatch{Pos: f.Pos, Expected: typ, Actual: actual})
+		return false
+	}
+
+	return f.ensure(1 + drainJumpTable[typ])
+}
+
+// writeFixed returns true, if the type prefix and the fixed size value of typ fit into the buffer.
+func (t *CheckedTypedLittleEndianBuffer) writeFixed(typ Type) bool {
+	return t.checked().ensure(1 + drainJumpTable[typ])
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteUint8(v uint8) {
+	if t.writeFixed(TUint8) {
+		t.unchecked().WriteUint8(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadUint8() uint8 {
+	if !t.readFixed(TUint8) {
+		return 0
+	}
+
+	return t.unchecked().ReadUint8()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteInt8(v int8) {
+	if t.writeFixed(TInt8) {
+		t.unchecked().WriteInt8(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadInt8() int8 {
+	if !t.readFixed(TInt8) {
+		return 0
+	}
+
+	return t.unchecked().ReadInt8()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteUint16(v uint16) {
+	if t.writeFixed(TUint16) {
+		t.unchecked().WriteUint16(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadUint16() uint16 {
+	if !t.readFixed(TUint16) {
+		return 0
+	}
+
+	return t.unchecked().ReadUint16()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteInt16(v int16) {
+	if t.writeFixed(TInt16) {
+		t.unchecked().WriteInt16(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadInt16() int16 {
+	if !t.readFixed(TInt16) {
+		return 0
+	}
+
+	return t.unchecked().ReadInt16()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteUint24(v uint32) {
+	if t.writeFixed(TUint24) {
+		t.unchecked().WriteUint24(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadUint24() uint32 {
+	if !t.readFixed(TUint24) {
+		return 0
+	}
+
+	return t.unchecked().ReadUint24()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteInt24(v int32) {
+	if t.writeFixed(TInt24) {
+		t.unchecked().WriteInt24(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadInt24() int32 {
+	if !t.readFixed(TInt24) {
+		return 0
+	}
+
+	return t.unchecked().ReadInt24()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteUint32(v uint32) {
+	if t.writeFixed(TUint32) {
+		t.unchecked().WriteUint32(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadUint32() uint32 {
+	if !t.readFixed(TUint32) {
+		return 0
+	}
+
+	return t.unchecked().ReadUint32()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteInt32(v int32) {
+	if t.writeFixed(TInt32) {
+		t.unchecked().WriteInt32(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadInt32() int32 {
+	if !t.readFixed(TInt32) {
+		return 0
+	}
+
+	return t.unchecked().ReadInt32()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteUint40(v uint64) {
+	if t.writeFixed(TUint40) {
+		t.unchecked().WriteUint40(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadUint40() uint64 {
+	if !t.readFixed(TUint40) {
+		return 0
+	}
+
+	return t.unchecked().ReadUint40()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteInt40(v int64) {
+	if t.writeFixed(TInt40) {
+		t.unchecked().WriteInt40(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadInt40() int64 {
+	if !t.readFixed(TInt40) {
+		return 0
+	}
+
+	return t.unchecked().ReadInt40()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteUint48(v uint64) {
+	if t.writeFixed(TUint48) {
+		t.unchecked().WriteUint48(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadUint48() uint64 {
+	if !t.readFixed(TUint48) {
+		return 0
+	}
+
+	return t.unchecked().ReadUint48()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteInt48(v int64) {
+	if t.writeFixed(TInt48) {
+		t.unchecked().WriteInt48(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadInt48() int64 {
+	if !t.readFixed(TInt48) {
+		return 0
+	}
+
+	return t.unchecked().ReadInt48()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteUint56(v uint64) {
+	if t.writeFixed(TUint56) {
+		t.unchecked().WriteUint56(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadUint56() uint64 {
+	if !t.readFixed(TUint56) {
+		return 0
+	}
+
+	return t.unchecked().ReadUint56()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteInt56(v int64) {
+	if t.writeFixed(TInt56) {
+		t.unchecked().WriteInt56(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadInt56() int64 {
+	if !t.readFixed(TInt56) {
+		return 0
+	}
+
+	return t.unchecked().ReadInt56()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteUint64(v uint64) {
+	if t.writeFixed(TUint64) {
+		t.unchecked().WriteUint64(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadUint64() uint64 {
+	if !t.readFixed(TUint64) {
+		return 0
+	}
+
+	return t.unchecked().ReadUint64()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteInt64(v int64) {
+	if t.writeFixed(TInt64) {
+		t.unchecked().WriteInt64(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadInt64() int64 {
+	if !t.readFixed(TInt64) {
+		return 0
+	}
+
+	return t.unchecked().ReadInt64()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteFloat32(v float32) {
+	if t.writeFixed(TFloat32) {
+		t.unchecked().WriteFloat32(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadFloat32() float32 {
+	if !t.readFixed(TFloat32) {
+		return 0
+	}
+
+	return t.unchecked().ReadFloat32()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteFloat64(v float64) {
+	if t.writeFixed(TFloat64) {
+		t.unchecked().WriteFloat64(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadFloat64() float64 {
+	if !t.readFixed(TFloat64) {
+		return 0
+	}
+
+	return t.unchecked().ReadFloat64()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteComplex64(v complex64) {
+	if t.writeFixed(TComplex64) {
+		t.unchecked().WriteComplex64(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadComplex64() complex64 {
+	if !t.readFixed(TComplex64) {
+		return 0
+	}
+
+	return t.unchecked().ReadComplex64()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteComplex128(v complex128) {
+	if t.writeFixed(TComplex128) {
+		t.unchecked().WriteComplex128(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadComplex128() complex128 {
+	if !t.readFixed(TComplex128) {
+		return 0
+	}
+
+	return t.unchecked().ReadComplex128()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteBool(v bool) {
+	if t.writeFixed(TBool) {
+		t.unchecked().WriteBool(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadBool() bool {
+	if !t.readFixed(TBool) {
+		return false
+	}
+
+	return t.unchecked().ReadBool()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteNil() {
+	if t.checked().ensure(1) {
+		t.unchecked().WriteNil()
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadNil() {
+	t.expect(TNil)
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteVarint(v int64) {
+	var tmp [binary.MaxVarintLen64]byte
+	if t.checked().ensure(1 + binary.PutVarint(tmp[:], v)) {
+		t.unchecked().WriteVarint(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadVarint() int64 {
+	if !t.expect(TVarint) {
+		return 0
+	}
+
+	return t.checked().ReadVarint()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteUvarint(v uint64) {
+	t.writeHeader(TUvarint, v)
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadUvarint() uint64 {
+	if !t.expect(TUvarint) {
+		return 0
+	}
+
+	return t.checked().ReadUvarint()
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteBlob8(v []byte) {
+	if t.checked().ensure(2 + minLen(v, int(MaxUint8))) {
+		t.unchecked().WriteBlob8(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadBlob8(dst []byte) int {
+	if !t.expect(TBlob8) {
+		return 0
+	}
+
+	return t.checked().ReadBlob8(dst)
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteBlob16(v []byte) {
+	if t.checked().ensure(3 + minLen(v, int(MaxUint16))) {
+		t.unchecked().WriteBlob16(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadBlob16(dst []byte) int {
+	if !t.expect(TBlob16) {
+		return 0
+	}
+
+	return t.checked().ReadBlob16(dst)
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteBlob24(v []byte) {
+	if t.checked().ensure(4 + minLen(v, int(MaxUint24))) {
+		t.unchecked().WriteBlob24(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadBlob24(dst []byte) int {
+	if !t.expect(TBlob24) {
+		return 0
+	}
+
+	return t.checked().ReadBlob24(dst)
+}
+
+func (t *CheckedTypedLittleEndianBuffer) WriteBlob32(v []byte) {
+	if t.checked().ensure(5 + minLen(v, int(MaxUint32))) {
+		t.unchecked().WriteBlob32(v)
+	}
+}
+
+func (t *CheckedTypedLittleEndianBuffer) ReadBlob32(dst []byte) int {
+	if !t.expect(TBlob32) {
+		return 0
+	}
+
+	return t.checked().ReadBlob32(dst)
+}
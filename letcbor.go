@@ -0,0 +1,258 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// TypedToCBOR converts all values of a buffer, written by a TypedLittleEndianBuffer, into a CBOR sequence
+// (RFC 8742). Integers of any size become CBOR integers, floats keep their precision, blobs become byte strings
+// and strings become text strings. A complex number becomes an array of its real and imaginary part and a
+// record becomes an indefinite length array of its fields, because CBOR has no equivalent. Strings which are not
+// valid UTF-8 and a malformed or truncated buffer result in an error.
+func TypedToCBOR(buf []byte) (res []byte, err error) {
+	t := &TypedLittleEndianBuffer{Bytes: buf}
+	out := &bytes.Buffer{}
+	enc := NewCBOREncoder(out)
+
+	defer func() {
+		if r := recover(); r != nil {
+			res = nil
+			err = fmt.Errorf("malformed typed buffer at offset %d: %v", t.Pos, r)
+		}
+	}()
+
+	for t.HasNext() {
+		nextCBOR(t, enc, 0)
+	}
+
+	if enc.Error() != nil {
+		return nil, enc.Error()
+	}
+
+	return out.Bytes(), nil
+}
+
+// nextCBOR converts the next value including all nested values of a container.
+//
+//nolint:gocyclo
+func nextCBOR(t *TypedLittleEndianBuffer, enc *CBOREncoder, depth int) {
+	offset := t.Pos
+	typ, v := t.Next()
+
+	if typ.IsContainer() && depth >= maxTypedDepth {
+		panic(fmt.Sprintf("containers nested deeper than %d", maxTypedDepth))
+	}
+
+	switch val := v.(type) {
+	case uint8:
+		enc.WriteUint(uint64(val))
+	case uint16:
+		enc.WriteUint(uint64(val))
+	case uint32:
+		enc.WriteUint(uint64(val))
+	case uint64:
+		enc.WriteUint(val)
+	case int8:
+		enc.WriteInt(int64(val))
+	case int16:
+		enc.WriteInt(int64(val))
+	case int32:
+		enc.WriteInt(int64(val))
+	case int64:
+		enc.WriteInt(val)
+	case float32:
+		enc.WriteFloat32(val)
+	case float64:
+		enc.WriteFloat64(val)
+	case complex64:
+		enc.WriteArray(2)
+		enc.WriteFloat32(real(val))
+		enc.WriteFloat32(imag(val))
+	case complex128:
+		enc.WriteArray(2)
+		enc.WriteFloat64(real(val))
+		enc.WriteFloat64(imag(val))
+	case []byte:
+		enc.WriteBytes(val)
+	case string:
+		if !utf8.ValidString(val) {
+			panic(fmt.Sprintf("%s at offset %d is not valid UTF-8", typ, offset))
+		}
+
+		enc.WriteString(val)
+	case bool:
+		enc.WriteBool(val)
+	case nil:
+		enc.WriteNull()
+	case int:
+		switch typ {
+		case TArray:
+			enc.WriteArray(val)
+
+			for i := 0; i < val; i++ {
+				nextCBOR(t, enc, depth+1)
+			}
+		case TMap:
+			enc.WriteMap(val)
+
+			for i := 0; i < 2*val; i++ {
+				nextCBOR(t, enc, depth+1)
+			}
+		case TRecord:
+			end := t.Pos + val
+
+			enc.BeginArray()
+
+			for t.Pos < end {
+				nextCBOR(t, enc, depth+1)
+			}
+
+			if t.Pos != end {
+				panic("record fields exceed the record length")
+			}
+
+			enc.WriteBreak()
+		}
+	}
+}
+
+// TypedFromCBOR converts a CBOR sequence into a typed buffer. Unsigned integers become TUvarint and negative
+// integers TVarint, half and single-precision floats become TFloat32 and doubles TFloat64. Byte and text
+// strings, including indefinite ones, become blobs and strings with the smallest length prefix. Arrays and maps
+// keep their structure, null and undefined become TNil and tags are dropped, so that only the tagged item is
+// converted. Other simple values and negative integers below math.MinInt64 result in an error.
+func TypedFromCBOR(data []byte) ([]byte, error) {
+	dec := NewCBORDecoder(bytes.NewReader(data))
+	t := &TypedLittleEndianBuffer{Mode: WriteGrow}
+
+	for {
+		typ, v, err := dec.Next()
+		if err == io.EOF {
+			return t.Bytes[:t.Pos], nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		typedFromCBOR(dec, t, typ, v)
+
+		if dec.Error() != nil {
+			return nil, dec.Error()
+		}
+	}
+}
+
+// typedFromCBOR writes the item, whose head has just been read, including all nested items.
+func typedFromCBOR(dec *CBORDecoder, t *TypedLittleEndianBuffer, typ CBORType, v Value) {
+	offset := dec.Position()
+
+	switch typ {
+	case CBORUint:
+		t.WriteValue(TUvarint, v)
+	case CBORNegInt:
+		n := v.(uint64)
+		if n > uint64(MaxInt64) {
+			dec.decoder.noteErr("TypedFromCBOR", nil, offset, IntegerOverflow{Val: n, Max: MaxInt64})
+			return
+		}
+
+		t.WriteValue(TVarint, -1-int64(n))
+	case CBORBytes, CBORText:
+		if v == CBORIndefinite {
+			v = joinCBORChunks(dec, typ)
+		}
+
+		typedBlobFromCBOR(dec, t, offset, v)
+	case CBORArray, CBORMap:
+		n := v.(int)
+		if n != CBORIndefinite && typ == CBORMap {
+			n *= 2
+		}
+
+		// the typed header requires the amount of elements in advance
+		children := &TypedLittleEndianBuffer{Mode: WriteGrow}
+		count := 0
+
+		forEachCBORChild(dec, n, func(typ CBORType, v Value) {
+			typedFromCBOR(dec, children, typ, v)
+			count++
+		})
+
+		if typ == CBORMap {
+			t.WriteValue(TMap, count/2)
+		} else {
+			t.WriteValue(TArray, count)
+		}
+
+		(*LittleEndianBuffer)(t).WriteSlice(children.Bytes[:children.Pos])
+	case CBORTag:
+		typ, v := dec.next("ReadCBOR", true)
+		typedFromCBOR(dec, t, typ, v)
+	case CBORBool:
+		t.WriteValue(TBool, v)
+	case CBORNull, CBORUndefined:
+		t.WriteValue(TNil, nil)
+	case CBORFloat16, CBORFloat32:
+		t.WriteValue(TFloat32, v)
+	case CBORFloat64:
+		t.WriteValue(TFloat64, v)
+	default:
+		dec.decoder.noteErr("TypedFromCBOR", nil, offset, fmt.Errorf("unsupported %s", typ))
+	}
+}
+
+// typedBlobFromCBOR writes a blob or string with the smallest length prefix.
+func typedBlobFromCBOR(dec *CBORDecoder, t *TypedLittleEndianBuffer, offset int64, v Value) {
+	var n int
+
+	switch val := v.(type) {
+	case []byte:
+		n = len(val)
+	case string:
+		n = len(val)
+	default:
+		return
+	}
+
+	var typ Type
+
+	switch {
+	case n <= int(MaxUint8):
+		typ = TBlob8
+	case n <= int(MaxUint16):
+		typ = TBlob16
+	case n <= int(MaxUint24):
+		typ = TBlob24
+	case uint64(n) <= uint64(MaxUint32):
+		typ = TBlob32
+	default:
+		dec.decoder.noteErr("TypedFromCBOR", nil, offset, IntegerOverflow{Val: n, Max: MaxUint32})
+		return
+	}
+
+	if _, ok := v.(string); ok {
+		typ += TString8 - TBlob8
+	}
+
+	t.WriteValue(typ, v)
+}
@@ -0,0 +1,417 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// jsonValue is the JSON representation of a single typed value.
+type jsonValue struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// rawJSONValue is used to decode a jsonValue, before the type is known.
+type rawJSONValue struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// TypedToJSON converts all values of a buffer, written by a TypedLittleEndianBuffer, into a JSON array of
+// objects like {"type":"int24","value":-5}. Integers are encoded as numbers, floats are encoded as numbers or
+// as the strings "NaN", "+Inf" and "-Inf", complex numbers as an array of two floats, blobs as base64 strings
+// and strings as is. Strings which are not valid UTF-8 cannot be represented losslessly. The value of an array
+// or a record is the array of its elements or fields and the value of a map is an array of key and value
+// pairs. A malformed or truncated buffer results in an error.
+func TypedToJSON(buf []byte) (res []byte, err error) {
+	t := &TypedLittleEndianBuffer{Bytes: buf}
+
+	defer func() {
+		if r := recover(); r != nil {
+			res = nil
+			err = fmt.Errorf("malformed typed buffer at offset %d: %v", t.Pos, r)
+		}
+	}()
+
+	values := make([]jsonValue, 0)
+	for t.HasNext() {
+		values = append(values, nextJSON(t, 0))
+	}
+
+	return json.Marshal(values)
+}
+
+// nextJSON reads the next value including all nested values of a container.
+func nextJSON(t *TypedLittleEndianBuffer, depth int) jsonValue {
+	typ, v := t.Next()
+
+	if typ.IsContainer() && depth >= maxTypedDepth {
+		panic(fmt.Sprintf("containers nested deeper than %d", maxTypedDepth))
+	}
+
+	switch val := v.(type) {
+	case float32:
+		v = jsonFloat(float64(val), 32)
+	case float64:
+		v = jsonFloat(val, 64)
+	case complex64:
+		v = []interface{}{jsonFloat(float64(real(val)), 32), jsonFloat(float64(imag(val)), 32)}
+	case complex128:
+		v = []interface{}{jsonFloat(real(val), 64), jsonFloat(imag(val), 64)}
+	}
+
+	switch typ {
+	case TArray:
+		elems := make([]jsonValue, 0)
+		for i := 0; i < v.(int); i++ {
+			elems = append(elems, nextJSON(t, depth+1))
+		}
+
+		v = elems
+	case TMap:
+		entries := make([][2]jsonValue, 0)
+		for i := 0; i < v.(int); i++ {
+			key := nextJSON(t, depth+1)
+			entries = append(entries, [2]jsonValue{key, nextJSON(t, depth+1)})
+		}
+
+		v = entries
+	case TRecord:
+		end := t.Pos + v.(int)
+		fields := make([]jsonValue, 0)
+
+		for t.Pos < end {
+			fields = append(fields, nextJSON(t, depth+1))
+		}
+
+		if t.Pos != end {
+			panic("record fields exceed the record length")
+		}
+
+		v = fields
+	}
+
+	return jsonValue{Type: typ.String(), Value: v}
+}
+
+// A jsonNode is a parsed jsonValue. The value of a container is its header as described by Value.
+type jsonNode struct {
+	typ      Type
+	val      Value
+	children []jsonNode
+	size     int // size is the amount of bytes including the type prefix and all children
+}
+
+// TypedFromJSON converts the JSON representation created by TypedToJSON back into a typed buffer.
+func TypedFromJSON(data []byte) ([]byte, error) {
+	var raws []rawJSONValue
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return nil, err
+	}
+
+	nodes, size, err := parseJSONNodes(raws)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &TypedLittleEndianBuffer{Bytes: make([]byte, size)}
+	writeJSONNodes(t, nodes)
+
+	return t.Bytes, nil
+}
+
+func parseJSONNodes(raws []rawJSONValue) ([]jsonNode, int, error) {
+	nodes := make([]jsonNode, len(raws))
+	size := 0
+
+	for i, raw := range raws {
+		node, err := parseJSONNode(raw)
+		if err != nil {
+			return nil, 0, fmt.Errorf("value %d: %w", i, err)
+		}
+
+		nodes[i] = node
+		size += node.size
+	}
+
+	return nodes, size, nil
+}
+
+func parseJSONNode(raw rawJSONValue) (jsonNode, error) {
+	typ, ok := typeByName(raw.Type)
+	if !ok {
+		return jsonNode{}, fmt.Errorf("unknown type '%s'", raw.Type)
+	}
+
+	node := jsonNode{typ: typ}
+
+	switch typ {
+	case TArray, TMap, TRecord:
+		var elems []rawJSONValue
+
+		if typ == TMap {
+			var entries [][2]rawJSONValue
+			if err := json.Unmarshal(raw.Value, &entries); err != nil {
+				return jsonNode{}, err
+			}
+
+			for _, entry := range entries {
+				elems = append(elems, entry[0], entry[1])
+			}
+		} else if err := json.Unmarshal(raw.Value, &elems); err != nil {
+			return jsonNode{}, err
+		}
+
+		children, size, err := parseJSONNodes(elems)
+		if err != nil {
+			return jsonNode{}, err
+		}
+
+		node.children = children
+
+		switch typ {
+		case TArray:
+			node.val = len(children)
+		case TMap:
+			node.val = len(children) / 2
+		default:
+			if uint64(size) > uint64(MaxUint32) {
+				return jsonNode{}, IntegerOverflow{Val: size, Max: MaxUint32}
+			}
+
+			node.val = size
+		}
+
+		node.size = 1 + valueSize(typ, node.val) + size
+	default:
+		v, err := parseJSONValue(typ, raw.Value)
+		if err != nil {
+			return jsonNode{}, err
+		}
+
+		node.val = v
+		node.size = 1 + valueSize(typ, v)
+	}
+
+	return node, nil
+}
+
+func writeJSONNodes(t *TypedLittleEndianBuffer, nodes []jsonNode) {
+	for _, node := range nodes {
+		t.WriteValue(node.typ, node.val)
+		writeJSONNodes(t, node.children)
+	}
+}
+
+// typeByName returns the Type, whose String representation is name.
+func typeByName(name string) (Type, bool) {
+	for t := minTValid; t <= maxTValid; t++ {
+		if t.String() == name {
+			return t, true
+		}
+	}
+
+	return 0, false
+}
+
+// valueSize returns the amount of bytes of the value without the type prefix. For containers only the size of
+// the header is returned.
+func valueSize(typ Type, v Value) int {
+	var tmp [binary.MaxVarintLen64]byte
+
+	switch val := v.(type) {
+	case []byte:
+		return blobPrefixSize(typ) + len(val)
+	case string:
+		return blobPrefixSize(typ) + len(val)
+	case int64:
+		if typ == TVarint {
+			return binary.PutVarint(tmp[:], val)
+		}
+	case uint64:
+		if typ == TUvarint {
+			return binary.PutUvarint(tmp[:], val)
+		}
+	case int:
+		switch typ {
+		case TArray, TMap:
+			return binary.PutUvarint(tmp[:], uint64(val))
+		case TRecord:
+			return 4
+		}
+	}
+
+	return drainJumpTable[typ]
+}
+
+// blobPrefixSize returns the length prefix size of a blob or string type.
+func blobPrefixSize(typ Type) int {
+	switch typ {
+	case TBlob8, TString8:
+		return 1
+	case TBlob16, TString16:
+		return 2
+	case TBlob24, TString24:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// jsonFloat returns a string for values, which cannot be represented as a JSON number.
+func jsonFloat(v float64, bitSize int) interface{} {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'g', -1, bitSize)
+	}
+
+	if bitSize == 32 {
+		return float32(v)
+	}
+
+	return v
+}
+
+//nolint:gocyclo
+func parseJSONValue(typ Type, raw json.RawMessage) (Value, error) {
+	switch typ {
+	case TUint8, TUint16, TUint24, TUint32, TUint40, TUint48, TUint56, TUint64:
+		u, err := strconv.ParseUint(string(raw), 10, drainJumpTable[typ]*8)
+		if err != nil {
+			return nil, err
+		}
+
+		switch typ {
+		case TUint8:
+			return uint8(u), nil
+		case TUint16:
+			return uint16(u), nil
+		case TUint24, TUint32:
+			return uint32(u), nil
+		default:
+			return u, nil
+		}
+	case TInt8, TInt16, TInt24, TInt32, TInt40, TInt48, TInt56, TInt64:
+		s, err := strconv.ParseInt(string(raw), 10, drainJumpTable[typ]*8)
+		if err != nil {
+			return nil, err
+		}
+
+		switch typ {
+		case TInt8:
+			return int8(s), nil
+		case TInt16:
+			return int16(s), nil
+		case TInt24, TInt32:
+			return int32(s), nil
+		default:
+			return s, nil
+		}
+	case TBool:
+		var b bool
+		err := json.Unmarshal(raw, &b)
+
+		return b, err
+	case TNil:
+		if string(raw) != "null" {
+			return nil, fmt.Errorf("expected null but got %s", raw)
+		}
+
+		return nil, nil
+	case TVarint:
+		return strconv.ParseInt(string(raw), 10, 64)
+	case TUvarint:
+		return strconv.ParseUint(string(raw), 10, 64)
+	case TFloat32:
+		f, err := parseJSONFloat(raw, 32)
+		return float32(f), err
+	case TFloat64:
+		return parseJSONFloat(raw, 64)
+	case TComplex64, TComplex128:
+		var parts []json.RawMessage
+		if err := json.Unmarshal(raw, &parts); err != nil {
+			return nil, err
+		}
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected real and imaginary part but got %d values", len(parts))
+		}
+
+		bitSize := drainJumpTable[typ] * 4
+
+		re, err := parseJSONFloat(parts[0], bitSize)
+		if err != nil {
+			return nil, err
+		}
+
+		im, err := parseJSONFloat(parts[1], bitSize)
+		if err != nil {
+			return nil, err
+		}
+
+		if typ == TComplex64 {
+			return complex64(complex(re, im)), nil
+		}
+
+		return complex(re, im), nil
+	case TBlob8, TBlob16, TBlob24, TBlob32:
+		var b []byte
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return nil, err
+		}
+
+		if b == nil {
+			b = []byte{}
+		}
+
+		return b, checkBlobLen(typ, len(b))
+	case TString8, TString16, TString24, TString32:
+		var str string
+		if err := json.Unmarshal(raw, &str); err != nil {
+			return nil, err
+		}
+
+		return str, checkBlobLen(typ, len(str))
+	default:
+		return nil, fmt.Errorf("unsupported type %s", typ)
+	}
+}
+
+// parseJSONFloat parses a number or one of the strings "NaN", "+Inf" and "-Inf".
+func parseJSONFloat(raw json.RawMessage, bitSize int) (float64, error) {
+	str := string(raw)
+	if len(raw) > 0 && raw[0] == '"' {
+		if err := json.Unmarshal(raw, &str); err != nil {
+			return 0, err
+		}
+	}
+
+	return strconv.ParseFloat(str, bitSize)
+}
+
+// checkBlobLen returns an error, if n does not fit into the length prefix of the type.
+func checkBlobLen(typ Type, n int) error {
+	if max := uint64(1)<<(uint(blobPrefixSize(typ))*8) - 1; uint64(n) > max {
+		return IntegerOverflow{Val: n, Max: max}
+	}
+
+	return nil
+}
@@ -0,0 +1,286 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"fmt"
+)
+
+// A Value is the decoded representation of a single typed value. The dynamic type depends on the Type:
+//
+//	TUint8: uint8, TUint16: uint16, TUint24, TUint32: uint32, TUint40..TUint64: uint64
+//	TInt8: int8, TInt16: int16, TInt24, TInt32: int32, TInt40..TInt64: int64
+//	TFloat32: float32, TFloat64: float64, TComplex64: complex64, TComplex128: complex128
+//	TBlob8..TBlob32: []byte, TString8..TString32: string
+//	TBool: bool, TNil: nil, TVarint: int64, TUvarint: uint64
+//	TArray, TMap: int with the amount of elements or entries, which follow as separate values
+//	TRecord: int with the byte length of the fields, which follow as separate values
+type Value interface{}
+
+// HasNext returns true, if the buffer contains more values.
+func (t *TypedLittleEndianBuffer) HasNext() bool {
+	return t.Pos < len(t.Bytes)
+}
+
+// Next reads the type prefix and the following value, whatever it is. For containers only the header is read, so
+// the elements, entries or fields are returned by the following calls. Blobs are returned as sub slices of the
+// buffer, so they are only valid until the buffer is modified. Strings are copied. Like all other methods, Next
+// panics if the buffer is malformed or truncated.
+func (t *TypedLittleEndianBuffer) Next() (Type, Value) {
+	f := (*LittleEndianBuffer)(t)
+	typ := f.ReadType()
+
+	switch typ {
+	case TUint8:
+		return typ, f.ReadUint8()
+	case TUint16:
+		return typ, f.ReadUint16()
+	case TUint24:
+		return typ, f.ReadUint24()
+	case TUint32:
+		return typ, f.ReadUint32()
+	case TUint40:
+		return typ, f.ReadUint40()
+	case TUint48:
+		return typ, f.ReadUint48()
+	case TUint56:
+		return typ, f.ReadUint56()
+	case TUint64:
+		return typ, f.ReadUint64()
+	case TInt8:
+		return typ, int8(f.ReadUint8())
+	case TInt16:
+		return typ, int16(f.ReadUint16())
+	case TInt24:
+		return typ, int32(f.ReadUint24()<<8) >> 8
+	case TInt32:
+		return typ, int32(f.ReadUint32())
+	case TInt40:
+		return typ, int64(f.ReadUint40()<<24) >> 24
+	case TInt48:
+		return typ, int64(f.ReadUint48()<<16) >> 16
+	case TInt56:
+		return typ, int64(f.ReadUint56()<<8) >> 8
+	case TInt64:
+		return typ, int64(f.ReadUint64())
+	case TBlob8, TString8:
+		return typ, t.nextBlob(typ, int(f.ReadUint8()))
+	case TBlob16, TString16:
+		return typ, t.nextBlob(typ, int(f.ReadUint16()))
+	case TBlob24, TString24:
+		return typ, t.nextBlob(typ, int(f.ReadUint24()))
+	case TBlob32, TString32:
+		return typ, t.nextBlob(typ, int(f.ReadUint32()))
+	case TFloat32:
+		return typ, f.ReadFloat32()
+	case TFloat64:
+		return typ, f.ReadFloat64()
+	case TComplex64:
+		return typ, f.ReadComplex64()
+	case TComplex128:
+		return typ, f.ReadComplex128()
+	case TBool:
+		return typ, f.ReadUint8() != 0
+	case TNil:
+		return typ, nil
+	case TVarint:
+		return typ, f.ReadVarint()
+	case TUvarint:
+		return typ, f.ReadUvarint()
+	case TArray, TMap:
+		return typ, int(f.ReadUvarint())
+	case TRecord:
+		return typ, int(f.ReadUint32())
+	default:
+		panic("unsupported type " + typ.String())
+	}
+}
+
+func (t *TypedLittleEndianBuffer) nextBlob(typ Type, n int) Value {
+	if t.Pos+n > len(t.Bytes) {
+		panic(fmt.Sprintf("%s of %d bytes exceeds the buffer", typ, n))
+	}
+
+	b := t.Bytes[t.Pos : t.Pos+n : t.Pos+n]
+	t.Pos += n
+
+	if typ >= TString8 && typ <= TString32 {
+		return string(b)
+	}
+
+	return b
+}
+
+// WriteValue writes the type prefix and the value, which must have the dynamic type as returned by Next. For
+// containers only the header is written. Blobs
+// and strings which do not fit into the length prefix are truncated, like WriteBlob8 etc. do.
+func (t *TypedLittleEndianBuffer) WriteValue(typ Type, v Value) {
+	f := (*LittleEndianBuffer)(t)
+	f.WriteType(typ)
+
+	if typ == TNil && v == nil {
+		return
+	}
+
+	switch val := v.(type) {
+	case bool:
+		if typ == TBool {
+			if val {
+				f.WriteUint8(1)
+			} else {
+				f.WriteUint8(0)
+			}
+
+			return
+		}
+	case int:
+		switch typ {
+		case TArray, TMap:
+			f.WriteUvarint(uint64(val))
+			return
+		case TRecord:
+			f.WriteUint32(uint32(val))
+			return
+		}
+	case uint8:
+		if typ == TUint8 {
+			f.WriteUint8(val)
+			return
+		}
+	case uint16:
+		if typ == TUint16 {
+			f.WriteUint16(val)
+			return
+		}
+	case uint32:
+		switch typ {
+		case TUint24:
+			f.WriteUint24(val)
+			return
+		case TUint32:
+			f.WriteUint32(val)
+			return
+		}
+	case uint64:
+		switch typ {
+		case TUvarint:
+			f.WriteUvarint(val)
+			return
+		case TUint40:
+			f.WriteUint40(val)
+			return
+		case TUint48:
+			f.WriteUint48(val)
+			return
+		case TUint56:
+			f.WriteUint56(val)
+			return
+		case TUint64:
+			f.WriteUint64(val)
+			return
+		}
+	case int8:
+		if typ == TInt8 {
+			f.WriteUint8(uint8(val))
+			return
+		}
+	case int16:
+		if typ == TInt16 {
+			f.WriteUint16(uint16(val))
+			return
+		}
+	case int32:
+		switch typ {
+		case TInt24:
+			f.WriteUint24(uint32(val))
+			return
+		case TInt32:
+			f.WriteUint32(uint32(val))
+			return
+		}
+	case int64:
+		switch typ {
+		case TVarint:
+			f.WriteVarint(val)
+			return
+		case TInt40:
+			f.WriteUint40(uint64(val))
+			return
+		case TInt48:
+			f.WriteUint48(uint64(val))
+			return
+		case TInt56:
+			f.WriteUint56(uint64(val))
+			return
+		case TInt64:
+			f.WriteUint64(uint64(val))
+			return
+		}
+	case float32:
+		if typ == TFloat32 {
+			f.WriteFloat32(val)
+			return
+		}
+	case float64:
+		if typ == TFloat64 {
+			f.WriteFloat64(val)
+			return
+		}
+	case complex64:
+		if typ == TComplex64 {
+			f.WriteComplex64(val)
+			return
+		}
+	case complex128:
+		if typ == TComplex128 {
+			f.WriteComplex128(val)
+			return
+		}
+	case []byte:
+		switch typ {
+		case TBlob8:
+			f.WriteBlob8(val)
+			return
+		case TBlob16:
+			f.WriteBlob16(val)
+			return
+		case TBlob24:
+			f.WriteBlob24(val)
+			return
+		case TBlob32:
+			f.WriteBlob32(val)
+			return
+		}
+	case string:
+		switch typ {
+		case TString8:
+			f.WriteString8(val)
+			return
+		case TString16:
+			f.WriteString16(val)
+			return
+		case TString24:
+			f.WriteString24(val)
+			return
+		case TString32:
+			f.WriteString32(val)
+			return
+		}
+	}
+
+	panic(fmt.Sprintf("cannot write %T as %s", v, typ))
+}
@@ -0,0 +1,263 @@
+package ioutil
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type typedSample struct {
+	typ Type
+	val Value
+}
+
+func typedSamples() []typedSample {
+	return []typedSample{
+		{TUint8, uint8(255)},
+		{TUint16, uint16(65535)},
+		{TUint24, MaxUint24},
+		{TUint32, uint32(7)},
+		{TUint40, MaxUint40},
+		{TUint48, MaxUint48},
+		{TUint56, MaxUint56},
+		{TUint64, MaxUint64},
+		{TInt8, MinInt8},
+		{TInt16, MinInt16},
+		{TInt24, MinInt24},
+		{TInt32, int32(-1)},
+		{TInt40, MinInt40},
+		{TInt48, int64(-2)},
+		{TInt56, MaxInt56},
+		{TInt64, MinInt64},
+		{TFloat32, float32(0.1)},
+		{TFloat64, math.Inf(-1)},
+		{TComplex64, complex64(complex(1.5, float32(math.NaN())))},
+		{TComplex128, complex(-0.25, 3)},
+		{TBlob8, []byte{0, 1, 2}},
+		{TBlob16, []byte{}},
+		{TBlob24, []byte("blob")},
+		{TBlob32, []byte{0xFF}},
+		{TString8, "hello"},
+		{TString16, ""},
+		{TString24, "world"},
+		{TString32, "äöü"},
+		{TBool, true},
+		{TNil, nil},
+		{TVarint, int64(-300)},
+		{TUvarint, MaxUint64},
+	}
+}
+
+func writeTypedSamples() []byte {
+	samples := typedSamples()
+	size := 0
+
+	for _, s := range samples {
+		size += 1 + valueSize(s.typ, s.val)
+	}
+
+	t := &TypedLittleEndianBuffer{Bytes: make([]byte, size)}
+	for _, s := range samples {
+		t.WriteValue(s.typ, s.val)
+	}
+
+	return t.Bytes
+}
+
+func TestTypedLittleEndianBuffer_Next(t *testing.T) {
+	buf := &TypedLittleEndianBuffer{Bytes: writeTypedSamples()}
+
+	for _, s := range typedSamples() {
+		if !buf.HasNext() {
+			t.Fatalf("expected %s", s.typ)
+		}
+
+		typ, v := buf.Next()
+		if typ != s.typ || !sameValue(v, s.val) {
+			t.Fatalf("expected %s %v but got %s %v", s.typ, s.val, typ, v)
+		}
+	}
+
+	if buf.HasNext() {
+		t.Fatal("expected the end")
+	}
+
+	// the typed accessors must agree with Next
+	buf.Pos = 0
+	if buf.Next(); buf.ReadUint16() != 65535 {
+		t.Fatal("unexpected uint16")
+	}
+
+	buf.Pos = 0
+	for i := 0; i < 10; i++ {
+		buf.Next()
+	}
+
+	if v := buf.ReadInt24(); v != MinInt24 {
+		t.Fatalf("expected %d but got %d", MinInt24, v)
+	}
+
+	if v := buf.ReadInt(); v != -1 {
+		t.Fatalf("expected -1 but got %d", v)
+	}
+
+	if v := buf.ReadInt40(); v != MinInt40 {
+		t.Fatalf("expected %d but got %d", MinInt40, v)
+	}
+}
+
+func TestTypedJSON(t *testing.T) {
+	data := writeTypedSamples()
+
+	js, err := TypedToJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := TypedFromJSON(js)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, res) {
+		t.Fatalf("expected\n%x\nbut got\n%x\n%s", data, res, js)
+	}
+
+	if _, err := TypedToJSON(data[:len(data)-1]); err == nil {
+		t.Fatal("expected an error for a truncated buffer")
+	}
+
+	deep := &TypedLittleEndianBuffer{Mode: WriteGrow}
+	for i := 0; i <= maxTypedDepth; i++ {
+		deep.WriteValue(TArray, 1)
+	}
+
+	deep.WriteValue(TUint8, uint8(1))
+
+	if _, err := TypedToJSON(deep.Bytes); err == nil {
+		t.Fatal("expected an error for excessive nesting")
+	}
+
+	invalid := []string{
+		`[{"type":"uint8","value":256}]`,
+		`[{"type":"int24","value":8388608}]`,
+		`[{"type":"unknown","value":1}]`,
+		`[{"type":"complex64","value":[1]}]`,
+		`[{"type":"blob8","value":"not base64"}]`,
+	}
+
+	for _, js := range invalid {
+		if _, err := TypedFromJSON([]byte(js)); err == nil {
+			t.Fatalf("expected an error for %s", js)
+		}
+	}
+}
+
+// sameValue compares like reflect.DeepEqual but treats NaN as equal.
+func sameValue(a, b Value) bool {
+	if ca, ok := a.(complex64); ok {
+		cb, ok := b.(complex64)
+		return ok && sameFloat(float64(real(ca)), float64(real(cb))) && sameFloat(float64(imag(ca)), float64(imag(cb)))
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func sameFloat(a, b float64) bool {
+	return a == b || math.IsNaN(a) && math.IsNaN(b)
+}
+
+// writeNestedRecord writes a record, which contains every kind of container.
+func writeNestedRecord(withUnknown bool) []byte {
+	buf := &TypedLittleEndianBuffer{Bytes: make([]byte, 128)}
+	rec := buf.BeginRecord()
+	buf.WriteString("name")
+
+	if withUnknown {
+		buf.WriteArray(3)
+		buf.WriteVarint(-1)
+		buf.WriteNil()
+		buf.WriteMap(2)
+		buf.WriteUint40(1)
+		buf.WriteBool(true)
+		buf.WriteString("k")
+		inner := buf.BeginRecord()
+		buf.WriteComplex128(complex(1, 2))
+		buf.WriteInt56(-7)
+		buf.EndRecord(inner)
+	}
+
+	buf.WriteUvarint(42)
+	buf.EndRecord(rec)
+	buf.WriteBool(false)
+
+	return buf.Bytes[:buf.Pos]
+}
+
+func TestTypedLittleEndianBuffer_Containers(t *testing.T) {
+	for _, withUnknown := range []bool{false, true} {
+		buf := &TypedLittleEndianBuffer{Bytes: writeNestedRecord(withUnknown)}
+
+		// an old reader only knows the first field and skips anything else
+		end := buf.ReadRecord()
+		if s := buf.ReadString(nil); s != "name" {
+			t.Fatalf("expected name but got %s", s)
+		}
+
+		for buf.Pos < end {
+			buf.Skip()
+		}
+
+		if buf.ReadBool() {
+			t.Fatal("expected false")
+		}
+
+		if buf.HasNext() {
+			t.Fatal("expected the end")
+		}
+
+		// a record must be skippable as a whole
+		buf.Pos = 0
+		if n := buf.Skip(); n != end {
+			t.Fatalf("expected to skip %d bytes but skipped %d", end, n)
+		}
+	}
+
+	data := writeNestedRecord(true)
+	buf := &TypedLittleEndianBuffer{Bytes: data}
+	buf.ReadRecord()
+	buf.ReadString(nil)
+
+	if n := buf.ReadArray(); n != 3 {
+		t.Fatalf("expected 3 elements but got %d", n)
+	}
+
+	if v := buf.ReadVarint(); v != -1 {
+		t.Fatalf("expected -1 but got %d", v)
+	}
+
+	buf.ReadNil()
+
+	if n := buf.ReadMap(); n != 2 {
+		t.Fatalf("expected 2 entries but got %d", n)
+	}
+
+	if typ := Type(buf.Bytes[buf.Pos]); typ != TUint40 {
+		t.Fatalf("expected %s but got %s", TUint40, typ)
+	}
+
+	js, err := TypedToJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := TypedFromJSON(js)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(data, res) {
+		t.Fatalf("expected\n%x\nbut got\n%x\n%s", data, res, js)
+	}
+}
@@ -0,0 +1,28 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+// Limits restricts the amount of memory, which a Decoder allocates for length prefixed data. Without limits,
+// a single corrupted or hostile length prefix may cause an allocation of gigabytes.
+type Limits struct {
+	// MaxBlobSize is the maximum length of a single blob, string or byte slice. Zero means unlimited.
+	MaxBlobSize int
+
+	// Budget is the maximum total amount of bytes, which are allocated for blobs, strings and byte slices.
+	// Zero means unlimited.
+	Budget int64
+}
@@ -0,0 +1,321 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"hash/crc32"
+	"io"
+)
+
+// DefaultLogBlockSize is the block size of the LevelDB log format.
+const DefaultLogBlockSize = 32 * 1024
+
+// logHeaderSize is the size of a fragment header: checksum (4), length (2) and type (1).
+const logHeaderSize = 7
+
+// fragment types of the LevelDB log format
+const (
+	logZero   = 0 // logZero is reserved for preallocated or padded regions
+	logFull   = 1
+	logFirst  = 2
+	logMiddle = 3
+	logLast   = 4
+)
+
+// logMaskDelta is used to mask the checksums, because computing the CRC of a string which contains embedded
+// CRCs is problematic.
+const logMaskDelta = 0xa282ead8
+
+func logChecksum(typ uint8, data []byte) uint32 {
+	crc := crc32.Update(crc32.Checksum([]byte{typ}, castagnoli), castagnoli, data)
+	return (crc>>15 | crc<<17) + logMaskDelta
+}
+
+// A LogWriter appends records to a write-ahead log in the LevelDB log format. The log is split into blocks of
+// a fixed size and each record is split into fragments, so that no fragment crosses a block boundary. Each
+// fragment has a header of a masked CRC32C, its uint16 length and its type (full, first, middle or last). A block
+// trailer, which is too small for a header, is filled with zeros. All integers are little endian. As soon as
+// any error occurred, any call is a no-op and will result in the same error state.
+type LogWriter struct {
+	out         DataOutput
+	blockSize   int
+	blockOffset int
+	offset      int64
+}
+
+// NewLogWriter creates a new LogWriter, which writes into w. A blockSize of 0 means DefaultLogBlockSize. The
+// writer must use the same block size as the reader.
+func NewLogWriter(w io.Writer, blockSize int) *LogWriter {
+	if blockSize == 0 {
+		blockSize = DefaultLogBlockSize
+	}
+
+	if blockSize <= logHeaderSize || blockSize > logHeaderSize+int(MaxUint16) {
+		panic("invalid block size")
+	}
+
+	return &LogWriter{out: NewDataOutput(LittleEndian, w), blockSize: blockSize}
+}
+
+// SetOffset declares the size of an existing log, which is continued by this writer, e.g. the
+// LogReader.ValidOffset after truncating a damaged tail.
+func (w *LogWriter) SetOffset(offset int64) {
+	w.offset = offset - w.out.Position()
+	w.blockOffset = int(offset % int64(w.blockSize))
+}
+
+// WriteRecord appends a single record, which may be empty, and returns the first occurred error.
+func (w *LogWriter) WriteRecord(p []byte) error {
+	begin := true
+
+	for w.out.Error() == nil {
+		if leftover := w.blockSize - w.blockOffset; leftover < logHeaderSize {
+			w.out.WriteBytes(make([]byte, leftover)...)
+			w.blockOffset = 0
+		}
+
+		avail := w.blockSize - w.blockOffset - logHeaderSize
+		fragment := p
+		end := true
+
+		if len(fragment) > avail {
+			fragment = fragment[:avail]
+			end = false
+		}
+
+		var typ uint8
+
+		switch {
+		case begin && end:
+			typ = logFull
+		case begin:
+			typ = logFirst
+		case end:
+			typ = logLast
+		default:
+			typ = logMiddle
+		}
+
+		w.out.WriteUint32(logChecksum(typ, fragment))
+		w.out.WriteUint16(uint16(len(fragment)))
+		w.out.WriteUint8(typ)
+		w.out.WriteBytes(fragment...)
+
+		w.blockOffset += logHeaderSize + len(fragment)
+		p = p[len(fragment):]
+		begin = false
+
+		if end {
+			break
+		}
+	}
+
+	return w.out.Error()
+}
+
+// Offset returns the size of the log, which is the position of the next record.
+func (w *LogWriter) Offset() int64 {
+	return w.offset + w.out.Position()
+}
+
+// Error returns the first occurred error.
+func (w *LogWriter) Error() error {
+	return w.out.Error()
+}
+
+// A LogReader reads the records of a write-ahead log, which has been written by a LogWriter. It survives a
+// torn write at the end of the log, e.g. after a crash, by dropping the incomplete record. A corrupted block in
+// the middle of the log is skipped and only the records, whose fragments are located in that block, are lost.
+type LogReader struct {
+	in          io.Reader
+	blockSize   int
+	block       []byte
+	blockStart  int64 // blockStart is the offset of block within the log
+	din         *SliceDecoder
+	eof         bool
+	record      []byte
+	fragmented  bool
+	validOffset int64
+	dropped     int64
+	firstErr    error
+}
+
+// NewLogReader creates a new LogReader, which reads from r. A blockSize of 0 means DefaultLogBlockSize.
+func NewLogReader(r io.Reader, blockSize int) *LogReader {
+	if blockSize == 0 {
+		blockSize = DefaultLogBlockSize
+	}
+
+	return &LogReader{in: r, blockSize: blockSize, block: make([]byte, blockSize)}
+}
+
+// Next returns the next intact record. The returned slice is only valid until the next call. It returns io.EOF
+// at the end of the log, including a torn tail, and any other error of the wrapped reader.
+func (r *LogReader) Next() ([]byte, error) {
+	if r.firstErr != nil {
+		return nil, r.firstErr
+	}
+
+	for {
+		typ, fragment, err := r.nextFragment()
+		if err != nil {
+			if r.fragmented {
+				r.drop(int64(len(r.record)))
+			}
+
+			r.firstErr = err
+
+			return nil, err
+		}
+
+		switch typ {
+		case logFull:
+			if r.fragmented {
+				r.drop(int64(len(r.record)))
+			}
+
+			r.fragmented = false
+			r.validOffset = r.offset()
+
+			return fragment, nil
+		case logFirst:
+			if r.fragmented {
+				r.drop(int64(len(r.record)))
+			}
+
+			r.record = append(r.record[:0], fragment...)
+			r.fragmented = true
+		case logMiddle, logLast:
+			if !r.fragmented {
+				r.drop(int64(len(fragment)))
+				continue
+			}
+
+			r.record = append(r.record, fragment...)
+
+			if typ == logLast {
+				r.fragmented = false
+				r.validOffset = r.offset()
+
+				return r.record, nil
+			}
+		default:
+			r.drop(int64(len(fragment)))
+		}
+	}
+}
+
+// nextFragment returns the next fragment with a valid checksum.
+func (r *LogReader) nextFragment() (uint8, []byte, error) {
+	for {
+		if r.din == nil || r.din.Remaining() < logHeaderSize {
+			if err := r.readBlock(); err != nil {
+				return 0, nil, err
+			}
+
+			continue
+		}
+
+		start := r.din.Position()
+		checksum := r.din.ReadUint32()
+		length := int(r.din.ReadUint16())
+		typ := r.din.ReadUint8()
+
+		if length > r.din.Remaining() {
+			// a torn tail or a corrupted length, in any case the rest of the block is lost
+			r.dropBlock(logHeaderSize)
+
+			continue
+		}
+
+		if typ == logZero && length == 0 {
+			// padding of a preallocated file
+			r.dropBlock(logHeaderSize)
+
+			continue
+		}
+
+		fragment := r.din.next("ReadLog", length)
+		if logChecksum(typ, fragment) != checksum {
+			r.dropBlock(int(r.din.Position() - start))
+
+			continue
+		}
+
+		return typ, fragment, nil
+	}
+}
+
+// readBlock reads the next block. A short block is only valid at the end of the log.
+func (r *LogReader) readBlock() error {
+	if r.din != nil {
+		r.blockStart += r.din.Position() + int64(r.din.Remaining())
+	}
+
+	if r.eof {
+		r.din = nil
+		return io.EOF
+	}
+
+	n, err := io.ReadFull(r.in, r.block)
+
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		r.eof = true
+	default:
+		return err
+	}
+
+	r.din = NewSliceDecoder(LittleEndian, r.block[:n])
+
+	return nil
+}
+
+// offset returns the position after the last read fragment.
+func (r *LogReader) offset() int64 {
+	return r.blockStart + r.din.Position()
+}
+
+func (r *LogReader) drop(n int64) {
+	r.dropped += n
+}
+
+// dropBlock skips the rest of the current block, including the already consumed n bytes of the damaged
+// fragment. A pending fragmented record is lost as well, because its next fragment cannot be trusted.
+func (r *LogReader) dropBlock(n int) {
+	r.drop(int64(n + r.din.Remaining()))
+
+	if r.fragmented {
+		r.drop(int64(len(r.record)))
+		r.fragmented = false
+	}
+
+	r.blockStart += r.din.Position() + int64(r.din.Remaining())
+	r.din = nil
+}
+
+// ValidOffset returns the position after the last intact record, which has been returned by Next. After
+// reaching io.EOF, a damaged log can be truncated at this position and continued with a LogWriter.
+func (r *LogReader) ValidOffset() int64 {
+	return r.validOffset
+}
+
+// Dropped returns the amount of bytes, which have been skipped due to corruption, including incomplete records.
+func (r *LogReader) Dropped() int64 {
+	return r.dropped
+}
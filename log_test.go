@@ -0,0 +1,164 @@
+package ioutil
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func logRecords() [][]byte {
+	var records [][]byte
+
+	for i, n := range []int{0, 1, 10, 57, 58, 64, 100, 300, 3, 0, 49} {
+		records = append(records, bytes.Repeat([]byte{byte(i + 1)}, n))
+	}
+
+	return records
+}
+
+// writeLog writes the records and returns the log and the end offset of each record.
+func writeLog(t *testing.T, blockSize int, records [][]byte) ([]byte, []int64) {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	w := NewLogWriter(buf, blockSize)
+
+	var ends []int64
+
+	for _, rec := range records {
+		if err := w.WriteRecord(rec); err != nil {
+			t.Fatal(err)
+		}
+
+		ends = append(ends, w.Offset())
+	}
+
+	return buf.Bytes(), ends
+}
+
+func readLog(t *testing.T, r *LogReader) [][]byte {
+	t.Helper()
+
+	var records [][]byte
+
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return records
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		records = append(records, append([]byte(nil), rec...))
+	}
+}
+
+func TestLog(t *testing.T) {
+	records := logRecords()
+	data, _ := writeLog(t, 64, records)
+
+	r := NewLogReader(bytes.NewReader(data), 64)
+	if actual := readLog(t, r); !equalRecords(actual, records) {
+		t.Fatalf("expected %d records but got %d", len(records), len(actual))
+	}
+
+	if r.Dropped() != 0 || r.ValidOffset() != int64(len(data)) {
+		t.Fatalf("unexpected dropped %d or offset %d", r.Dropped(), r.ValidOffset())
+	}
+}
+
+func TestLog_TruncateAtEveryOffset(t *testing.T) {
+	records := logRecords()
+	data, ends := writeLog(t, 64, records)
+
+	for size := 0; size <= len(data); size++ {
+		r := NewLogReader(bytes.NewReader(data[:size]), 64)
+		actual := readLog(t, r)
+
+		// exactly the records, which have been written completely, must survive
+		complete := 0
+		for complete < len(ends) && ends[complete] <= int64(size) {
+			complete++
+		}
+
+		if !equalRecords(actual, records[:complete]) {
+			t.Fatalf("truncated at %d: expected %d records but got %d", size, complete, len(actual))
+		}
+
+		// a repaired log can be continued
+		valid := r.ValidOffset()
+		repaired := &bytes.Buffer{}
+		repaired.Write(data[:valid])
+
+		w := NewLogWriter(repaired, 64)
+		w.SetOffset(valid)
+
+		if err := w.WriteRecord([]byte("appended")); err != nil {
+			t.Fatal(err)
+		}
+
+		actual = readLog(t, NewLogReader(repaired, 64))
+		expected := append(append([][]byte(nil), records[:complete]...), []byte("appended"))
+
+		if !equalRecords(actual, expected) {
+			t.Fatalf("repaired at %d: expected %d records but got %d", valid, len(expected), len(actual))
+		}
+	}
+}
+
+func TestLog_Corrupted(t *testing.T) {
+	records := logRecords()
+	data, _ := writeLog(t, 64, records)
+
+	// corrupt the second block, which contains the last fragment of record 3 and the first one of record 4
+	corrupted := append([]byte(nil), data...)
+	corrupted[64+10] ^= 1
+
+	r := NewLogReader(bytes.NewReader(corrupted), 64)
+	actual := readLog(t, r)
+
+	expected := append(append([][]byte(nil), records[:3]...), records[5:]...)
+	if !equalRecords(actual, expected) {
+		t.Fatalf("expected %d records but got %d", len(expected), len(actual))
+	}
+
+	if r.Dropped() == 0 {
+		t.Fatal("expected dropped bytes")
+	}
+
+	// a flipped bit anywhere must never produce a record, which has not been written
+	for i := range data {
+		corrupted := append([]byte(nil), data...)
+		corrupted[i] ^= 0x10
+
+		next := 0
+
+		for _, rec := range readLog(t, NewLogReader(bytes.NewReader(corrupted), 64)) {
+			for next < len(records) && !bytes.Equal(records[next], rec) {
+				next++
+			}
+
+			if next == len(records) {
+				t.Fatalf("flipped bit at %d: unexpected record %x", i, rec)
+			}
+
+			next++
+		}
+	}
+}
+
+func equalRecords(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
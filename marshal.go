@@ -0,0 +1,623 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// TagName is the struct tag key, which is inspected by Marshal and Unmarshal.
+const TagName = "io"
+
+// Marshal writes the exported fields of v in declaration order using the given default byte order. Each field
+// may be annotated with a tag like `io:"u24,le"`, `io:"blob,I16"` or `io:"varint"`. The first element
+// denotes the wire kind and is named after the according DataOutput method:
+//
+//  u8, u16, u24, u32, u40, u48, u56, u64, uvarint
+//  i8, i16, i24, i32, i40, i48, i56, i64, varint
+//  f32, f64, c64, c128, bool, blob, utf8
+//
+// The other elements are options: le or be overrides the byte order and I8, I16, I24, I32, I40, I64 or IVar
+// defines the length prefix of strings, blobs and slices (default is IVar). For arrays and slices the kind applies
+// to each element. Without a kind, the natural width of the Go type is used. A tag of "-" skips the field.
+func Marshal(o ByteOrder, w io.Writer, v interface{}) error {
+	enc := NewEncoder(w, true)
+	enc.WriteStruct(o, v)
+
+	return enc.Error()
+}
+
+// Unmarshal is the inverse of Marshal and reads the fields of the struct, to which v points.
+func Unmarshal(o ByteOrder, r io.Reader, v interface{}) error {
+	dec := NewDecoder(r, true)
+	dec.ReadStruct(o, v)
+
+	return dec.Error()
+}
+
+// WriteStruct writes v as described by Marshal. Any error is recorded and can be inspected using Error.
+func (e *Encoder) WriteStruct(o ByteOrder, v interface{}) {
+	if e.quickFail() {
+		return
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if !rv.IsValid() {
+		e.noteErr("WriteStruct", o, e.pos, fmt.Errorf("expected a struct or a non-nil pointer but got %T", v))
+		return
+	}
+
+	e.writeValue(o, FieldTag{}, rv)
+}
+
+// ReadStruct reads into v as described by Unmarshal. Any error is recorded and can be inspected using Error.
+func (r *Decoder) ReadStruct(o ByteOrder, v interface{}) {
+	if r.quickFail() {
+		return
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		r.noteErr("ReadStruct", o, r.pos, fmt.Errorf("expected a non-nil pointer but got %T", v))
+		return
+	}
+
+	r.readValue(o, FieldTag{}, rv.Elem())
+}
+
+// A FieldTag is the parsed representation of an io struct tag, as described by Marshal.
+type FieldTag struct {
+	Kind  string    // Kind is the wire kind or empty to select the default of the Go type
+	Order ByteOrder // Order overrides the inherited byte order, if not nil
+	Size  IntSize   // Size is the length prefix for strings, blobs and slices
+	Skip  bool      // Skip ignores the field entirely
+}
+
+// intKind describes the width and signedness of an integer wire kind.
+type intKind struct {
+	bits   uint
+	signed bool
+}
+
+var intKinds = map[string]intKind{ //nolint:gochecknoglobals
+	"u8":      {8, false},
+	"u16":     {16, false},
+	"u24":     {24, false},
+	"u32":     {32, false},
+	"u40":     {40, false},
+	"u48":     {48, false},
+	"u56":     {56, false},
+	"u64":     {64, false},
+	"uvarint": {64, false},
+	"i8":      {8, true},
+	"i16":     {16, true},
+	"i24":     {24, true},
+	"i32":     {32, true},
+	"i40":     {40, true},
+	"i48":     {48, true},
+	"i56":     {56, true},
+	"i64":     {64, true},
+	"varint":  {64, true},
+}
+
+var intSizes = map[string]IntSize{ //nolint:gochecknoglobals
+	"I8":   I8,
+	"I16":  I16,
+	"I24":  I24,
+	"I32":  I32,
+	"I40":  I40,
+	"I64":  I64,
+	"IVar": IVar,
+}
+
+// ParseFieldTag parses the value of an io struct tag. It is also used by code generators, so that
+// the same vocabulary is used everywhere.
+func ParseFieldTag(tag string) (FieldTag, error) {
+	res := FieldTag{Size: IVar}
+
+	if tag == "-" {
+		res.Skip = true
+		return res, nil
+	}
+
+	if tag == "" {
+		return res, nil
+	}
+
+	for i, opt := range strings.Split(tag, ",") {
+		opt = strings.TrimSpace(opt)
+
+		switch opt {
+		case "le":
+			res.Order = LittleEndian
+			continue
+		case "be":
+			res.Order = BigEndian
+			continue
+		}
+
+		if size, ok := intSizes[opt]; ok {
+			res.Size = size
+			continue
+		}
+
+		if i == 0 && isWireKind(opt) {
+			res.Kind = opt
+			continue
+		}
+
+		return res, fmt.Errorf("invalid io tag option '%s' in '%s'", opt, tag)
+	}
+
+	return res, nil
+}
+
+func isWireKind(kind string) bool {
+	if _, ok := intKinds[kind]; ok {
+		return true
+	}
+
+	switch kind {
+	case "f32", "f64", "c64", "c128", "bool", "blob", "utf8":
+		return true
+	default:
+		return false
+	}
+}
+
+// defaultKind returns the wire kind for a Go type, if it has no explicit kind.
+func defaultKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int8:
+		return "i8"
+	case reflect.Int16:
+		return "i16"
+	case reflect.Int32:
+		return "i32"
+	case reflect.Int, reflect.Int64:
+		return "i64"
+	case reflect.Uint8:
+		return "u8"
+	case reflect.Uint16:
+		return "u16"
+	case reflect.Uint32:
+		return "u32"
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return "u64"
+	case reflect.Float32:
+		return "f32"
+	case reflect.Float64:
+		return "f64"
+	case reflect.Complex64:
+		return "c64"
+	case reflect.Complex128:
+		return "c128"
+	case reflect.String:
+		return "utf8"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "blob"
+		}
+	}
+
+	return ""
+}
+
+// isBlob returns true, if the value is a string or byte slice which is encoded as a single prefixed blob.
+func (f FieldTag) isBlob(t reflect.Type) bool {
+	if f.Kind != "" && f.Kind != "blob" && f.Kind != "utf8" {
+		return false
+	}
+
+	return t.Kind() == reflect.String || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8)
+}
+
+func (e *Encoder) writeValue(o ByteOrder, tag FieldTag, v reflect.Value) {
+	if tag.Order != nil {
+		o = tag.Order
+	}
+
+	t := v.Type()
+
+	switch {
+	case tag.isBlob(t):
+		if t.Kind() == reflect.String {
+			e.WriteUTF8(o, tag.Size, v.String())
+		} else {
+			e.WriteBlob(o, tag.Size, v.Bytes())
+		}
+
+		return
+	case t.Kind() == reflect.Struct:
+		e.writeStruct(o, v)
+		return
+	case t.Kind() == reflect.Array:
+		for i := 0; i < v.Len() && !e.quickFail(); i++ {
+			e.writeValue(o, tag, v.Index(i))
+		}
+
+		return
+	case t.Kind() == reflect.Slice:
+		if !e.writeSize("WriteStruct", o, tag.Size, v.Len()) {
+			return
+		}
+
+		for i := 0; i < v.Len() && !e.quickFail(); i++ {
+			e.writeValue(o, tag, v.Index(i))
+		}
+
+		return
+	}
+
+	kind := tag.Kind
+	if kind == "" {
+		kind = defaultKind(t)
+	}
+
+	if ik, ok := intKinds[kind]; ok {
+		e.writeInt(o, kind, ik, v)
+		return
+	}
+
+	switch {
+	case kind == "bool" && t.Kind() == reflect.Bool:
+		e.WriteBool(v.Bool())
+	case kind == "f32" && isFloat(t):
+		e.WriteFloat32(o, float32(v.Float()))
+	case kind == "f64" && isFloat(t):
+		e.WriteFloat64(o, v.Float())
+	case kind == "c64" && isComplex(t):
+		e.WriteComplex64(o, complex64(v.Complex()))
+	case kind == "c128" && isComplex(t):
+		e.WriteComplex128(o, v.Complex())
+	default:
+		e.noteErr("WriteStruct", o, e.pos, fmt.Errorf("cannot encode %s as '%s'", t, kind))
+	}
+}
+
+func (e *Encoder) writeStruct(o ByteOrder, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField() && !e.quickFail(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		tag, err := ParseFieldTag(field.Tag.Get(TagName))
+		if err != nil {
+			e.noteErr("WriteStruct", o, e.pos, fmt.Errorf("field %s.%s: %w", t, field.Name, err))
+			return
+		}
+
+		if tag.Skip {
+			continue
+		}
+
+		e.writeValue(o, tag, v.Field(i))
+	}
+}
+
+func (e *Encoder) writeInt(o ByteOrder, kind string, ik intKind, v reflect.Value) {
+	var (
+		u        uint64
+		negative bool
+	)
+
+	switch {
+	case isSigned(v.Type()):
+		s := v.Int()
+		u = uint64(s)
+		negative = s < 0
+
+		if ik.signed && ik.bits < 64 && (s < -1<<(ik.bits-1) || s > 1<<(ik.bits-1)-1) {
+			e.noteErr("WriteStruct", o, e.pos, IntegerOverflow{Val: s, Max: uint64(1)<<(ik.bits-1) - 1})
+			return
+		}
+	case isUnsigned(v.Type()):
+		u = v.Uint()
+		if ik.signed && u > uint64(1)<<(ik.bits-1)-1 {
+			e.noteErr("WriteStruct", o, e.pos, IntegerOverflow{Val: u, Max: uint64(1)<<(ik.bits-1) - 1})
+			return
+		}
+	default:
+		e.noteErr("WriteStruct", o, e.pos, fmt.Errorf("cannot encode %s as '%s'", v.Type(), kind))
+		return
+	}
+
+	if !ik.signed && (negative || (ik.bits < 64 && u > uint64(1)<<ik.bits-1)) {
+		e.noteErr("WriteStruct", o, e.pos, IntegerOverflow{Val: v.Interface(), Max: uint64(1)<<ik.bits - 1})
+		return
+	}
+
+	switch kind {
+	case "u8", "i8":
+		e.WriteUint8(uint8(u))
+	case "u16", "i16":
+		e.WriteUint16(o, uint16(u))
+	case "u24", "i24":
+		e.WriteUint24(o, uint32(u))
+	case "u32", "i32":
+		e.WriteUint32(o, uint32(u))
+	case "u40", "i40":
+		e.WriteUint40(o, u)
+	case "u48", "i48":
+		e.WriteUint48(o, u)
+	case "u56", "i56":
+		e.WriteUint56(o, u)
+	case "u64", "i64":
+		e.WriteUint64(o, u)
+	case "uvarint":
+		e.WriteUvarint(u)
+	case "varint":
+		e.WriteVarint(int64(u))
+	}
+}
+
+func (r *Decoder) readValue(o ByteOrder, tag FieldTag, v reflect.Value) {
+	if tag.Order != nil {
+		o = tag.Order
+	}
+
+	t := v.Type()
+
+	switch {
+	case tag.isBlob(t):
+		buf := r.ReadBlob(o, tag.Size)
+		if r.quickFail() {
+			return
+		}
+
+		if t.Kind() == reflect.String {
+			v.SetString(string(buf))
+		} else {
+			v.SetBytes(buf)
+		}
+
+		return
+	case t.Kind() == reflect.Struct:
+		r.readStruct(o, v)
+		return
+	case t.Kind() == reflect.Array:
+		for i := 0; i < v.Len() && !r.quickFail(); i++ {
+			r.readValue(o, tag, v.Index(i))
+		}
+
+		return
+	case t.Kind() == reflect.Slice:
+		n, ok := r.readSize("ReadStruct", o, tag.Size)
+		if !ok {
+			return
+		}
+
+		// do not trust the prefix for the allocation, the elements must be readable first
+		slice := reflect.MakeSlice(t, 0, 0)
+		elem := reflect.New(t.Elem()).Elem()
+
+		for i := 0; i < n; i++ {
+			elem.Set(reflect.Zero(t.Elem()))
+			r.readValue(o, tag, elem)
+
+			if r.quickFail() {
+				return
+			}
+
+			slice = reflect.Append(slice, elem)
+		}
+
+		v.Set(slice)
+
+		return
+	}
+
+	kind := tag.Kind
+	if kind == "" {
+		kind = defaultKind(t)
+	}
+
+	if ik, ok := intKinds[kind]; ok {
+		r.readInt(o, kind, ik, v)
+		return
+	}
+
+	offset := r.pos
+
+	switch {
+	case kind == "bool" && t.Kind() == reflect.Bool:
+		v.SetBool(r.ReadBool())
+	case kind == "f32" && isFloat(t):
+		v.SetFloat(float64(r.ReadFloat32(o)))
+	case kind == "f64" && isFloat(t):
+		f := r.ReadFloat64(o)
+		if v.OverflowFloat(f) {
+			r.noteErr("ReadStruct", o, offset, fmt.Errorf("float64 value %v overflows %s", f, t))
+			return
+		}
+
+		v.SetFloat(f)
+	case kind == "c64" && isComplex(t):
+		v.SetComplex(complex128(r.ReadComplex64(o)))
+	case kind == "c128" && isComplex(t):
+		c := r.ReadComplex128(o)
+		if v.OverflowComplex(c) {
+			r.noteErr("ReadStruct", o, offset, fmt.Errorf("complex128 value %v overflows %s", c, t))
+			return
+		}
+
+		v.SetComplex(c)
+	default:
+		r.noteErr("ReadStruct", o, offset, fmt.Errorf("cannot decode '%s' into %s", kind, t))
+	}
+}
+
+func (r *Decoder) readStruct(o ByteOrder, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField() && !r.quickFail(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		tag, err := ParseFieldTag(field.Tag.Get(TagName))
+		if err != nil {
+			r.noteErr("ReadStruct", o, r.pos, fmt.Errorf("field %s.%s: %w", t, field.Name, err))
+			return
+		}
+
+		if tag.Skip {
+			continue
+		}
+
+		r.readValue(o, tag, v.Field(i))
+	}
+}
+
+func (r *Decoder) readInt(o ByteOrder, kind string, ik intKind, v reflect.Value) {
+	offset := r.pos
+
+	if !isSigned(v.Type()) && !isUnsigned(v.Type()) {
+		r.noteErr("ReadStruct", o, offset, fmt.Errorf("cannot decode '%s' into %s", kind, v.Type()))
+		return
+	}
+
+	var (
+		u uint64
+		s int64
+	)
+
+	switch kind {
+	case "u8":
+		u = uint64(r.ReadUint8())
+	case "u16":
+		u = uint64(r.ReadUint16(o))
+	case "u24":
+		u = uint64(r.ReadUint24(o))
+	case "u32":
+		u = uint64(r.ReadUint32(o))
+	case "u40":
+		u = r.ReadUint40(o)
+	case "u48":
+		u = r.ReadUint48(o)
+	case "u56":
+		u = r.ReadUint56(o)
+	case "u64":
+		u = r.ReadUint64(o)
+	case "uvarint":
+		u = r.ReadUvarint()
+	case "i8":
+		s = int64(r.ReadInt8())
+	case "i16":
+		s = int64(r.ReadInt16(o))
+	case "i24":
+		s = int64(r.ReadInt24(o))
+	case "i32":
+		s = int64(r.ReadInt32(o))
+	case "i40":
+		s = r.ReadInt40(o)
+	case "i48":
+		s = r.ReadInt48(o)
+	case "i56":
+		s = r.ReadInt56(o)
+	case "i64":
+		s = r.ReadInt64(o)
+	case "varint":
+		s = r.ReadVarint()
+	}
+
+	if r.quickFail() {
+		return
+	}
+
+	if ik.signed {
+		if isSigned(v.Type()) {
+			if v.OverflowInt(s) {
+				r.noteErr("ReadStruct", o, offset, IntegerOverflow{Val: s, Max: maxOf(v.Type())})
+				return
+			}
+
+			v.SetInt(s)
+
+			return
+		}
+
+		if s < 0 || v.OverflowUint(uint64(s)) {
+			r.noteErr("ReadStruct", o, offset, IntegerOverflow{Val: s, Max: maxOf(v.Type())})
+			return
+		}
+
+		v.SetUint(uint64(s))
+
+		return
+	}
+
+	if isSigned(v.Type()) {
+		if u > uint64(MaxInt64) || v.OverflowInt(int64(u)) {
+			r.noteErr("ReadStruct", o, offset, IntegerOverflow{Val: u, Max: maxOf(v.Type())})
+			return
+		}
+
+		v.SetInt(int64(u))
+
+		return
+	}
+
+	if v.OverflowUint(u) {
+		r.noteErr("ReadStruct", o, offset, IntegerOverflow{Val: u, Max: maxOf(v.Type())})
+		return
+	}
+
+	v.SetUint(u)
+}
+
+// maxOf returns the largest value, which fits into the given integer type.
+func maxOf(t reflect.Type) uint64 {
+	if isSigned(t) {
+		return uint64(1)<<(t.Bits()-1) - 1
+	}
+
+	return uint64(1)<<(t.Bits()-1)<<1 - 1
+}
+
+func isSigned(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	default:
+		return false
+	}
+}
+
+func isUnsigned(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	default:
+		return false
+	}
+}
+
+func isFloat(t reflect.Type) bool {
+	return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+}
+
+func isComplex(t reflect.Type) bool {
+	return t.Kind() == reflect.Complex64 || t.Kind() == reflect.Complex128
+}
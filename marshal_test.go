@@ -0,0 +1,145 @@
+package ioutil
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type marshalInner struct {
+	A uint32 `io:"u24,be"`
+	B string `io:"utf8,I8"`
+}
+
+type marshalRecord struct {
+	U8      uint8
+	I16     int16
+	U24     uint32 `io:"u24,le"`
+	I24     int32  `io:"i24"`
+	U40     uint64 `io:"u40"`
+	I40     int64  `io:"i40"`
+	U48     uint64 `io:"u48,be"`
+	I48     int64  `io:"i48"`
+	U56     uint64 `io:"u56"`
+	I56     int64  `io:"i56"`
+	Var     int64  `io:"varint"`
+	UVar    int    `io:"uvarint"`
+	F32     float32
+	F64     float64
+	C64     complex64
+	C128    complex128
+	Flag    bool
+	Blob    []byte `io:"blob,I16"`
+	Name    string
+	Fixed   [3]uint16 `io:"u24"`
+	Values  []int32   `io:"i40,I8"`
+	Inner   marshalInner
+	Inners  []marshalInner `io:"I24"`
+	Skipped int            `io:"-"`
+	hidden  int
+}
+
+func TestMarshal(t *testing.T) {
+	src := marshalRecord{
+		U8:     1,
+		I16:    -2,
+		U24:    MaxUint24,
+		I24:    MinInt24,
+		U40:    MaxUint40,
+		I40:    MinInt40,
+		U48:    MaxUint48,
+		I48:    -3,
+		U56:    MaxUint56,
+		I56:    MinInt56,
+		Var:    -4,
+		UVar:   300,
+		F32:    1.5,
+		F64:    -2.25,
+		C64:    complex(1, 2),
+		C128:   complex(-3, 4),
+		Flag:   true,
+		Blob:   []byte{1, 2, 3},
+		Name:   "hello",
+		Fixed:  [3]uint16{5, 6, 7},
+		Values: []int32{-1, 0, 1},
+		Inner:  marshalInner{A: 8, B: "world"},
+		Inners: []marshalInner{{A: 9, B: "x"}, {A: 10, B: "y"}},
+	}
+
+	for _, o := range []ByteOrder{LittleEndian, BigEndian} {
+		buf := &bytes.Buffer{}
+		if err := Marshal(o, buf, &src); err != nil {
+			t.Fatal(err)
+		}
+
+		var dst marshalRecord
+		if err := Unmarshal(o, buf, &dst); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(src, dst) {
+			t.Fatalf("expected \n%+v\n but got \n%+v", src, dst)
+		}
+
+		if buf.Len() != 0 {
+			t.Fatalf("expected all bytes to be consumed but %d are left", buf.Len())
+		}
+	}
+}
+
+func TestMarshalHandWritten(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := Marshal(BigEndian, buf, &marshalInner{A: 0x010203, B: "ab"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &bytes.Buffer{}
+	dout := NewDataOutput(BigEndian, expected)
+	dout.WriteUint24(0x010203)
+	dout.WriteUTF8(I8, "ab")
+
+	if !bytes.Equal(expected.Bytes(), buf.Bytes()) {
+		t.Fatalf("expected \n%v\n but got \n%v", expected.Bytes(), buf.Bytes())
+	}
+}
+
+func TestMarshalOverflow(t *testing.T) {
+	type narrow struct {
+		V uint32 `io:"u8"`
+	}
+
+	err := Marshal(LittleEndian, &bytes.Buffer{}, narrow{V: 256})
+
+	var overflow IntegerOverflow
+	if !errors.As(err, &overflow) {
+		t.Fatalf("expected IntegerOverflow but got %v", err)
+	}
+
+	type wide struct {
+		V uint8 `io:"u16"`
+	}
+
+	err = Unmarshal(LittleEndian, bytes.NewReader([]byte{0, 1}), &wide{})
+	if !errors.As(err, &overflow) {
+		t.Fatalf("expected IntegerOverflow but got %v", err)
+	}
+}
+
+func TestMarshalNil(t *testing.T) {
+	type record struct {
+		V uint32
+	}
+
+	if err := Marshal(LittleEndian, &bytes.Buffer{}, nil); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if err := Marshal(LittleEndian, &bytes.Buffer{}, (*record)(nil)); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if err := Unmarshal(LittleEndian, &bytes.Buffer{}, nil); err == nil {
+		t.Fatal("expected an error")
+	}
+}
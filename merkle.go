@@ -0,0 +1,435 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// Domain separation prefixes as defined by RFC 6962, so that a leaf can never be mistaken for an inner node.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// A MerkleTree is a binary hash tree over a stream, which has been split into leaves of a fixed size. The tree
+// is built like the Merkle Tree Hash of RFC 6962, so that an inclusion proof for any range of leaves only needs
+// a logarithmic amount of hashes. Only the leaf hashes are stored, so Root and Proof calculate the inner nodes
+// on each call, which costs about one hash per leaf.
+type MerkleTree struct {
+	LeafSize int              // LeafSize is the size of each leaf, only the last leaf may be smaller.
+	Length   int64            // Length is the total length of the stream.
+	Leaves   [][]byte         // Leaves contains the hash of each leaf.
+	NewHash  func() hash.Hash // NewHash creates the hash for the leaves and inner nodes and must not be nil.
+}
+
+// Root returns the root hash of the tree. The root of an empty stream is the hash of no bytes. The root is
+// calculated again on each call, so keep it instead of calling Root repeatedly.
+func (t *MerkleTree) Root() []byte {
+	if len(t.Leaves) == 0 {
+		return t.NewHash().Sum(nil)
+	}
+
+	return merkleRoot(t.NewHash, 0, len(t.Leaves), func(i int) []byte { return t.Leaves[i] })
+}
+
+// Proof creates an inclusion proof for the given byte range. The proof covers all leaves, which are touched by
+// the range, so the verifier needs the data from MerkleProof.Offset to MerkleProof.End. Like Root, it hashes the
+// inner nodes of the whole tree.
+func (t *MerkleTree) Proof(offset, length int64) (*MerkleProof, error) {
+	if offset < 0 || length <= 0 || offset+length > t.Length {
+		return nil, fmt.Errorf("invalid range %d+%d for a stream of %d bytes", offset, length, t.Length)
+	}
+
+	p := &MerkleProof{
+		LeafSize: t.LeafSize,
+		Length:   t.Length,
+		First:    int(offset / int64(t.LeafSize)),
+		Last:     int((offset + length - 1) / int64(t.LeafSize)),
+	}
+
+	p.walk(t.NewHash, 0, len(t.Leaves), func(lo, hi int) []byte {
+		h := merkleRoot(t.NewHash, lo, hi, func(i int) []byte { return t.Leaves[i] })
+		p.Hashes = append(p.Hashes, h)
+
+		return h
+	}, nil)
+
+	return p, nil
+}
+
+// EncodeTo writes the leaf size, the length and all leaf hashes. It returns an IntegerOverflow without writing
+// anything, if the leaf size does not fit into 4 bytes, otherwise the error of dout.
+func (t *MerkleTree) EncodeTo(dout DataOutput) error {
+	if t.LeafSize < 0 || uint64(t.LeafSize) > uint64(MaxUint32) {
+		return IntegerOverflow{Val: t.LeafSize, Max: MaxUint32}
+	}
+
+	dout.WriteUint32(uint32(t.LeafSize))
+	dout.WriteUint64(uint64(t.Length))
+	dout.WriteUvarint(uint64(len(t.Leaves)))
+
+	for _, leaf := range t.Leaves {
+		dout.WriteBytes(leaf...)
+	}
+
+	return dout.Error()
+}
+
+// DecodeMerkleTree reads a tree, which has been written by MerkleTree.EncodeTo. The given hash constructor must
+// create the same kind of hash, which has been used to build the tree.
+func DecodeMerkleTree(din DataInput, newHash func() hash.Hash) (*MerkleTree, error) {
+	t := &MerkleTree{NewHash: newHash}
+	t.LeafSize = int(din.ReadUint32())
+	t.Length = int64(din.ReadUint64())
+	n := din.ReadUvarint()
+
+	if din.Error() != nil {
+		return nil, din.Error()
+	}
+
+	if err := checkMerkleShape(t.LeafSize, t.Length, n); err != nil {
+		return nil, err
+	}
+
+	hashSize := newHash().Size()
+	t.Leaves = make([][]byte, 0, minInt(int(n), 1024))
+
+	for i := uint64(0); i < n && din.Error() == nil; i++ {
+		t.Leaves = append(t.Leaves, din.ReadBytes(hashSize))
+	}
+
+	if din.Error() != nil {
+		return nil, din.Error()
+	}
+
+	return t, nil
+}
+
+// A MerkleProof proves, that the leaves First to Last (inclusive) belong to a MerkleTree with a known root.
+type MerkleProof struct {
+	LeafSize int      // LeafSize is the size of each leaf, only the last leaf may be smaller.
+	Length   int64    // Length is the total length of the stream.
+	First    int      // First is the index of the first proven leaf.
+	Last     int      // Last is the index of the last proven leaf.
+	Hashes   [][]byte // Hashes are the roots of all subtrees, which do not contain any proven leaf.
+}
+
+// Offset returns the position of the first byte, which must be passed to Verify.
+func (p *MerkleProof) Offset() int64 {
+	return int64(p.First) * int64(p.LeafSize)
+}
+
+// End returns the position after the last byte, which must be passed to Verify.
+func (p *MerkleProof) End() int64 {
+	end := int64(p.Last+1) * int64(p.LeafSize)
+	if end > p.Length {
+		end = p.Length
+	}
+
+	return end
+}
+
+// Verify checks that data, which is the range from Offset to End of the stream, belongs to the tree with the
+// given root. It returns a LengthMismatch, if data has the wrong size and a ChecksumMismatch, if the calculated
+// root is not the expected one.
+func (p *MerkleProof) Verify(newHash func() hash.Hash, root []byte, data []byte) error {
+	if int64(len(data)) != p.End()-p.Offset() {
+		return LengthMismatch{Expected: p.End() - p.Offset(), Actual: int64(len(data))}
+	}
+
+	n, err := merkleLeafCount(p.LeafSize, p.Length)
+	if err != nil {
+		return err
+	}
+
+	if p.First < 0 || p.First > p.Last || p.Last >= n {
+		return fmt.Errorf("invalid proof for leaves %d-%d of %d", p.First, p.Last, n)
+	}
+
+	leaves := make([][]byte, p.Last-p.First+1)
+	for i := range leaves {
+		start := i * p.LeafSize
+		end := minInt(start+p.LeafSize, len(data))
+		leaves[i] = merkleLeaf(newHash(), data[start:end])
+	}
+
+	next := 0
+	actual := p.walk(newHash, 0, n, func(lo, hi int) []byte {
+		if next >= len(p.Hashes) {
+			return nil
+		}
+
+		next++
+
+		return p.Hashes[next-1]
+	}, func(lo, hi int) []byte {
+		return merkleRoot(newHash, lo, hi, func(i int) []byte { return leaves[i-p.First] })
+	})
+
+	if next != len(p.Hashes) || actual == nil {
+		return fmt.Errorf("invalid proof: expected %d hashes but got %d", next, len(p.Hashes))
+	}
+
+	if !bytes.Equal(actual, root) {
+		return ChecksumMismatch{Expected: root, Actual: actual}
+	}
+
+	return nil
+}
+
+// walk visits the subtrees of the leaves lo to hi in proof order. Subtrees without any proven leaf are
+// resolved by other, subtrees of proven leaves only are resolved by proven, if not nil. It returns the root
+// of the subtree or nil, if any subtree could not be resolved.
+func (p *MerkleProof) walk(newHash func() hash.Hash, lo, hi int, other, proven func(lo, hi int) []byte) []byte {
+	switch {
+	case hi <= p.First || lo > p.Last:
+		return other(lo, hi)
+	case lo >= p.First && hi-1 <= p.Last:
+		if proven == nil {
+			return nil
+		}
+
+		return proven(lo, hi)
+	}
+
+	k := merkleSplit(hi - lo)
+	left := p.walk(newHash, lo, lo+k, other, proven)
+	right := p.walk(newHash, lo+k, hi, other, proven)
+
+	if left == nil || right == nil {
+		return nil
+	}
+
+	return merkleNode(newHash(), left, right)
+}
+
+// EncodeTo writes the proof. Like MerkleTree.EncodeTo, it returns an IntegerOverflow for a leaf size, which
+// does not fit into 4 bytes, otherwise the error of dout.
+func (p *MerkleProof) EncodeTo(dout DataOutput) error {
+	if p.LeafSize < 0 || uint64(p.LeafSize) > uint64(MaxUint32) {
+		return IntegerOverflow{Val: p.LeafSize, Max: MaxUint32}
+	}
+
+	dout.WriteUint32(uint32(p.LeafSize))
+	dout.WriteUint64(uint64(p.Length))
+	dout.WriteUvarint(uint64(p.First))
+	dout.WriteUvarint(uint64(p.Last))
+	dout.WriteUvarint(uint64(len(p.Hashes)))
+
+	for _, h := range p.Hashes {
+		dout.WriteBlob(I8, h)
+	}
+
+	return dout.Error()
+}
+
+// DecodeMerkleProof reads a proof, which has been written by MerkleProof.EncodeTo.
+func DecodeMerkleProof(din DataInput) (*MerkleProof, error) {
+	p := &MerkleProof{}
+	p.LeafSize = int(din.ReadUint32())
+	p.Length = int64(din.ReadUint64())
+	p.First = int(din.ReadUvarint())
+	p.Last = int(din.ReadUvarint())
+	n := din.ReadUvarint()
+
+	if din.Error() != nil {
+		return nil, din.Error()
+	}
+
+	// a proof contains at most two hashes per level
+	if n > 128 {
+		return nil, fmt.Errorf("invalid proof with %d hashes", n)
+	}
+
+	for i := uint64(0); i < n && din.Error() == nil; i++ {
+		p.Hashes = append(p.Hashes, din.ReadBlob(I8))
+	}
+
+	if din.Error() != nil {
+		return nil, din.Error()
+	}
+
+	return p, nil
+}
+
+// merkleBuilder hashes a stream into leaves of a fixed size.
+type merkleBuilder struct {
+	newHash  func() hash.Hash
+	leafSize int
+	hasher   hash.Hash // hasher contains the current leaf, which has fill bytes so far
+	fill     int
+	leaves   [][]byte
+	length   int64
+}
+
+func newMerkleBuilder(newHash func() hash.Hash, leafSize int) merkleBuilder {
+	if leafSize <= 0 {
+		panic("invalid leaf size")
+	}
+
+	return merkleBuilder{newHash: newHash, leafSize: leafSize, hasher: newHash()}
+}
+
+func (b *merkleBuilder) write(p []byte) {
+	b.length += int64(len(p))
+
+	for len(p) > 0 {
+		if b.fill == 0 {
+			b.hasher.Reset()
+			_, _ = b.hasher.Write([]byte{merkleLeafPrefix})
+		}
+
+		n := minInt(b.leafSize-b.fill, len(p))
+		_, _ = b.hasher.Write(p[:n]) // a hash.Hash never returns an error
+		b.fill += n
+		p = p[n:]
+
+		if b.fill == b.leafSize {
+			b.leaves = append(b.leaves, b.hasher.Sum(nil))
+			b.fill = 0
+		}
+	}
+}
+
+// tree returns the tree of all bytes so far. A pending partial leaf is included as the last leaf.
+func (b *merkleBuilder) tree() *MerkleTree {
+	leaves := append([][]byte(nil), b.leaves...)
+	if b.fill > 0 {
+		leaves = append(leaves, b.hasher.Sum(nil))
+	}
+
+	return &MerkleTree{LeafSize: b.leafSize, Length: b.length, Leaves: leaves, NewHash: b.newHash}
+}
+
+// A MerkleHashReader builds a MerkleTree for every transferred byte, like a HashReader calculates a hash.
+type MerkleHashReader struct {
+	reader  io.Reader
+	builder merkleBuilder
+}
+
+// NewMerkleHashReader creates a new instance, which splits the stream into leaves of leafSize bytes and hashes
+// each leaf using a new hash from newHash.
+func NewMerkleHashReader(newHash func() hash.Hash, leafSize int, reader io.Reader) *MerkleHashReader {
+	return &MerkleHashReader{reader: reader, builder: newMerkleBuilder(newHash, leafSize)}
+}
+
+func (h *MerkleHashReader) Read(p []byte) (n int, err error) {
+	n, err = h.reader.Read(p)
+	h.builder.write(p[0:n])
+
+	return n, err
+}
+
+// Tree returns the tree of all bytes read so far.
+func (h *MerkleHashReader) Tree() *MerkleTree {
+	return h.builder.tree()
+}
+
+// A MerkleHashWriter builds a MerkleTree for every transferred byte, like a HashWriter calculates a hash.
+type MerkleHashWriter struct {
+	writer  io.Writer
+	builder merkleBuilder
+}
+
+// NewMerkleHashWriter creates a new instance, which splits the stream into leaves of leafSize bytes and hashes
+// each leaf using a new hash from newHash.
+func NewMerkleHashWriter(newHash func() hash.Hash, leafSize int, writer io.Writer) *MerkleHashWriter {
+	return &MerkleHashWriter{writer: writer, builder: newMerkleBuilder(newHash, leafSize)}
+}
+
+// Write writes p into the wrapped writer and hashes only those bytes, which have been actually written.
+func (h *MerkleHashWriter) Write(p []byte) (n int, err error) {
+	n, err = h.writer.Write(p)
+	h.builder.write(p[0:n])
+
+	return n, err
+}
+
+// Tree returns the tree of all bytes written so far.
+func (h *MerkleHashWriter) Tree() *MerkleTree {
+	return h.builder.tree()
+}
+
+func merkleLeaf(h hash.Hash, data []byte) []byte {
+	_, _ = h.Write([]byte{merkleLeafPrefix})
+	_, _ = h.Write(data)
+
+	return h.Sum(nil)
+}
+
+// merkleRoot calculates the root of the leaves lo to hi.
+func merkleRoot(newHash func() hash.Hash, lo, hi int, leaf func(i int) []byte) []byte {
+	if hi-lo == 1 {
+		return leaf(lo)
+	}
+
+	k := merkleSplit(hi - lo)
+
+	return merkleNode(newHash(), merkleRoot(newHash, lo, lo+k, leaf), merkleRoot(newHash, lo+k, hi, leaf))
+}
+
+// merkleNode combines the roots of two subtrees.
+func merkleNode(h hash.Hash, left, right []byte) []byte {
+	_, _ = h.Write([]byte{merkleNodePrefix})
+	_, _ = h.Write(left)
+	_, _ = h.Write(right)
+
+	return h.Sum(nil)
+}
+
+// merkleSplit returns the largest power of two, which is smaller than n > 1.
+func merkleSplit(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+
+	return k
+}
+
+func merkleLeafCount(leafSize int, length int64) (int, error) {
+	if leafSize <= 0 || length < 0 {
+		return 0, fmt.Errorf("invalid leaf size %d or length %d", leafSize, length)
+	}
+
+	return int((length + int64(leafSize) - 1) / int64(leafSize)), nil
+}
+
+func checkMerkleShape(leafSize int, length int64, leaves uint64) error {
+	n, err := merkleLeafCount(leafSize, length)
+	if err != nil {
+		return err
+	}
+
+	if uint64(n) != leaves {
+		return fmt.Errorf("invalid tree: expected %d leaves but got %d", n, leaves)
+	}
+
+	return nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
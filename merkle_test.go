@@ -0,0 +1,118 @@
+package ioutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+func TestMerkleTree(t *testing.T) {
+	src := make([]byte, 10*1024+17)
+	rand.New(rand.NewSource(1)).Read(src)
+
+	writer := NewMerkleHashWriter(sha256.New, 1024, ioutil.Discard)
+	if _, err := writer.Write(src[:3000]); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := writer.Write(src[3000:]); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewMerkleHashReader(sha256.New, 1024, bytes.NewReader(src))
+	if _, err := io.Copy(ioutil.Discard, reader); err != nil {
+		t.Fatal(err)
+	}
+
+	tree := writer.Tree()
+	root := tree.Root()
+
+	if len(tree.Leaves) != 11 || !bytes.Equal(root, reader.Tree().Root()) {
+		t.Fatalf("reader and writer must build the same tree")
+	}
+
+	// the tree survives a round trip through DataOutput
+	buf := &bytes.Buffer{}
+	if err := tree.EncodeTo(NewDataOutput(LittleEndian, buf)); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeMerkleTree(NewDataInput(LittleEndian, buf), sha256.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decoded.Root(), root) {
+		t.Fatalf("expected root %x but got %x", root, decoded.Root())
+	}
+
+	for _, r := range [][2]int64{{0, 1}, {0, int64(len(src))}, {1023, 2}, {5000, 3000}, {int64(len(src)) - 1, 1}} {
+		proof, err := tree.Proof(r[0], r[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if proof.Offset() > r[0] || proof.End() < r[0]+r[1] {
+			t.Fatalf("proof %d-%d does not cover %v", proof.Offset(), proof.End(), r)
+		}
+
+		buf.Reset()
+		if err := proof.EncodeTo(NewDataOutput(BigEndian, buf)); err != nil {
+			t.Fatal(err)
+		}
+
+		proof, err = DecodeMerkleProof(NewDataInput(BigEndian, buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data := src[proof.Offset():proof.End()]
+		if err := proof.Verify(sha256.New, root, data); err != nil {
+			t.Fatalf("%v: %v", r, err)
+		}
+
+		corrupted := append([]byte(nil), data...)
+		corrupted[len(corrupted)/2] ^= 1
+
+		var mismatch ChecksumMismatch
+		if err := proof.Verify(sha256.New, root, corrupted); !errors.As(err, &mismatch) {
+			t.Fatalf("%v: expected a checksum mismatch but got %v", r, err)
+		}
+
+		if err := proof.Verify(sha256.New, root, data[1:]); err == nil {
+			t.Fatalf("%v: expected a length mismatch", r)
+		}
+	}
+
+	if _, err := tree.Proof(int64(len(src)), 1); err == nil {
+		t.Fatal("expected an error for a range beyond the stream")
+	}
+
+	// a declared tree works like a built one
+	declared := &MerkleTree{LeafSize: tree.LeafSize, Length: tree.Length, Leaves: tree.Leaves, NewHash: sha256.New}
+	if !bytes.Equal(declared.Root(), root) {
+		t.Fatalf("expected root %x but got %x", root, declared.Root())
+	}
+
+	if uint64(MaxInt) > uint64(MaxUint32) {
+		buf.Reset()
+		declared.LeafSize = MaxInt
+
+		if err := declared.EncodeTo(NewDataOutput(LittleEndian, buf)); !errors.As(err, &IntegerOverflow{}) || buf.Len() != 0 {
+			t.Fatalf("expected an IntegerOverflow but got %v", err)
+		}
+	}
+}
+
+func TestMerkleTree_Empty(t *testing.T) {
+	tree := NewMerkleHashWriter(sha256.New, 16, ioutil.Discard).Tree()
+	empty := sha256.Sum256(nil)
+
+	if !bytes.Equal(tree.Root(), empty[:]) {
+		t.Fatalf("expected %x but got %x", empty, tree.Root())
+	}
+}
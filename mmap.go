@@ -0,0 +1,262 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// A MappedFile maps a file into memory, so that huge files can be parsed without copying them into a buffer.
+// Mapping is only supported on linux, other platforms return an error.
+// It provides the positional reads of a CheckedLittleEndianBuffer, which report a BufferOverrun when reading
+// beyond the mapped size, and a DataInput for any byte order. The mapping is read only, so there are no write
+// methods. The file must not shrink while it is mapped: reading pages, which have been truncated by another
+// process, raises a SIGBUS, which crashes the program. A MappedFile is not thread safe, but ReadAt is.
+type MappedFile struct {
+	buf    CheckedLittleEndianBuffer
+	mapped []byte
+}
+
+// OpenMappedFile maps the entire file with the given name into memory. The file descriptor is closed
+// immediately, the mapping stays valid until Close is called.
+func OpenMappedFile(name string) (*MappedFile, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := stat.Size()
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("file %s is too large to be mapped: %d bytes", name, size)
+	}
+
+	data, err := mmap(file, int(size))
+	if err != nil {
+		return nil, fmt.Errorf("failed to map %s: %w", name, err)
+	}
+
+	return &MappedFile{buf: *NewCheckedLittleEndianBuffer(data), mapped: data}, nil
+}
+
+// Len returns the amount of mapped bytes.
+func (m *MappedFile) Len() int {
+	return len(m.mapped)
+}
+
+// DataInput returns a new DataInput, which reads the mapped bytes from the start in the given byte order. Blobs
+// and strings refer to the mapped memory without copying, so they must not be used after Close.
+func (m *MappedFile) DataInput(order ByteOrder) DataInput {
+	return NewSliceDecoder(order, m.mapped)
+}
+
+// ReadAt reads len(p) bytes at the given offset, without changing the position. It returns io.EOF if less than
+// len(p) bytes are available.
+func (m *MappedFile) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+
+	if off >= int64(len(m.mapped)) {
+		if len(p) == 0 {
+			return 0, nil
+		}
+
+		return 0, io.EOF
+	}
+
+	n = copy(p, m.mapped[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Close unmaps the file. Afterwards, all positional reads fail with a BufferOverrun, but any slice or DataInput
+// which has been obtained before, must not be used anymore. Closing twice is a no-op.
+func (m *MappedFile) Close() error {
+	if m.mapped == nil {
+		return nil
+	}
+
+	err := munmap(m.mapped)
+	m.mapped = nil
+	m.buf.Bytes = nil
+	m.buf.Pos = 0
+
+	return err
+}
+
+// Pos returns the current position of the positional reads.
+func (m *MappedFile) Pos() int {
+	return m.buf.Pos
+}
+
+// SetPos sets the position of the next positional read. A position outside of the mapping is reported by the
+// next read.
+func (m *MappedFile) SetPos(pos int) {
+	m.buf.Pos = pos
+}
+
+// Error returns the first error of the positional reads.
+func (m *MappedFile) Error() error {
+	return m.buf.Error()
+}
+
+// Reset removes any error state of the positional reads.
+func (m *MappedFile) Reset() {
+	m.buf.Reset()
+}
+
+// ReadUint8 is the same as CheckedLittleEndianBuffer.ReadUint8.
+func (m *MappedFile) ReadUint8() uint8 {
+	return m.buf.ReadUint8()
+}
+
+// ReadUint16 is the same as CheckedLittleEndianBuffer.ReadUint16.
+func (m *MappedFile) ReadUint16() uint16 {
+	return m.buf.ReadUint16()
+}
+
+// ReadUint24 is the same as CheckedLittleEndianBuffer.ReadUint24.
+func (m *MappedFile) ReadUint24() uint32 {
+	return m.buf.ReadUint24()
+}
+
+// ReadUint32 is the same as CheckedLittleEndianBuffer.ReadUint32.
+func (m *MappedFile) ReadUint32() uint32 {
+	return m.buf.ReadUint32()
+}
+
+// ReadUint40 is the same as CheckedLittleEndianBuffer.ReadUint40.
+func (m *MappedFile) ReadUint40() uint64 {
+	return m.buf.ReadUint40()
+}
+
+// ReadUint48 is the same as CheckedLittleEndianBuffer.ReadUint48.
+func (m *MappedFile) ReadUint48() uint64 {
+	return m.buf.ReadUint48()
+}
+
+// ReadUint56 is the same as CheckedLittleEndianBuffer.ReadUint56.
+func (m *MappedFile) ReadUint56() uint64 {
+	return m.buf.ReadUint56()
+}
+
+// ReadUint64 is the same as CheckedLittleEndianBuffer.ReadUint64.
+func (m *MappedFile) ReadUint64() uint64 {
+	return m.buf.ReadUint64()
+}
+
+// ReadFloat32 is the same as CheckedLittleEndianBuffer.ReadFloat32.
+func (m *MappedFile) ReadFloat32() float32 {
+	return m.buf.ReadFloat32()
+}
+
+// ReadFloat64 is the same as CheckedLittleEndianBuffer.ReadFloat64.
+func (m *MappedFile) ReadFloat64() float64 {
+	return m.buf.ReadFloat64()
+}
+
+// ReadComplex64 is the same as CheckedLittleEndianBuffer.ReadComplex64.
+func (m *MappedFile) ReadComplex64() complex64 {
+	return m.buf.ReadComplex64()
+}
+
+// ReadComplex128 is the same as CheckedLittleEndianBuffer.ReadComplex128.
+func (m *MappedFile) ReadComplex128() complex128 {
+	return m.buf.ReadComplex128()
+}
+
+// ReadUvarint is the same as CheckedLittleEndianBuffer.ReadUvarint.
+func (m *MappedFile) ReadUvarint() uint64 {
+	return m.buf.ReadUvarint()
+}
+
+// ReadVarint is the same as CheckedLittleEndianBuffer.ReadVarint.
+func (m *MappedFile) ReadVarint() int64 {
+	return m.buf.ReadVarint()
+}
+
+// ReadSlice is the same as CheckedLittleEndianBuffer.ReadSlice.
+func (m *MappedFile) ReadSlice(v []byte) {
+	m.buf.ReadSlice(v)
+}
+
+// ReadBlob8 is the same as CheckedLittleEndianBuffer.ReadBlob8.
+func (m *MappedFile) ReadBlob8(v []byte) int {
+	return m.buf.ReadBlob8(v)
+}
+
+// ReadBlob16 is the same as CheckedLittleEndianBuffer.ReadBlob16.
+func (m *MappedFile) ReadBlob16(v []byte) int {
+	return m.buf.ReadBlob16(v)
+}
+
+// ReadBlob24 is the same as CheckedLittleEndianBuffer.ReadBlob24.
+func (m *MappedFile) ReadBlob24(v []byte) int {
+	return m.buf.ReadBlob24(v)
+}
+
+// ReadBlob32 is the same as CheckedLittleEndianBuffer.ReadBlob32.
+func (m *MappedFile) ReadBlob32(v []byte) int {
+	return m.buf.ReadBlob32(v)
+}
+
+// ReadString8 is the same as CheckedLittleEndianBuffer.ReadString8.
+func (m *MappedFile) ReadString8(strBuffer []byte) string {
+	return m.buf.ReadString8(strBuffer)
+}
+
+// ReadString16 is the same as CheckedLittleEndianBuffer.ReadString16.
+func (m *MappedFile) ReadString16(strBuffer []byte) string {
+	return m.buf.ReadString16(strBuffer)
+}
+
+// ReadString24 is the same as CheckedLittleEndianBuffer.ReadString24.
+func (m *MappedFile) ReadString24(strBuffer []byte) string {
+	return m.buf.ReadString24(strBuffer)
+}
+
+// ReadString32 is the same as CheckedLittleEndianBuffer.ReadString32.
+func (m *MappedFile) ReadString32(strBuffer []byte) string {
+	return m.buf.ReadString32(strBuffer)
+}
+
+// ReadType is the same as CheckedLittleEndianBuffer.ReadType.
+func (m *MappedFile) ReadType() Type {
+	return m.buf.ReadType()
+}
+
+// DrainFast is the same as CheckedLittleEndianBuffer.DrainFast.
+func (m *MappedFile) DrainFast(t Type) int {
+	return m.buf.DrainFast(t)
+}
+
+// Drain is the same as CheckedLittleEndianBuffer.Drain.
+func (m *MappedFile) Drain(t Type) int {
+	return m.buf.Drain(t)
+}
@@ -0,0 +1,110 @@
+//go:build linux
+// +build linux
+
+package ioutil
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestMappedFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "mapped")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(file.Name())
+
+	dout := NewDataOutput(LittleEndian, file)
+	dout.WriteUint32(0xCAFEBABE)
+	dout.WriteUTF8(I8, "hello")
+	dout.WriteUint40(MaxUint40)
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mapped, err := OpenMappedFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer mapped.Close()
+
+	if mapped.Len() != 15 {
+		t.Fatalf("expected 15 but got %d", mapped.Len())
+	}
+
+	if v := mapped.ReadUint32(); v != 0xCAFEBABE {
+		t.Fatalf("expected %x but got %x", 0xCAFEBABE, v)
+	}
+
+	if s := mapped.ReadString8(make([]byte, 255)); s != "hello" {
+		t.Fatalf("expected hello but got %q", s)
+	}
+
+	if v := mapped.ReadUint40(); v != MaxUint40 || mapped.Error() != nil {
+		t.Fatalf("unexpected %x %v", v, mapped.Error())
+	}
+
+	// reading beyond the mapping reports an error instead of faulting
+	mapped.ReadUint8()
+
+	var overrun BufferOverrun
+	if !errors.As(mapped.Error(), &overrun) {
+		t.Fatalf("expected a buffer overrun but got %v", mapped.Error())
+	}
+
+	din := mapped.DataInput(LittleEndian)
+	din.ReadUint32()
+
+	if s := din.ReadUTF8(I8); s != "hello" {
+		t.Fatalf("expected hello but got %q", s)
+	}
+
+	buf := make([]byte, 10)
+	if n, err := mapped.ReadAt(buf, 10); n != 5 || err != io.EOF {
+		t.Fatalf("unexpected %d %v", n, err)
+	}
+
+	mapped.Reset()
+	mapped.SetPos(4)
+
+	if s := mapped.ReadString8(make([]byte, 255)); s != "hello" || mapped.Pos() != 10 {
+		t.Fatalf("expected hello but got %q at %d", s, mapped.Pos())
+	}
+
+	if err := mapped.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mapped.Reset()
+	mapped.ReadUint8()
+
+	if mapped.Error() == nil {
+		t.Fatal("expected an error after close")
+	}
+}
+
+func TestMappedFile_Empty(t *testing.T) {
+	file, err := ioutil.TempFile("", "mapped")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.Remove(file.Name())
+	_ = file.Close()
+
+	mapped, err := OpenMappedFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mapped.Len() != 0 || mapped.Close() != nil {
+		t.Fatal("expected an empty mapping")
+	}
+}
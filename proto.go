@@ -0,0 +1,448 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+)
+
+// WireType is the type of an encoded protocol buffers field, which tells how to read or skip its value.
+type WireType uint8
+
+const (
+	// WireVarint is used for int32, int64, uint32, uint64, sint32, sint64, bool and enum.
+	WireVarint WireType = 0
+
+	// WireFixed64 is used for fixed64, sfixed64 and double.
+	WireFixed64 WireType = 1
+
+	// WireBytes is used for string, bytes, embedded messages and packed repeated fields.
+	WireBytes WireType = 2
+
+	// WireStartGroup starts a deprecated group.
+	WireStartGroup WireType = 3
+
+	// WireEndGroup ends a deprecated group.
+	WireEndGroup WireType = 4
+
+	// WireFixed32 is used for fixed32, sfixed32 and float.
+	WireFixed32 WireType = 5
+)
+
+// MaxProtoField is the largest valid field number.
+const MaxProtoField = 1<<29 - 1
+
+// maxProtoGroupDepth limits the nesting of groups, which are skipped recursively.
+const maxProtoGroupDepth = 100
+
+// String returns the name of the wire type.
+func (w WireType) String() string {
+	switch w {
+	case WireVarint:
+		return "varint"
+	case WireFixed64:
+		return "fixed64"
+	case WireBytes:
+		return "bytes"
+	case WireStartGroup:
+		return "start group"
+	case WireEndGroup:
+		return "end group"
+	case WireFixed32:
+		return "fixed32"
+	default:
+		return "unknown wire type " + strconv.Itoa(int(w))
+	}
+}
+
+// A ProtoWireWriter writes the protocol buffers wire format using an Encoder, without any generated code. Each
+// Write method writes the tag of the given field followed by the value. As soon as any error occurred, any call
+// is a no-op and will result in the same error state.
+type ProtoWireWriter struct {
+	encoder *Encoder
+}
+
+// NewProtoWireWriter creates a new ProtoWireWriter, which writes into w.
+func NewProtoWireWriter(w io.Writer) *ProtoWireWriter {
+	return &ProtoWireWriter{encoder: NewEncoder(w, true)}
+}
+
+// WriteTag writes the key of a field, consisting of the field number and the wire type.
+func (w *ProtoWireWriter) WriteTag(field int, typ WireType) {
+	if field < 1 || field > MaxProtoField {
+		w.encoder.noteErr("WriteTag", nil, w.encoder.pos, fmt.Errorf("invalid field number %d", field))
+		return
+	}
+
+	w.encoder.writeUvarint("WriteTag", uint64(field)<<3|uint64(typ))
+}
+
+// WriteUint64 writes an uint64 or uint32 as varint.
+func (w *ProtoWireWriter) WriteUint64(field int, v uint64) {
+	w.WriteTag(field, WireVarint)
+	w.encoder.writeUvarint("WriteUint64", v)
+}
+
+// WriteInt64 writes an int64, int32 or enum as varint. Negative values always take 10 bytes.
+func (w *ProtoWireWriter) WriteInt64(field int, v int64) {
+	w.WriteTag(field, WireVarint)
+	w.encoder.writeUvarint("WriteInt64", uint64(v))
+}
+
+// WriteSint64 writes a sint64 or sint32 as zig-zag encoded varint.
+func (w *ProtoWireWriter) WriteSint64(field int, v int64) {
+	w.WriteTag(field, WireVarint)
+	w.encoder.writeUvarint("WriteSint64", uint64(v<<1)^uint64(v>>63))
+}
+
+// WriteBool writes a bool as varint.
+func (w *ProtoWireWriter) WriteBool(field int, v bool) {
+	var b uint64
+	if v {
+		b = 1
+	}
+
+	w.WriteTag(field, WireVarint)
+	w.encoder.writeUvarint("WriteBool", b)
+}
+
+// WriteFixed32 writes a fixed32 or the bits of a sfixed32.
+func (w *ProtoWireWriter) WriteFixed32(field int, v uint32) {
+	w.WriteTag(field, WireFixed32)
+	w.encoder.writeUint32("WriteFixed32", LittleEndian, v)
+}
+
+// WriteFixed64 writes a fixed64 or the bits of a sfixed64.
+func (w *ProtoWireWriter) WriteFixed64(field int, v uint64) {
+	w.WriteTag(field, WireFixed64)
+	w.encoder.writeUint64("WriteFixed64", LittleEndian, v)
+}
+
+// WriteFloat writes a float as fixed32.
+func (w *ProtoWireWriter) WriteFloat(field int, v float32) {
+	w.WriteFixed32(field, math.Float32bits(v))
+}
+
+// WriteDouble writes a double as fixed64.
+func (w *ProtoWireWriter) WriteDouble(field int, v float64) {
+	w.WriteFixed64(field, math.Float64bits(v))
+}
+
+// WriteBytes writes a length-delimited field, e.g. bytes, an encoded message or a packed repeated field.
+func (w *ProtoWireWriter) WriteBytes(field int, v []byte) {
+	w.WriteTag(field, WireBytes)
+	w.encoder.writeBlob("WriteBytes", nil, IVar, v)
+}
+
+// WriteString writes a length-delimited string.
+func (w *ProtoWireWriter) WriteString(field int, v string) {
+	w.WriteTag(field, WireBytes)
+	w.encoder.writeUvarint("WriteString", uint64(len(v)))
+	w.encoder.write("WriteString", nil, []byte(v))
+}
+
+// WriteMessage writes an embedded message, which is encoded by fn into a temporary buffer, because the
+// length must be known upfront.
+func (w *ProtoWireWriter) WriteMessage(field int, fn func(w *ProtoWireWriter)) {
+	if w.encoder.quickFail() {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	nested := NewProtoWireWriter(buf)
+	fn(nested)
+
+	if err := nested.Error(); err != nil {
+		w.encoder.noteErr("WriteMessage", nil, w.encoder.pos, err)
+		return
+	}
+
+	w.WriteBytes(field, buf.Bytes())
+}
+
+// WriteRaw writes the tag and the raw value, which has been returned by ProtoWireReader.ReadRaw. This allows
+// to copy unknown or unchanged fields, when patching a message.
+func (w *ProtoWireWriter) WriteRaw(field int, typ WireType, raw []byte) {
+	w.WriteTag(field, typ)
+	w.encoder.write("WriteRaw", nil, raw)
+}
+
+// Position returns the amount of bytes, which have been written so far.
+func (w *ProtoWireWriter) Position() int64 {
+	return w.encoder.Position()
+}
+
+// Error returns the first occurred error.
+func (w *ProtoWireWriter) Error() error {
+	return w.encoder.Error()
+}
+
+// A ProtoWireReader reads the protocol buffers wire format using a Decoder, without any generated code. Next
+// returns the field number and wire type of each field, which must be followed by exactly one Read or Skip
+// call, which matches the wire type. As soon as any error occurred, any call is a no-op and will result in the
+// same error state.
+type ProtoWireReader struct {
+	decoder *Decoder
+}
+
+// NewProtoWireReader creates a new ProtoWireReader, which reads from r.
+func NewProtoWireReader(r io.Reader) *ProtoWireReader {
+	return &ProtoWireReader{decoder: NewDecoder(r, true)}
+}
+
+// SetLimits configures the limits of the underlying Decoder, to protect against hostile length prefixes.
+func (r *ProtoWireReader) SetLimits(limits Limits) {
+	r.decoder.SetLimits(limits)
+}
+
+// Next reads the tag of the next field. It returns io.EOF, if the message ends exactly before a tag. An end group
+// tag is returned like any other field.
+func (r *ProtoWireReader) Next() (int, WireType, error) {
+	d := r.decoder
+	if d.quickFail() {
+		return 0, 0, d.Error()
+	}
+
+	offset := d.pos
+	tag := d.readUvarint("ReadTag")
+
+	if d.Error() != nil {
+		if d.pos == offset && errors.Is(d.Error(), io.EOF) {
+			d.firstErr = io.EOF
+		}
+
+		return 0, 0, d.Error()
+	}
+
+	field, typ := tag>>3, WireType(tag&7)
+	if field < 1 || field > MaxProtoField || typ > WireFixed32 {
+		d.noteErr("ReadTag", nil, offset, fmt.Errorf("invalid tag %x", tag))
+		return 0, 0, d.Error()
+	}
+
+	return int(field), typ, nil
+}
+
+// ReadUint64 reads an uint64 or uint32 varint.
+func (r *ProtoWireReader) ReadUint64() uint64 {
+	return r.readUvarint("ReadUint64")
+}
+
+// ReadInt64 reads an int64 varint.
+func (r *ProtoWireReader) ReadInt64() int64 {
+	return int64(r.readUvarint("ReadInt64"))
+}
+
+// ReadInt32 reads an int32 or enum varint.
+func (r *ProtoWireReader) ReadInt32() int32 {
+	return int32(r.readUvarint("ReadInt32"))
+}
+
+// ReadSint64 reads a zig-zag encoded sint64 varint.
+func (r *ProtoWireReader) ReadSint64() int64 {
+	v := r.readUvarint("ReadSint64")
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// ReadSint32 reads a zig-zag encoded sint32 varint.
+func (r *ProtoWireReader) ReadSint32() int32 {
+	v := uint32(r.readUvarint("ReadSint32"))
+	return int32(v>>1) ^ -int32(v&1)
+}
+
+// ReadBool reads a bool varint.
+func (r *ProtoWireReader) ReadBool() bool {
+	return r.readUvarint("ReadBool") != 0
+}
+
+// ReadFixed32 reads a fixed32. Convert it to int32 for a sfixed32.
+func (r *ProtoWireReader) ReadFixed32() uint32 {
+	return r.readUint32("ReadFixed32")
+}
+
+// ReadFixed64 reads a fixed64. Convert it to int64 for a sfixed64.
+func (r *ProtoWireReader) ReadFixed64() uint64 {
+	return r.readUint64("ReadFixed64")
+}
+
+// ReadFloat reads a float.
+func (r *ProtoWireReader) ReadFloat() float32 {
+	return math.Float32frombits(r.ReadFixed32())
+}
+
+// ReadDouble reads a double.
+func (r *ProtoWireReader) ReadDouble() float64 {
+	return math.Float64frombits(r.ReadFixed64())
+}
+
+// ReadBytes reads a length-delimited field into a newly allocated slice.
+func (r *ProtoWireReader) ReadBytes() []byte {
+	return r.readBlob("ReadBytes")
+}
+
+// ReadString reads a length-delimited string.
+func (r *ProtoWireReader) ReadString() string {
+	return string(r.readBlob("ReadString"))
+}
+
+// ReadMessage reads a length-delimited embedded message and returns a reader for its fields. The limits are
+// inherited.
+func (r *ProtoWireReader) ReadMessage() *ProtoWireReader {
+	msg := r.ReadBytes()
+	nested := NewProtoWireReader(bytes.NewReader(msg))
+	nested.SetLimits(r.decoder.limits)
+
+	if r.decoder.Error() != nil {
+		nested.decoder.firstErr = r.decoder.Error()
+	}
+
+	return nested
+}
+
+// ReadRaw reads the value of the given wire type without interpreting it. For a group, the raw value contains
+// all nested fields and the end group tag. Together with ProtoWireWriter.WriteRaw, unknown or unchanged
+// fields can be copied, when patching a message. Varints are copied in their canonical form.
+func (r *ProtoWireReader) ReadRaw(field int, typ WireType) []byte {
+	buf := &bytes.Buffer{}
+	r.copyValue(NewEncoder(buf, true), field, typ, 0)
+
+	if r.decoder.Error() != nil {
+		return nil
+	}
+
+	return buf.Bytes()
+}
+
+// Skip discards the value of the given wire type, e.g. of an unknown field. A group is skipped including all
+// nested fields and its end group tag.
+func (r *ProtoWireReader) Skip(field int, typ WireType) {
+	r.copyValue(NewEncoder(ioutil.Discard, true), field, typ, 0)
+}
+
+// copyValue reads a value and writes it unchanged into e.
+func (r *ProtoWireReader) copyValue(e *Encoder, field int, typ WireType, depth int) {
+	d := r.decoder
+	if d.quickFail() {
+		return
+	}
+
+	offset := d.pos
+
+	switch typ {
+	case WireVarint:
+		e.writeUvarint("ReadRaw", r.readUvarint("ReadRaw"))
+	case WireFixed64:
+		e.writeUint64("ReadRaw", LittleEndian, r.readUint64("ReadRaw"))
+	case WireFixed32:
+		e.writeUint32("ReadRaw", LittleEndian, r.readUint32("ReadRaw"))
+	case WireBytes:
+		n, ok := d.readSize("ReadRaw", nil, IVar)
+		r.decoder.unexpectedEOF()
+
+		if !ok {
+			return
+		}
+
+		e.writeUvarint("ReadRaw", uint64(n))
+
+		// copy instead of allocating, so that a hostile length cannot allocate more than the actual stream
+		offset := d.pos
+		m, err := io.CopyN(e, d.in, int64(n))
+		d.pos += m
+
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		d.noteErr("ReadRaw", nil, offset, err)
+	case WireStartGroup:
+		if depth >= maxProtoGroupDepth {
+			d.noteErr("ReadRaw", nil, offset, fmt.Errorf("groups nested deeper than %d", maxProtoGroupDepth))
+			return
+		}
+
+		for d.Error() == nil {
+			nestedField, nestedType, err := r.Next()
+			if err == io.EOF {
+				d.firstErr = nil
+				d.noteErr("ReadRaw", nil, offset, io.ErrUnexpectedEOF)
+			}
+
+			if d.Error() != nil {
+				return
+			}
+
+			e.writeUvarint("ReadRaw", uint64(nestedField)<<3|uint64(nestedType))
+
+			if nestedType == WireEndGroup {
+				if nestedField != field {
+					d.noteErr("ReadRaw", nil, offset, fmt.Errorf("group %d ended by %d", field, nestedField))
+				}
+
+				return
+			}
+
+			r.copyValue(e, nestedField, nestedType, depth+1)
+		}
+	default:
+		d.noteErr("ReadRaw", nil, offset, fmt.Errorf("unexpected %s", typ))
+	}
+}
+
+func (r *ProtoWireReader) readUvarint(op string) uint64 {
+	v := r.decoder.readUvarint(op)
+	r.decoder.unexpectedEOF()
+
+	return v
+}
+
+func (r *ProtoWireReader) readUint32(op string) uint32 {
+	v := r.decoder.readUint32(op, LittleEndian)
+	r.decoder.unexpectedEOF()
+
+	return v
+}
+
+func (r *ProtoWireReader) readUint64(op string) uint64 {
+	v := r.decoder.readUint64(op, LittleEndian)
+	r.decoder.unexpectedEOF()
+
+	return v
+}
+
+func (r *ProtoWireReader) readBlob(op string) []byte {
+	v := r.decoder.readBlob(op, nil, IVar, 0)
+	r.decoder.unexpectedEOF()
+
+	return v
+}
+
+// Position returns the amount of bytes, which have been read so far.
+func (r *ProtoWireReader) Position() int64 {
+	return r.decoder.Position()
+}
+
+// Error returns the first occurred error.
+func (r *ProtoWireReader) Error() error {
+	return r.decoder.Error()
+}
@@ -0,0 +1,318 @@
+package ioutil
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestProtoWireWriter_Spec(t *testing.T) {
+	tests := []struct {
+		write    func(w *ProtoWireWriter)
+		expected []byte
+	}{
+		{func(w *ProtoWireWriter) { w.WriteUint64(1, 150) }, []byte{0x08, 0x96, 0x01}},
+		{func(w *ProtoWireWriter) { w.WriteString(2, "testing") }, append([]byte{0x12, 0x07}, "testing"...)},
+		{func(w *ProtoWireWriter) {
+			w.WriteMessage(3, func(w *ProtoWireWriter) { w.WriteUint64(1, 150) })
+		}, []byte{0x1a, 0x03, 0x08, 0x96, 0x01}},
+		{func(w *ProtoWireWriter) { w.WriteSint64(1, -1) }, []byte{0x08, 0x01}},
+		{func(w *ProtoWireWriter) { w.WriteSint64(1, 1) }, []byte{0x08, 0x02}},
+		{func(w *ProtoWireWriter) { w.WriteSint64(1, -2) }, []byte{0x08, 0x03}},
+		{func(w *ProtoWireWriter) { w.WriteInt64(1, -1) },
+			[]byte{0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{func(w *ProtoWireWriter) { w.WriteFixed32(1, 1) }, []byte{0x0d, 0x01, 0x00, 0x00, 0x00}},
+		{func(w *ProtoWireWriter) { w.WriteFixed64(16, 1) },
+			[]byte{0x81, 0x01, 0x01, 0, 0, 0, 0, 0, 0, 0}},
+	}
+
+	for i, tt := range tests {
+		buf := &bytes.Buffer{}
+		w := NewProtoWireWriter(buf)
+		tt.write(w)
+
+		if w.Error() != nil {
+			t.Fatal(i, w.Error())
+		}
+
+		if !bytes.Equal(buf.Bytes(), tt.expected) {
+			t.Fatalf("%d: expected %x but got %x", i, tt.expected, buf.Bytes())
+		}
+	}
+
+	w := NewProtoWireWriter(&bytes.Buffer{})
+	w.WriteTag(MaxProtoField+1, WireVarint)
+
+	if w.Error() == nil {
+		t.Fatal("expected invalid field number")
+	}
+}
+
+func TestProtoWireReader(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewProtoWireWriter(buf)
+	w.WriteInt64(1, -5)
+	w.WriteInt64(2, math.MinInt32)
+	w.WriteSint64(3, math.MinInt32)
+	w.WriteSint64(4, math.MinInt64)
+	w.WriteUint64(5, math.MaxUint64)
+	w.WriteBool(6, true)
+	w.WriteFixed32(7, uint32(0xfffffffe))
+	w.WriteFixed64(8, uint64(math.MaxUint64-1))
+	w.WriteFloat(9, 1.5)
+	w.WriteDouble(10, -2.25)
+	w.WriteBytes(11, []byte{1, 2, 3})
+	w.WriteMessage(12, func(w *ProtoWireWriter) {
+		w.WriteString(1, "nested")
+		w.WriteSint64(2, -7)
+	})
+
+	if w.Error() != nil {
+		t.Fatal(w.Error())
+	}
+
+	r := NewProtoWireReader(buf)
+	next := func(field int, typ WireType) {
+		t.Helper()
+
+		f, wt, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if f != field || wt != typ {
+			t.Fatalf("expected %d/%v but got %d/%v", field, typ, f, wt)
+		}
+	}
+
+	next(1, WireVarint)
+
+	if v := r.ReadInt64(); v != -5 {
+		t.Fatal(v)
+	}
+
+	next(2, WireVarint)
+
+	if v := r.ReadInt32(); v != math.MinInt32 {
+		t.Fatal(v)
+	}
+
+	next(3, WireVarint)
+
+	if v := r.ReadSint32(); v != math.MinInt32 {
+		t.Fatal(v)
+	}
+
+	next(4, WireVarint)
+
+	if v := r.ReadSint64(); v != math.MinInt64 {
+		t.Fatal(v)
+	}
+
+	next(5, WireVarint)
+
+	if v := r.ReadUint64(); v != math.MaxUint64 {
+		t.Fatal(v)
+	}
+
+	next(6, WireVarint)
+
+	if v := r.ReadBool(); !v {
+		t.Fatal(v)
+	}
+
+	next(7, WireFixed32)
+
+	if v := int32(r.ReadFixed32()); v != -2 {
+		t.Fatal(v)
+	}
+
+	next(8, WireFixed64)
+
+	if v := int64(r.ReadFixed64()); v != -2 {
+		t.Fatal(v)
+	}
+
+	next(9, WireFixed32)
+
+	if v := r.ReadFloat(); v != 1.5 {
+		t.Fatal(v)
+	}
+
+	next(10, WireFixed64)
+
+	if v := r.ReadDouble(); v != -2.25 {
+		t.Fatal(v)
+	}
+
+	next(11, WireBytes)
+
+	if v := r.ReadBytes(); !bytes.Equal(v, []byte{1, 2, 3}) {
+		t.Fatal(v)
+	}
+
+	next(12, WireBytes)
+
+	msg := r.ReadMessage()
+
+	if f, _, _ := msg.Next(); f != 1 || msg.ReadString() != "nested" {
+		t.Fatal("unexpected nested field", f)
+	}
+
+	if f, _, _ := msg.Next(); f != 2 || msg.ReadSint64() != -7 {
+		t.Fatal("unexpected nested field", f)
+	}
+
+	if _, _, err := msg.Next(); err != io.EOF {
+		t.Fatalf("expected EOF but got %v", err)
+	}
+
+	if _, _, err := r.Next(); err != io.EOF {
+		t.Fatalf("expected EOF but got %v", err)
+	}
+}
+
+func TestProtoWireReader_Skip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewProtoWireWriter(buf)
+	w.WriteUint64(100, 1)
+	w.WriteFixed32(101, 2)
+	w.WriteFixed64(102, 3)
+	w.WriteBytes(103, []byte("unknown"))
+	w.WriteTag(104, WireStartGroup)
+	w.WriteUint64(1, 4)
+	w.WriteTag(2, WireStartGroup)
+	w.WriteString(1, "deep")
+	w.WriteTag(2, WireEndGroup)
+	w.WriteTag(104, WireEndGroup)
+	w.WriteString(1, "known")
+
+	r := NewProtoWireReader(bytes.NewReader(buf.Bytes()))
+
+	for {
+		field, typ, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if field == 1 {
+			if v := r.ReadString(); v != "known" {
+				t.Fatal(v)
+			}
+
+			break
+		}
+
+		r.Skip(field, typ)
+	}
+
+	if _, _, err := r.Next(); err != io.EOF {
+		t.Fatalf("expected EOF but got %v", err)
+	}
+
+	// a mismatching end group is malformed
+	buf.Reset()
+	w = NewProtoWireWriter(buf)
+	w.WriteTag(5, WireStartGroup)
+	w.WriteTag(6, WireEndGroup)
+
+	r = NewProtoWireReader(buf)
+	field, typ, _ := r.Next()
+	r.Skip(field, typ)
+
+	if r.Error() == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestProtoWireReader_Malformed(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want error
+	}{
+		{[]byte{0x08}, io.ErrUnexpectedEOF},
+		{[]byte{0x08, 0x96}, io.ErrUnexpectedEOF},
+		{[]byte{0x12, 0x07, 't'}, io.ErrUnexpectedEOF},
+		{[]byte{0x1b}, io.ErrUnexpectedEOF},
+		{[]byte{0x0d, 0x01}, io.ErrUnexpectedEOF},
+		{bytes.Repeat([]byte{0x1b}, maxProtoGroupDepth+1), nil},
+		{[]byte{0x0e}, nil},
+		{[]byte{0x00}, nil},
+	}
+
+	for i, tt := range tests {
+		r := NewProtoWireReader(bytes.NewReader(tt.data))
+		r.SetLimits(Limits{MaxBlobSize: 1024})
+
+		for r.Error() == nil {
+			field, typ, err := r.Next()
+			if err != nil {
+				break
+			}
+
+			r.Skip(field, typ)
+		}
+
+		var decErr *DecodeError
+		if !errors.As(r.Error(), &decErr) {
+			t.Fatalf("%d: expected a DecodeError but got %v", i, r.Error())
+		}
+
+		if tt.want != nil && !errors.Is(r.Error(), tt.want) {
+			t.Fatalf("%d: expected %v but got %v", i, tt.want, r.Error())
+		}
+	}
+}
+
+func TestProtoWire_Patch(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := NewProtoWireWriter(buf)
+	w.WriteString(1, "name")
+	w.WriteUint64(2, 41)
+	w.WriteTag(3, WireStartGroup)
+	w.WriteFixed32(1, 7)
+	w.WriteTag(3, WireEndGroup)
+	w.WriteBytes(99, []byte("opaque"))
+
+	// increment field 2 and copy everything else unchanged
+	patched := &bytes.Buffer{}
+	pw := NewProtoWireWriter(patched)
+	r := NewProtoWireReader(bytes.NewReader(buf.Bytes()))
+
+	for {
+		field, typ, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if field == 2 {
+			pw.WriteUint64(2, r.ReadUint64()+1)
+			continue
+		}
+
+		pw.WriteRaw(field, typ, r.ReadRaw(field, typ))
+	}
+
+	if pw.Error() != nil {
+		t.Fatal(pw.Error())
+	}
+
+	expected := &bytes.Buffer{}
+	w = NewProtoWireWriter(expected)
+	w.WriteString(1, "name")
+	w.WriteUint64(2, 42)
+	w.WriteTag(3, WireStartGroup)
+	w.WriteFixed32(1, 7)
+	w.WriteTag(3, WireEndGroup)
+	w.WriteBytes(99, []byte("opaque"))
+
+	if !bytes.Equal(patched.Bytes(), expected.Bytes()) {
+		t.Fatalf("expected %x but got %x", expected.Bytes(), patched.Bytes())
+	}
+}
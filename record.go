@@ -0,0 +1,275 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// RecordFormat configures the framing of a record stream. Each record is written as a length prefix, the payload
+// and an optional checksum trailer.
+type RecordFormat struct {
+	// Order is the byte order of the length prefix and the checksum. Nil means LittleEndian.
+	Order ByteOrder
+
+	// Prefix is the storage class of the length prefix, e.g. I32 or IVar.
+	Prefix IntSize
+
+	// Checksum appends a CRC32C (Castagnoli) of the payload as an uint32 to each record.
+	Checksum bool
+
+	// MaxSize is the maximum length of a single record. Larger records are rejected by the reader and the
+	// writer. Zero means unlimited, so that only the Limits of the RecordReader protect against a corrupted
+	// length prefix, which allocates a lot of memory.
+	MaxSize int
+}
+
+func (f RecordFormat) order() ByteOrder {
+	if f.Order == nil {
+		return LittleEndian
+	}
+
+	return f.Order
+}
+
+// A RecordWriter writes length-delimited records using an Encoder. As soon as any error occurred, any call
+// is a no-op and will result in the same error state.
+type RecordWriter struct {
+	encoder *Encoder
+	format  RecordFormat
+}
+
+// NewRecordWriter creates a new RecordWriter, which writes into w.
+func NewRecordWriter(w io.Writer, format RecordFormat) *RecordWriter {
+	return &RecordWriter{encoder: NewEncoder(w, true), format: format}
+}
+
+// WriteRecord writes a single record and returns the first occurred error.
+func (w *RecordWriter) WriteRecord(p []byte) error {
+	e := w.encoder
+	if e.quickFail() {
+		return e.Error()
+	}
+
+	order := w.format.order()
+
+	if w.format.MaxSize > 0 && len(p) > w.format.MaxSize {
+		e.noteErr("WriteRecord", order, e.pos, BlobTooLarge{Len: len(p), Max: w.format.MaxSize})
+		return e.Error()
+	}
+
+	e.writeBlob("WriteRecord", order, w.format.Prefix, p)
+
+	if w.format.Checksum {
+		e.writeUint32("WriteRecord", order, crc32.Checksum(p, castagnoli))
+	}
+
+	return e.Error()
+}
+
+// Offset returns the position of the next record in the stream.
+func (w *RecordWriter) Offset() int64 {
+	return w.encoder.Position()
+}
+
+// Error returns the first occurred error.
+func (w *RecordWriter) Error() error {
+	return w.encoder.Error()
+}
+
+// A RecordReader reads length-delimited records, which have been written by a RecordWriter with the same
+// RecordFormat, using a Decoder. Any error, except the io.EOF at the end of the stream, is a *DecodeError,
+// which tells the offset of the failed record. A corrupted record is reported as a ChecksumMismatch. As soon as
+// any error occurred, any call is a no-op and will result in the same error state.
+type RecordReader struct {
+	decoder *Decoder
+	format  RecordFormat
+	current *recordPayload // current is the unread remainder of the last record returned by NextReader
+}
+
+// NewRecordReader creates a new RecordReader, which reads from r.
+func NewRecordReader(r io.Reader, format RecordFormat) *RecordReader {
+	return &RecordReader{decoder: NewDecoder(r, true), format: format}
+}
+
+// SetLimits configures the limits of the underlying Decoder, which are applied to the records allocated by Next.
+func (r *RecordReader) SetLimits(limits Limits) {
+	r.decoder.SetLimits(limits)
+}
+
+// Next reads the next record into a newly allocated slice. It returns io.EOF, if the stream ends exactly at
+// a record boundary.
+func (r *RecordReader) Next() ([]byte, error) {
+	n, offset, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+
+	d := r.decoder
+	order := r.format.order()
+
+	if !d.allocate("ReadRecord", order, offset, n, 0) {
+		return nil, d.Error()
+	}
+
+	buf := make([]byte, n)
+	d.readFull("ReadRecord", order, buf)
+	d.unexpectedEOF()
+
+	if d.Error() != nil {
+		return nil, d.Error()
+	}
+
+	if r.format.Checksum {
+		r.verify(offset, crc32.Checksum(buf, castagnoli))
+	}
+
+	return buf, d.Error()
+}
+
+// NextReader returns a reader for the payload of the next record, without allocating it. The reader is only
+// valid until the next call to Next or NextReader, which skips any unread bytes. If the record has a checksum,
+// the reader returns the ChecksumMismatch instead of io.EOF at its end.
+func (r *RecordReader) NextReader() (io.Reader, error) {
+	n, offset, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+
+	r.current = &recordPayload{reader: r, offset: offset, remaining: int64(n), hasher: crc32.New(castagnoli)}
+
+	return r.current, nil
+}
+
+// next skips the remainder of the current record and reads the length prefix of the next record.
+func (r *RecordReader) next() (int, int64, error) {
+	d := r.decoder
+
+	if r.current != nil {
+		_, _ = io.Copy(ioutil.Discard, r.current)
+		r.current = nil
+	}
+
+	if d.quickFail() {
+		return 0, 0, d.Error()
+	}
+
+	offset := d.Position()
+
+	n, ok := d.readSize("ReadRecord", r.format.order(), r.format.Prefix)
+	if !ok {
+		// an io.EOF at a record boundary is the regular end of the stream
+		if d.Position() == offset && errors.Is(d.Error(), io.EOF) {
+			d.firstErr = io.EOF
+			return 0, offset, io.EOF
+		}
+
+		return 0, offset, d.Error()
+	}
+
+	if r.format.MaxSize > 0 && n > r.format.MaxSize {
+		d.noteErr("ReadRecord", r.format.order(), offset, BlobTooLarge{Len: n, Max: r.format.MaxSize})
+		return 0, offset, d.Error()
+	}
+
+	return n, offset, nil
+}
+
+// verify reads the checksum trailer and compares it with the calculated checksum.
+func (r *RecordReader) verify(offset int64, actual uint32) {
+	order := r.format.order()
+
+	expected := r.decoder.readUint32("ReadRecord", order)
+	r.decoder.unexpectedEOF()
+
+	if r.decoder.Error() == nil && expected != actual {
+		err := ChecksumMismatch{Expected: crcBytes(expected), Actual: crcBytes(actual)}
+		r.decoder.noteErr("ReadRecord", order, offset, err)
+	}
+}
+
+// crcBytes returns the checksum in big endian, just like hash.Hash32.Sum.
+func crcBytes(v uint32) []byte {
+	return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+// Offset returns the position in the stream, which is the start of the next record, if the current record has
+// been read completely.
+func (r *RecordReader) Offset() int64 {
+	return r.decoder.Position()
+}
+
+// Error returns the first occurred error.
+func (r *RecordReader) Error() error {
+	return r.decoder.Error()
+}
+
+// recordPayload limits the reader to the payload of a single record and verifies its checksum at the end.
+type recordPayload struct {
+	reader    *RecordReader
+	offset    int64
+	remaining int64
+	hasher    hash.Hash32
+	verified  bool
+}
+
+func (p *recordPayload) Read(buf []byte) (int, error) {
+	d := p.reader.decoder
+
+	if d.quickFail() {
+		return 0, d.Error()
+	}
+
+	if p.remaining == 0 {
+		if p.reader.format.Checksum && !p.verified {
+			p.verified = true
+			p.reader.verify(p.offset, p.hasher.Sum32())
+		}
+
+		if d.Error() != nil {
+			return 0, d.Error()
+		}
+
+		return 0, io.EOF
+	}
+
+	if int64(len(buf)) > p.remaining {
+		buf = buf[:p.remaining]
+	}
+
+	n, err := d.in.Read(buf)
+	d.pos += int64(n)
+	p.remaining -= int64(n)
+	_, _ = p.hasher.Write(buf[:n])
+
+	if err == io.EOF && p.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+
+	if err != nil && err != io.EOF {
+		d.noteErr("ReadRecord", p.reader.format.order(), d.pos, err)
+		return n, d.Error()
+	}
+
+	return n, nil
+}
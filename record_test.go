@@ -0,0 +1,198 @@
+package ioutil
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+)
+
+func writeRecords(t *testing.T, format RecordFormat, records ...[]byte) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	w := NewRecordWriter(buf, format)
+
+	for _, rec := range records {
+		if err := w.WriteRecord(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if w.Offset() != int64(buf.Len()) {
+		t.Fatalf("expected offset %d but got %d", buf.Len(), w.Offset())
+	}
+
+	return buf.Bytes()
+}
+
+func TestRecordReader(t *testing.T) {
+	records := [][]byte{[]byte("hello"), {}, bytes.Repeat([]byte{1, 2, 3}, 100)}
+
+	for _, prefix := range []IntSize{I16, I24, I32, I40, I64, IVar} {
+		for _, checksum := range []bool{false, true} {
+			format := RecordFormat{Order: BigEndian, Prefix: prefix, Checksum: checksum}
+			data := writeRecords(t, format, records...)
+
+			r := NewRecordReader(iotest.HalfReader(bytes.NewReader(data)), format)
+			for i, expected := range records {
+				rec, err := r.Next()
+				if err != nil {
+					t.Fatalf("%d: %v", i, err)
+				}
+
+				if !bytes.Equal(rec, expected) {
+					t.Fatalf("%d: expected %x but got %x", i, expected, rec)
+				}
+			}
+
+			if _, err := r.Next(); err != io.EOF {
+				t.Fatalf("expected EOF but got %v", err)
+			}
+
+			// the streaming variant skips unread bytes
+			r = NewRecordReader(bytes.NewReader(data), format)
+			for i, expected := range records {
+				payload, err := r.NextReader()
+				if err != nil {
+					t.Fatalf("%d: %v", i, err)
+				}
+
+				if i == 1 {
+					continue
+				}
+
+				rec, err := ioutil.ReadAll(payload)
+				if err != nil {
+					t.Fatalf("%d: %v", i, err)
+				}
+
+				if !bytes.Equal(rec, expected) {
+					t.Fatalf("%d: expected %x but got %x", i, expected, rec)
+				}
+			}
+
+			if _, err := r.NextReader(); err != io.EOF {
+				t.Fatalf("expected EOF but got %v", err)
+			}
+		}
+	}
+}
+
+func TestRecordReader_Corrupted(t *testing.T) {
+	format := RecordFormat{Prefix: I32, Checksum: true, MaxSize: 100}
+	data := writeRecords(t, format, []byte("first"), []byte("second"))
+
+	corrupted := append([]byte(nil), data...)
+	corrupted[len(corrupted)-6] ^= 1
+
+	for _, next := range []func(r *RecordReader) error{
+		func(r *RecordReader) error {
+			_, err := r.Next()
+			return err
+		},
+		func(r *RecordReader) error {
+			payload, err := r.NextReader()
+			if err != nil {
+				return err
+			}
+
+			_, err = ioutil.ReadAll(payload)
+
+			return err
+		},
+	} {
+		r := NewRecordReader(bytes.NewReader(corrupted), format)
+		if err := next(r); err != nil {
+			t.Fatal(err)
+		}
+
+		err := next(r)
+
+		var decErr *DecodeError
+		if !errors.As(err, &decErr) || decErr.Offset != 4+5+4 {
+			t.Fatalf("expected an error at offset 13 but got %v", err)
+		}
+
+		var mismatch ChecksumMismatch
+		if !errors.As(err, &mismatch) {
+			t.Fatalf("expected a checksum mismatch but got %v", err)
+		}
+
+		if err2 := next(r); err2 != err {
+			t.Fatalf("expected the sticky error but got %v", err2)
+		}
+	}
+
+	// a truncated record is not the regular end of the stream, wherever it has been cut
+	for cut := 1; cut < len(data); cut++ {
+		for _, next := range []func(r *RecordReader) error{
+			func(r *RecordReader) error {
+				_, err := r.Next()
+				return err
+			},
+			func(r *RecordReader) error {
+				payload, err := r.NextReader()
+				if err != nil {
+					return err
+				}
+
+				_, err = ioutil.ReadAll(payload)
+
+				return err
+			},
+		} {
+			r := NewRecordReader(bytes.NewReader(data[:cut]), format)
+
+			err := next(r)
+			if cut >= 4+5+4 && err == nil {
+				err = next(r)
+			}
+
+			if cut == 4+5+4 {
+				if err != io.EOF {
+					t.Fatalf("cut at %d: expected EOF but got %v", cut, err)
+				}
+
+				continue
+			}
+
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Fatalf("cut at %d: expected an unexpected EOF but got %v", cut, err)
+			}
+		}
+	}
+
+	// oversized frames are rejected before allocating
+	r := NewRecordReader(bytes.NewReader([]byte{0xFF, 0xFF, 0xFF, 0x7F}), format)
+
+	var tooLarge BlobTooLarge
+	if _, err := r.Next(); !errors.As(err, &tooLarge) {
+		t.Fatalf("expected a blob too large error but got %v", err)
+	}
+
+	// without a MaxSize, the limits of the reader apply
+	r = NewRecordReader(bytes.NewReader([]byte{0xFF, 0xFF, 0xFF, 0x7F}), RecordFormat{Prefix: I32})
+	r.SetLimits(Limits{MaxBlobSize: 1024})
+
+	if _, err := r.Next(); !errors.As(err, &tooLarge) || tooLarge.Max != 1024 {
+		t.Fatalf("expected a blob too large error but got %v", err)
+	}
+
+	r = NewRecordReader(bytes.NewReader(writeRecords(t, RecordFormat{Prefix: I8}, []byte("first"), []byte("second"))),
+		RecordFormat{Prefix: I8})
+	r.SetLimits(Limits{Budget: 8})
+	_, _ = r.Next()
+
+	var exceeded BudgetExceeded
+	if _, err := r.Next(); !errors.As(err, &exceeded) {
+		t.Fatalf("expected an exceeded budget but got %v", err)
+	}
+
+	w := NewRecordWriter(ioutil.Discard, format)
+	if err := w.WriteRecord(make([]byte, 101)); !errors.As(err, &tooLarge) {
+		t.Fatalf("expected a blob too large error but got %v", err)
+	}
+}
@@ -0,0 +1,455 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unsafe"
+)
+
+var errVarintOverflow = errors.New("varint overflows a 64-bit integer")
+
+var _ DataInput = (*SliceDecoder)(nil)
+
+// A SliceDecoder implements the DataInput directly on top of a byte slice and avoids the indirection and
+// the copies of an io.Reader. ReadBlob and ReadUTF8 return sub slices of the underlying slice, so the caller must
+// not modify the returned slices and the underlying slice must not be modified as long as any returned slice or
+// string is in use. As soon as any error occurred, any call is a no-op and will result in the same error state.
+// Any error is a *DecodeError. A SliceDecoder is not thread safe.
+type SliceDecoder struct {
+	buf      []byte
+	pos      int
+	order    ByteOrder
+	firstErr error
+}
+
+// NewSliceDecoder creates a new SliceDecoder for the given byte order, which reads from b.
+func NewSliceDecoder(order ByteOrder, b []byte) *SliceDecoder {
+	return &SliceDecoder{buf: b, order: order}
+}
+
+// Position returns the amount of bytes, which have been read so far.
+func (d *SliceDecoder) Position() int64 {
+	return int64(d.pos)
+}
+
+// Remaining returns the amount of unread bytes.
+func (d *SliceDecoder) Remaining() int {
+	return len(d.buf) - d.pos
+}
+
+// ReadBlob reads a prefixed byte slice and returns a sub slice without copying.
+func (d *SliceDecoder) ReadBlob(p IntSize) []byte {
+	return d.readBlob("ReadBlob", p)
+}
+
+// ReadUTF8 reads a prefixed unmodified utf8 string sequence without copying.
+func (d *SliceDecoder) ReadUTF8(p IntSize) string {
+	tmp := d.readBlob("ReadUTF8", p)
+	// the string shares the memory with the underlying slice, see also Decoder.ReadUTF8
+	return *(*string)(unsafe.Pointer(&tmp))
+}
+
+func (d *SliceDecoder) readBlob(op string, p IntSize) []byte {
+	if d.firstErr != nil {
+		return nil
+	}
+
+	var size uint64
+
+	switch p {
+	case I8:
+		size = uint64(d.readUint8(op))
+	case I16:
+		size = uint64(d.readUint16(op))
+	case I24:
+		size = uint64(d.readUint24(op))
+	case I32:
+		size = uint64(d.readUint32(op))
+	case I40:
+		size = d.readUint40(op)
+	case I64:
+		size = d.readUint64(op)
+	case IVar:
+		size = d.readUvarint(op)
+	default:
+		panic("invalid IntSize " + strconv.Itoa(int(p)))
+	}
+
+	if d.firstErr != nil {
+		return nil
+	}
+
+	if size > uint64(d.Remaining()) {
+		d.noteErr(op, d.pos, eofErr(d.Remaining()))
+		return nil
+	}
+
+	return d.next(op, int(size))
+}
+
+// ReadBool reads one byte and returns 0 if the byte is zero, otherwise true
+func (d *SliceDecoder) ReadBool() bool {
+	return d.readUint8("ReadBool") != 0
+}
+
+// ReadUint8 reads one byte
+func (d *SliceDecoder) ReadUint8() uint8 {
+	return d.readUint8("ReadUint8")
+}
+
+// ReadBytes just reads a bunch of bytes into a newly allocated buffer
+func (d *SliceDecoder) ReadBytes(len int) []byte {
+	tmp := d.next("ReadBytes", len)
+	if tmp == nil {
+		return nil
+	}
+
+	buf := make([]byte, len)
+	copy(buf, tmp)
+
+	return buf
+}
+
+// ReadUint16 reads 2 bytes and interprets them as unsigned
+func (d *SliceDecoder) ReadUint16() uint16 {
+	return d.readUint16("ReadUint16")
+}
+
+// ReadUint24 reads 3 bytes and interprets them as unsigned
+func (d *SliceDecoder) ReadUint24() uint32 {
+	return d.readUint24("ReadUint24")
+}
+
+// ReadUint32 reads 4 bytes and interprets them as unsigned
+func (d *SliceDecoder) ReadUint32() uint32 {
+	return d.readUint32("ReadUint32")
+}
+
+// ReadUint40 reads 5 bytes and interprets them as unsigned
+func (d *SliceDecoder) ReadUint40() uint64 {
+	return d.readUint40("ReadUint40")
+}
+
+// ReadUint48 reads 6 bytes and interprets them as unsigned
+func (d *SliceDecoder) ReadUint48() uint64 {
+	return d.readUint48("ReadUint48")
+}
+
+// ReadUint56 reads 7 bytes and interprets them as unsigned
+func (d *SliceDecoder) ReadUint56() uint64 {
+	return d.readUint56("ReadUint56")
+}
+
+// ReadUint64 reads 8 bytes and interprets them as unsigned
+func (d *SliceDecoder) ReadUint64() uint64 {
+	return d.readUint64("ReadUint64")
+}
+
+// ReadInt8 reads one byte
+func (d *SliceDecoder) ReadInt8() int8 {
+	return int8(d.readUint8("ReadInt8"))
+}
+
+// ReadInt16 reads 2 bytes and interprets them as signed
+func (d *SliceDecoder) ReadInt16() int16 {
+	return int16(d.readUint16("ReadInt16"))
+}
+
+// ReadInt24 reads 3 bytes and interprets them as signed
+func (d *SliceDecoder) ReadInt24() int32 {
+	return int32(d.readUint24("ReadInt24")<<8) >> 8
+}
+
+// ReadInt32 reads 4 bytes and interprets them as signed
+func (d *SliceDecoder) ReadInt32() int32 {
+	return int32(d.readUint32("ReadInt32"))
+}
+
+// ReadInt40 reads 5 bytes and interprets them as signed
+func (d *SliceDecoder) ReadInt40() int64 {
+	return int64(d.readUint40("ReadInt40")<<24) >> 24
+}
+
+// ReadInt48 reads 6 bytes and interprets them as signed
+func (d *SliceDecoder) ReadInt48() int64 {
+	return int64(d.readUint48("ReadInt48")<<16) >> 16
+}
+
+// ReadInt56 reads 7 bytes and interprets them as signed
+func (d *SliceDecoder) ReadInt56() int64 {
+	return int64(d.readUint56("ReadInt56")<<8) >> 8
+}
+
+// ReadInt64 reads 8 bytes and interprets them as signed
+func (d *SliceDecoder) ReadInt64() int64 {
+	return int64(d.readUint64("ReadInt64"))
+}
+
+// ReadUvarint reads a variable length integer, up to 10 bytes using zig-zag protobuf encoding.
+func (d *SliceDecoder) ReadUvarint() uint64 {
+	return d.readUvarint("ReadUvarint")
+}
+
+// ReadVarint reads a variable length and signed integer, up to 10 bytes using zig-zag protobuf encoding.
+func (d *SliceDecoder) ReadVarint() int64 {
+	if d.firstErr != nil {
+		return 0
+	}
+
+	v, n := binary.Varint(d.buf[d.pos:])
+	if n <= 0 {
+		d.noteErr("ReadVarint", d.pos, d.varintErr(n))
+		return 0
+	}
+
+	d.pos += n
+
+	return v
+}
+
+// ReadFloat32 reads 4 bytes and interprets them as a float32 IEEE 754 4 byte bit sequence.
+func (d *SliceDecoder) ReadFloat32() float32 {
+	return math.Float32frombits(d.readUint32("ReadFloat32"))
+}
+
+// ReadFloat64 reads 8 bytes and interprets them as a float64 IEEE 754 4 byte bit sequence.
+func (d *SliceDecoder) ReadFloat64() float64 {
+	return math.Float64frombits(d.readUint64("ReadFloat64"))
+}
+
+// ReadComplex64 reads two float32 IEEE 754 4 byte bit sequences for the real and imaginary parts.
+func (d *SliceDecoder) ReadComplex64() complex64 {
+	rnum := math.Float32frombits(d.readUint32("ReadComplex64"))
+	inum := math.Float32frombits(d.readUint32("ReadComplex64"))
+
+	return complex(rnum, inum)
+}
+
+// ReadComplex128 reads two float64 IEEE 754 8 byte bit sequences for the real and imaginary parts.
+func (d *SliceDecoder) ReadComplex128() complex128 {
+	rnum := math.Float64frombits(d.readUint64("ReadComplex128"))
+	inum := math.Float64frombits(d.readUint64("ReadComplex128"))
+
+	return complex(rnum, inum)
+}
+
+// ReadFull reads exactly len(b) bytes. If an error occurs returns the number of read bytes.
+func (d *SliceDecoder) ReadFull(b []byte) int {
+	if d.firstErr != nil {
+		return 0
+	}
+
+	n := copy(b, d.buf[d.pos:])
+	if n < len(b) {
+		d.noteErr("ReadFull", d.pos, eofErr(n))
+	}
+
+	d.pos += n
+
+	return n
+}
+
+// Read follows the io.Reader contract. In contrast to the other methods, the returned error is not wrapped.
+func (d *SliceDecoder) Read(b []byte) (int, error) {
+	if d.firstErr != nil {
+		return 0, d.cause()
+	}
+
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	if d.pos == len(d.buf) {
+		d.noteErr("Read", d.pos, io.EOF)
+		return 0, io.EOF
+	}
+
+	n := copy(b, d.buf[d.pos:])
+	d.pos += n
+
+	return n, nil
+}
+
+// ReadByte follows the io.ByteReader contract. In contrast to the other methods, the returned error is not
+// wrapped.
+func (d *SliceDecoder) ReadByte() (byte, error) {
+	if d.firstErr != nil {
+		return 0, d.cause()
+	}
+
+	if d.pos == len(d.buf) {
+		d.noteErr("ReadByte", d.pos, io.EOF)
+		return 0, io.EOF
+	}
+
+	b := d.buf[d.pos]
+	d.pos++
+
+	return b, nil
+}
+
+// Error returns the first occurred error. Each call to any Read* method may cause an error.
+func (d *SliceDecoder) Error() error {
+	return d.firstErr
+}
+
+// next returns the next n bytes as a sub slice or nil, if not enough bytes are available.
+func (d *SliceDecoder) next(op string, n int) []byte {
+	if d.firstErr != nil {
+		return nil
+	}
+
+	if n < 0 {
+		d.noteErr(op, d.pos, fmt.Errorf("negative length %d", n))
+		return nil
+	}
+
+	if d.Remaining() < n {
+		d.noteErr(op, d.pos, eofErr(d.Remaining()))
+		return nil
+	}
+
+	tmp := d.buf[d.pos : d.pos+n : d.pos+n]
+	d.pos += n
+
+	return tmp
+}
+
+func (d *SliceDecoder) readUint8(op string) uint8 {
+	if d.firstErr == nil && d.pos < len(d.buf) {
+		v := d.buf[d.pos]
+		d.pos++
+
+		return v
+	}
+
+	d.next(op, 1)
+
+	return 0
+}
+
+func (d *SliceDecoder) readUint16(op string) uint16 {
+	if tmp := d.next(op, 2); tmp != nil {
+		return d.order.Uint16(tmp)
+	}
+
+	return 0
+}
+
+func (d *SliceDecoder) readUint24(op string) uint32 {
+	if tmp := d.next(op, 3); tmp != nil {
+		return d.order.Uint24(tmp)
+	}
+
+	return 0
+}
+
+func (d *SliceDecoder) readUint32(op string) uint32 {
+	if tmp := d.next(op, 4); tmp != nil {
+		return d.order.Uint32(tmp)
+	}
+
+	return 0
+}
+
+func (d *SliceDecoder) readUint40(op string) uint64 {
+	if tmp := d.next(op, 5); tmp != nil {
+		return d.order.Uint40(tmp)
+	}
+
+	return 0
+}
+
+func (d *SliceDecoder) readUint48(op string) uint64 {
+	if tmp := d.next(op, 6); tmp != nil {
+		return d.order.Uint48(tmp)
+	}
+
+	return 0
+}
+
+func (d *SliceDecoder) readUint56(op string) uint64 {
+	if tmp := d.next(op, 7); tmp != nil {
+		return d.order.Uint56(tmp)
+	}
+
+	return 0
+}
+
+func (d *SliceDecoder) readUint64(op string) uint64 {
+	if tmp := d.next(op, 8); tmp != nil {
+		return d.order.Uint64(tmp)
+	}
+
+	return 0
+}
+
+func (d *SliceDecoder) readUvarint(op string) uint64 {
+	if d.firstErr != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(d.buf[d.pos:])
+	if n <= 0 {
+		d.noteErr(op, d.pos, d.varintErr(n))
+		return 0
+	}
+
+	d.pos += n
+
+	return v
+}
+
+// varintErr converts the result of binary.Uvarint or binary.Varint into the same errors as returned
+// by binary.ReadUvarint.
+func (d *SliceDecoder) varintErr(n int) error {
+	if n < 0 {
+		return errVarintOverflow
+	}
+
+	return eofErr(d.Remaining())
+}
+
+// eofErr returns io.EOF if nothing could be read, otherwise io.ErrUnexpectedEOF, just like io.ReadFull.
+func eofErr(read int) error {
+	if read == 0 {
+		return io.EOF
+	}
+
+	return io.ErrUnexpectedEOF
+}
+
+func (d *SliceDecoder) noteErr(op string, offset int, err error) {
+	if d.firstErr == nil {
+		d.firstErr = &DecodeError{Offset: int64(offset), Op: op, Order: d.order, Err: err}
+	}
+}
+
+// cause returns the unwrapped first error.
+func (d *SliceDecoder) cause() error {
+	if err, ok := d.firstErr.(*DecodeError); ok {
+		return err.Err
+	}
+
+	return d.firstErr
+}
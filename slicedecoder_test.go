@@ -0,0 +1,176 @@
+package ioutil
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func writeSliceDecoderSample(dout DataOutput) {
+	dout.WriteBool(true)
+	dout.WriteInt8(-3)
+	dout.WriteUint16(0xABCD)
+	dout.WriteInt24(-123456)
+	dout.WriteUint32(0xDEADBEEF)
+	dout.WriteInt40(-549755813887)
+	dout.WriteUint48(0x123456789ABC)
+	dout.WriteInt56(-36028797018963967)
+	dout.WriteUint64(MaxUint64)
+	dout.WriteVarint(-300)
+	dout.WriteUvarint(1 << 40)
+	dout.WriteFloat32(3.5)
+	dout.WriteFloat64(-2.25)
+	dout.WriteComplex64(complex(1, -1))
+	dout.WriteComplex128(complex(-2, 2))
+	dout.WriteBlob(I16, []byte{1, 2, 3})
+	dout.WriteUTF8(IVar, "hello world")
+	dout.WriteBytes(9, 8, 7)
+}
+
+func readSliceDecoderSample(din DataInput) []interface{} {
+	return []interface{}{
+		din.ReadBool(),
+		din.ReadInt8(),
+		din.ReadUint16(),
+		din.ReadInt24(),
+		din.ReadUint32(),
+		din.ReadInt40(),
+		din.ReadUint48(),
+		din.ReadInt56(),
+		din.ReadUint64(),
+		din.ReadVarint(),
+		din.ReadUvarint(),
+		din.ReadFloat32(),
+		din.ReadFloat64(),
+		din.ReadComplex64(),
+		din.ReadComplex128(),
+		string(din.ReadBlob(I16)),
+		din.ReadUTF8(IVar),
+		string(din.ReadBytes(3)),
+	}
+}
+
+func TestSliceDecoder(t *testing.T) {
+	for _, order := range []ByteOrder{LittleEndian, BigEndian} {
+		buf := &bytes.Buffer{}
+		writeSliceDecoderSample(NewDataOutput(order, buf))
+
+		expected := readSliceDecoderSample(NewDataInput(order, bytes.NewReader(buf.Bytes())))
+		dec := NewSliceDecoder(order, buf.Bytes())
+		actual := readSliceDecoderSample(dec)
+
+		if dec.Error() != nil {
+			t.Fatal(dec.Error())
+		}
+
+		for i := range expected {
+			if expected[i] != actual[i] {
+				t.Fatalf("%s %d: expected %v but got %v", order, i, expected[i], actual[i])
+			}
+		}
+
+		if dec.Remaining() != 0 || dec.Position() != int64(buf.Len()) {
+			t.Fatalf("expected all bytes to be consumed but %d remain", dec.Remaining())
+		}
+
+		if _, err := dec.ReadByte(); err != io.EOF {
+			t.Fatalf("expected EOF but got %v", err)
+		}
+	}
+}
+
+func TestSliceDecoder_ZeroCopy(t *testing.T) {
+	buf := &bytes.Buffer{}
+	dout := NewDataOutput(LittleEndian, buf)
+	dout.WriteBlob(I8, []byte{1, 2, 3})
+
+	data := buf.Bytes()
+	blob := NewSliceDecoder(LittleEndian, data).ReadBlob(I8)
+	data[1] = 42
+
+	if blob[0] != 42 {
+		t.Fatalf("expected a sub slice but got a copy")
+	}
+}
+
+func TestSliceDecoder_Truncated(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writeSliceDecoderSample(NewDataOutput(BigEndian, buf))
+
+	// every truncation must fail with the same offset and operation as the Decoder reports
+	for i := 0; i < buf.Len(); i++ {
+		data := buf.Bytes()[:i]
+		din := NewDataInput(BigEndian, bytes.NewReader(data))
+		dec := NewSliceDecoder(BigEndian, data)
+
+		readSliceDecoderSample(din)
+		readSliceDecoderSample(dec)
+
+		var expected, actual *DecodeError
+		if !errors.As(din.Error(), &expected) || !errors.As(dec.Error(), &actual) {
+			t.Fatalf("%d: expected errors but got %v and %v", i, din.Error(), dec.Error())
+		}
+
+		if expected.Offset != actual.Offset || expected.Op != actual.Op || expected.Err != actual.Err {
+			t.Fatalf("%d: expected %v but got %v", i, expected, actual)
+		}
+	}
+}
+
+func BenchmarkSliceDecoder(b *testing.B) {
+	buf := &bytes.Buffer{}
+	dout := NewDataOutput(LittleEndian, buf)
+
+	for i := 0; i < 64; i++ {
+		writeSliceDecoderSample(dout)
+	}
+
+	data := buf.Bytes()
+
+	b.Run("DataInput", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+
+		for i := 0; i < b.N; i++ {
+			din := NewDataInput(LittleEndian, bytes.NewReader(data))
+			for j := 0; j < 64; j++ {
+				readBenchSample(din)
+			}
+		}
+	})
+
+	b.Run("SliceDecoder", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(data)))
+
+		for i := 0; i < b.N; i++ {
+			din := NewSliceDecoder(LittleEndian, data)
+			for j := 0; j < 64; j++ {
+				readBenchSample(din)
+			}
+		}
+	})
+}
+
+// readBenchSample reads the sample without boxing the values, to only measure the decoding.
+func readBenchSample(din DataInput) {
+	din.ReadBool()
+	din.ReadInt8()
+	din.ReadUint16()
+	din.ReadInt24()
+	din.ReadUint32()
+	din.ReadInt40()
+	din.ReadUint48()
+	din.ReadInt56()
+	din.ReadUint64()
+	din.ReadVarint()
+	din.ReadUvarint()
+	din.ReadFloat32()
+	din.ReadFloat64()
+	din.ReadComplex64()
+	din.ReadComplex128()
+	din.ReadBlob(I16)
+	din.ReadUTF8(IVar)
+	din.ReadBytes(3)
+}
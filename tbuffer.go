@@ -0,0 +1,1035 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"fmt"
+	"math"
+)
+
+// TypedBuffer is a light weight helper to modify bytes within a buffer in the byte order of a Buffer and
+// each written type has a type prefix. With the LittleEndian order it has exactly the format of the
+// TypedLittleEndianBuffer, otherwise only the byte order of the values differs.
+type TypedBuffer Buffer
+
+// WriteFloat inspects the value and chooses automatically between int 1/2/3/4/5/6/7/8 byte signed or signed
+// integers or float32/float64. The concrete value is prefixed with a type, so the written length is 2-9 byte.
+// Floats with a fraction upto 1/1000 and smaller than 16777215 are encoded as float32.
+func (t *TypedBuffer) WriteFloat(v float64) {
+	f := (*Buffer)(t)
+	const epsilon = 1e-9
+
+	// looks like an int?
+	if _, frac := math.Modf(math.Abs(v)); frac < epsilon || frac > 1.0-epsilon {
+		t.WriteInt(int64(v))
+		return
+	}
+
+	// looks like it fits into float32?
+	tmp := v * 1000
+	if _, frac := math.Modf(math.Abs(tmp)); frac < epsilon || frac > 1.0-epsilon && v <= 16777215 {
+		f.WriteType(TFloat32)
+		f.WriteFloat32(float32(v))
+		return
+	}
+
+	f.WriteType(TFloat64)
+	f.WriteFloat64(v)
+}
+
+// ReadFloat reads any number into a float
+func (t *TypedBuffer) ReadFloat() float64 {
+	f := (*Buffer)(t)
+	typ := f.ReadType()
+	switch typ {
+	case TUint8:
+		return float64(f.ReadUint8())
+	case TInt8:
+		return float64(int8(f.ReadUint8()))
+
+	case TUint16:
+		return float64(f.ReadUint16())
+	case TInt16:
+		return float64(int16(f.ReadUint16()))
+
+	case TUint24:
+		return float64(f.ReadUint24())
+	case TInt24:
+		return float64(int32(f.ReadUint24()<<8) >> 8)
+
+	case TUint32:
+		return float64(f.ReadUint32())
+	case TInt32:
+		return float64(int32(f.ReadUint32()))
+
+	case TUint40:
+		return float64(f.ReadUint40())
+	case TInt40:
+		return float64(int64(f.ReadUint40()<<24) >> 24)
+
+	case TUint48:
+		return float64(f.ReadUint48())
+	case TInt48:
+		return float64(int64(f.ReadUint48()<<16) >> 16)
+
+	case TUint56:
+		return float64(f.ReadUint56())
+	case TInt56:
+		return float64(int64(f.ReadUint56()<<8) >> 8)
+
+	case TUint64:
+		return float64(f.ReadUint64())
+	case TInt64:
+		return float64(int64(f.ReadUint64()))
+
+	case TVarint:
+		return float64(f.ReadVarint())
+	case TUvarint:
+		return float64(f.ReadUvarint())
+
+	case TFloat32:
+		return float64(f.ReadFloat32())
+	case TFloat64:
+		return f.ReadFloat64()
+	default:
+		panic("unsupported type " + typ.String())
+
+	}
+}
+
+// ReadInt reads any number into an integer
+func (t *TypedBuffer) ReadInt() int64 {
+	f := (*Buffer)(t)
+	typ := f.ReadType()
+	switch typ {
+	case TUint8:
+		return int64(f.ReadUint8())
+	case TInt8:
+		return int64(int8(f.ReadUint8()))
+
+	case TUint16:
+		return int64(f.ReadUint16())
+	case TInt16:
+		return int64(int16(f.ReadUint16()))
+
+	case TUint24:
+		return int64(f.ReadUint24())
+	case TInt24:
+		return int64(int32(f.ReadUint24()<<8) >> 8)
+
+	case TUint32:
+		return int64(f.ReadUint32())
+	case TInt32:
+		return int64(int32(f.ReadUint32()))
+
+	case TUint40:
+		return int64(f.ReadUint40())
+	case TInt40:
+		return int64(f.ReadUint40()<<24) >> 24
+
+	case TUint48:
+		return int64(f.ReadUint48())
+	case TInt48:
+		return int64(f.ReadUint48()<<16) >> 16
+
+	case TUint56:
+		return int64(f.ReadUint56())
+	case TInt56:
+		return int64(f.ReadUint56()<<8) >> 8
+
+	case TUint64:
+		return int64(f.ReadUint64())
+	case TInt64:
+		return int64(int64(f.ReadUint64()))
+
+	case TVarint:
+		return f.ReadVarint()
+	case TUvarint:
+		return int64(f.ReadUvarint())
+
+	case TFloat32:
+		return int64(f.ReadFloat32())
+	case TFloat64:
+		return int64(f.ReadFloat64())
+	default:
+		panic("unsupported type " + typ.String())
+
+	}
+}
+
+// WriteInt inspects the value and chooses automatically between 1/2/3/4/5/6/7/8 byte representation
+// of signed or unsigned values. The resulting size is 2-9 byte. This contains a lot of branches.
+// The concrete value is prefixed with a type, so the written length is 2-9 byte.
+func (t *TypedBuffer) WriteInt(v int64) {
+	f := (*Buffer)(t)
+
+	// The structure increases the minimum amount of checks but the worst case amount of checks
+	// is halfed.
+
+	if v >= int64(MinInt8) && v <= int64(MaxUint8) {
+		if v > int64(MaxInt8) {
+			f.WriteType(TUint8)
+		} else {
+			f.WriteType(TInt8)
+		}
+		f.WriteUint8(uint8(v))
+		return
+	}
+
+	if v >= int64(MinInt16) && v <= int64(MaxUint16) {
+		if v > int64(MaxInt16) {
+			f.WriteType(TUint16)
+		} else {
+			f.WriteType(TInt16)
+		}
+		f.WriteUint16(uint16(v))
+		return
+	}
+
+	if v >= int64(MinInt24) && v <= int64(MaxUint24) {
+		if v > int64(MaxInt24) {
+			f.WriteType(TUint24)
+		} else {
+			f.WriteType(TInt24)
+		}
+		f.WriteUint24(uint32(v))
+		return
+	}
+
+	if v >= int64(MinInt32) && v <= int64(MaxUint32) {
+		if v > int64(MaxInt32) {
+			f.WriteType(TUint32)
+		} else {
+			f.WriteType(TInt32)
+		}
+		f.WriteUint32(uint32(v))
+		return
+	}
+
+	if v >= int64(MinInt40) && v <= int64(MaxUint40) {
+		if v > int64(MaxInt40) {
+			f.WriteType(TUint40)
+		} else {
+			f.WriteType(TInt40)
+		}
+		f.WriteUint40(uint64(v))
+		return
+	}
+
+	if v >= int64(MinInt48) && v <= int64(MaxUint48) {
+		if v > int64(MaxInt48) {
+			f.WriteType(TUint48)
+		} else {
+			f.WriteType(TInt48)
+		}
+		f.WriteUint48(uint64(v))
+		return
+	}
+
+	if v >= int64(MinInt56) && v <= int64(MaxUint56) {
+		if v > int64(MaxInt56) {
+			f.WriteType(TUint56)
+		} else {
+			f.WriteType(TInt56)
+		}
+		f.WriteUint56(uint64(v))
+		return
+	}
+
+	f.WriteType(TInt64)
+	f.WriteUint64(uint64(v))
+}
+
+// WriteString determines how many bytes the string has and chooses between an 1,2,3 or 4 byte length prefix. It
+// is prefixed with a type, indicating the max size and followed by the actual length prefix and string bytes.
+func (t *TypedBuffer) WriteString(str string) {
+	f := (*Buffer)(t)
+
+	if len(str) <= int(MaxUint8) {
+		f.WriteType(TString8)
+		f.WriteString8(str)
+		return
+	}
+
+	if len(str) <= int(MaxUint16) {
+		f.WriteType(TString16)
+		f.WriteString16(str)
+		return
+	}
+
+	if len(str) <= int(MaxUint24) {
+		f.WriteType(TString24)
+		f.WriteString24(str)
+		return
+	}
+
+	f.WriteType(TString32)
+	f.WriteString32(str)
+	return
+}
+
+// ReadString reads a string8/16/24 or 32 string into the strBuffer and returns a mutable string from it.
+func (t *TypedBuffer) ReadString(strBuffer []byte) string {
+	if strBuffer == nil {
+		//ups, need to work around our mutable owned string approach
+		// otherwise we will get weired sigsegv somewhere later
+		tmp := make([]byte, 1024*64) // 64k
+		strBuffer = tmp
+	}
+	f := (*Buffer)(t)
+
+	typ := f.ReadType()
+	switch typ {
+	case TString8:
+		return f.ReadString8(strBuffer)
+	case TString16:
+		return f.ReadString16(strBuffer)
+	case TString24:
+		return f.ReadString24(strBuffer)
+	case TString32:
+		return f.ReadString32(strBuffer)
+	default:
+		panic("unsupported type " + typ.String())
+	}
+}
+
+// WriteBlob determines how many bytes the buffer has and chooses between an 1,2,3 or 4 byte length prefix. It
+// is prefixed with a blob type, indicating the max size and followed by the actual length prefix and string bytes.
+func (t *TypedBuffer) WriteBlob(b []byte) {
+	f := (*Buffer)(t)
+
+	if len(b) <= int(MaxUint8) {
+		f.WriteType(TBlob8)
+		f.WriteBlob8(b)
+		return
+	}
+
+	if len(b) <= int(MaxUint16) {
+		f.WriteType(TBlob16)
+		f.WriteBlob16(b)
+		return
+	}
+
+	if len(b) <= int(MaxUint24) {
+		f.WriteType(TBlob24)
+		f.WriteBlob24(b)
+		return
+	}
+
+	f.WriteType(TBlob32)
+	f.WriteBlob32(b)
+	return
+}
+
+// ReadBlob reads a blob8/16/24 or 32 into the buffer.
+func (t *TypedBuffer) ReadBlob(b []byte) int {
+	f := (*Buffer)(t)
+
+	typ := f.ReadType()
+	switch typ {
+	case TBlob8:
+		return f.ReadBlob8(b)
+	case TBlob16:
+		return f.ReadBlob16(b)
+	case TBlob24:
+		return f.ReadBlob24(b)
+	case TBlob32:
+		return f.ReadBlob32(b)
+	default:
+		panic("unsupported type " + typ.String())
+	}
+}
+
+func (t *TypedBuffer) WriteUint8(v uint8) {
+	f := (*Buffer)(t)
+	f.WriteType(TUint8)
+	f.WriteUint8(v)
+}
+
+func (t *TypedBuffer) WriteInt8(v int8) {
+	f := (*Buffer)(t)
+	f.WriteType(TInt8)
+	f.WriteUint8(uint8(v))
+}
+
+func (t *TypedBuffer) WriteUint16(v uint16) {
+	f := (*Buffer)(t)
+	f.WriteType(TUint16)
+	f.WriteUint16(v)
+}
+
+func (t *TypedBuffer) WriteInt16(v int16) {
+	f := (*Buffer)(t)
+	f.WriteType(TInt16)
+	f.WriteUint16(uint16(v))
+}
+
+func (t *TypedBuffer) WriteUint24(v uint32) {
+	f := (*Buffer)(t)
+	f.WriteType(TUint24)
+	f.WriteUint24(v)
+}
+
+func (t *TypedBuffer) WriteInt24(v int32) {
+	f := (*Buffer)(t)
+	f.WriteType(TInt24)
+	f.WriteUint24(uint32(v))
+}
+
+func (t *TypedBuffer) WriteUint32(v uint32) {
+	f := (*Buffer)(t)
+	f.WriteType(TUint32)
+	f.WriteUint32(v)
+}
+
+func (t *TypedBuffer) WriteInt32(v int32) {
+	f := (*Buffer)(t)
+	f.WriteType(TInt32)
+	f.WriteUint32(uint32(v))
+}
+
+func (t *TypedBuffer) WriteUint40(v uint64) {
+	f := (*Buffer)(t)
+	f.WriteType(TUint40)
+	f.WriteUint40(v)
+}
+
+func (t *TypedBuffer) WriteInt40(v int64) {
+	f := (*Buffer)(t)
+	f.WriteType(TInt40)
+	f.WriteUint40(uint64(v))
+}
+
+func (t *TypedBuffer) WriteUint48(v uint64) {
+	f := (*Buffer)(t)
+	f.WriteType(TUint48)
+	f.WriteUint48(v)
+}
+
+func (t *TypedBuffer) WriteInt48(v int64) {
+	f := (*Buffer)(t)
+	f.WriteType(TInt48)
+	f.WriteUint48(uint64(v))
+}
+
+func (t *TypedBuffer) WriteUint56(v uint64) {
+	f := (*Buffer)(t)
+	f.WriteType(TUint56)
+	f.WriteUint56(v)
+}
+
+func (t *TypedBuffer) WriteInt56(v int64) {
+	f := (*Buffer)(t)
+	f.WriteType(TInt56)
+	f.WriteUint56(uint64(v))
+}
+
+func (t *TypedBuffer) WriteUint64(v uint64) {
+	f := (*Buffer)(t)
+	f.WriteType(TUint64)
+	f.WriteUint64(v)
+}
+
+func (t *TypedBuffer) WriteInt64(v int64) {
+	f := (*Buffer)(t)
+	f.WriteType(TInt64)
+	f.WriteUint64(uint64(v))
+}
+
+func (t *TypedBuffer) WriteFloat32(v float32) {
+	f := (*Buffer)(t)
+	f.WriteType(TFloat32)
+	f.WriteFloat32(v)
+}
+
+func (t *TypedBuffer) WriteFloat64(v float64) {
+	f := (*Buffer)(t)
+	f.WriteType(TFloat64)
+	f.WriteFloat64(v)
+}
+
+func (t *TypedBuffer) WriteComplex64(v complex64) {
+	f := (*Buffer)(t)
+	f.WriteType(TComplex64)
+	f.WriteComplex64(v)
+}
+
+func (t *TypedBuffer) WriteComplex128(v complex128) {
+	f := (*Buffer)(t)
+	f.WriteType(TComplex128)
+	f.WriteComplex128(v)
+}
+
+func (t *TypedBuffer) WriteBlob8(v []byte) {
+	f := (*Buffer)(t)
+	f.WriteType(TBlob8)
+	f.WriteBlob8(v)
+}
+
+func (t *TypedBuffer) WriteBlob16(v []byte) {
+	f := (*Buffer)(t)
+	f.WriteType(TBlob16)
+	f.WriteBlob16(v)
+}
+
+func (t *TypedBuffer) WriteBlob24(v []byte) {
+	f := (*Buffer)(t)
+	f.WriteType(TBlob24)
+	f.WriteBlob24(v)
+}
+
+func (t *TypedBuffer) WriteBlob32(v []byte) {
+	f := (*Buffer)(t)
+	f.WriteType(TBlob32)
+	f.WriteBlob32(v)
+}
+
+func (t *TypedBuffer) ReadUint8() uint8 {
+	f := (*Buffer)(t)
+	t.assertType(TUint8)
+	return f.ReadUint8()
+}
+
+func (t *TypedBuffer) ReadInt8() int8 {
+	f := (*Buffer)(t)
+	t.assertType(TInt8)
+	return int8(f.ReadUint8())
+}
+
+func (t *TypedBuffer) ReadUint16() uint16 {
+	f := (*Buffer)(t)
+	t.assertType(TUint16)
+	return f.ReadUint16()
+}
+
+func (t *TypedBuffer) ReadInt16() int16 {
+	f := (*Buffer)(t)
+	t.assertType(TInt16)
+	return int16(f.ReadUint16())
+}
+
+func (t *TypedBuffer) ReadUint24() uint32 {
+	f := (*Buffer)(t)
+	t.assertType(TUint24)
+	return f.ReadUint24()
+}
+
+func (t *TypedBuffer) ReadInt24() int32 {
+	f := (*Buffer)(t)
+	t.assertType(TInt24)
+	return int32(f.ReadUint24()<<8) >> 8
+}
+
+func (t *TypedBuffer) ReadUint32() uint32 {
+	f := (*Buffer)(t)
+	t.assertType(TUint32)
+	return f.ReadUint32()
+}
+
+func (t *TypedBuffer) ReadInt32() int32 {
+	f := (*Buffer)(t)
+	t.assertType(TInt32)
+	return int32(f.ReadUint32())
+}
+
+func (t *TypedBuffer) ReadUint40() uint64 {
+	f := (*Buffer)(t)
+	t.assertType(TUint40)
+	return f.ReadUint40()
+}
+
+func (t *TypedBuffer) ReadInt40() int64 {
+	f := (*Buffer)(t)
+	t.assertType(TInt40)
+	return int64(f.ReadUint40()<<24) >> 24
+}
+
+func (t *TypedBuffer) ReadUint48() uint64 {
+	f := (*Buffer)(t)
+	t.assertType(TUint48)
+	return f.ReadUint48()
+}
+
+func (t *TypedBuffer) ReadInt48() int64 {
+	f := (*Buffer)(t)
+	t.assertType(TInt48)
+	return int64(f.ReadUint48()<<16) >> 16
+}
+
+func (t *TypedBuffer) ReadUint56() uint64 {
+	f := (*Buffer)(t)
+	t.assertType(TUint56)
+	return f.ReadUint56()
+}
+
+func (t *TypedBuffer) ReadInt56() int64 {
+	f := (*Buffer)(t)
+	t.assertType(TInt56)
+	return int64(f.ReadUint56()<<8) >> 8
+}
+
+func (t *TypedBuffer) ReadUint64() uint64 {
+	f := (*Buffer)(t)
+	t.assertType(TUint64)
+	return f.ReadUint64()
+}
+
+func (t *TypedBuffer) ReadInt64() int64 {
+	f := (*Buffer)(t)
+	t.assertType(TInt64)
+	return int64(f.ReadUint64())
+}
+
+func (t *TypedBuffer) ReadBlob8(dst []byte) int {
+	f := (*Buffer)(t)
+	t.assertType(TBlob8)
+	return f.ReadBlob8(dst)
+}
+
+func (t *TypedBuffer) ReadBlob16(dst []byte) int {
+	f := (*Buffer)(t)
+	t.assertType(TBlob16)
+	return f.ReadBlob16(dst)
+}
+
+func (t *TypedBuffer) ReadBlob24(dst []byte) int {
+	f := (*Buffer)(t)
+	t.assertType(TBlob24)
+	return f.ReadBlob24(dst)
+}
+
+func (t *TypedBuffer) ReadBlob32(dst []byte) int {
+	f := (*Buffer)(t)
+	t.assertType(TBlob32)
+	return f.ReadBlob32(dst)
+}
+
+func (t *TypedBuffer) ReadFloat32() float32 {
+	f := (*Buffer)(t)
+	t.assertType(TFloat32)
+	return f.ReadFloat32()
+}
+
+func (t *TypedBuffer) ReadFloat64() float64 {
+	f := (*Buffer)(t)
+	t.assertType(TFloat64)
+	return f.ReadFloat64()
+}
+
+func (t *TypedBuffer) ReadComplex64() complex64 {
+	f := (*Buffer)(t)
+	t.assertType(TComplex64)
+	return f.ReadComplex64()
+}
+
+func (t *TypedBuffer) ReadComplex128() complex128 {
+	f := (*Buffer)(t)
+	t.assertType(TComplex128)
+	return f.ReadComplex128()
+}
+
+func (t *TypedBuffer) WriteBool(v bool) {
+	f := (*Buffer)(t)
+	f.WriteType(TBool)
+	if v {
+		f.WriteUint8(1)
+	} else {
+		f.WriteUint8(0)
+	}
+}
+
+func (t *TypedBuffer) ReadBool() bool {
+	f := (*Buffer)(t)
+	t.assertType(TBool)
+	return f.ReadUint8() != 0
+}
+
+// WriteNil writes a value without any payload, e.g. to denote an absent optional field.
+func (t *TypedBuffer) WriteNil() {
+	f := (*Buffer)(t)
+	f.WriteType(TNil)
+}
+
+func (t *TypedBuffer) ReadNil() {
+	t.assertType(TNil)
+}
+
+func (t *TypedBuffer) WriteVarint(v int64) {
+	f := (*Buffer)(t)
+	f.WriteType(TVarint)
+	f.WriteVarint(v)
+}
+
+func (t *TypedBuffer) ReadVarint() int64 {
+	f := (*Buffer)(t)
+	t.assertType(TVarint)
+	return f.ReadVarint()
+}
+
+func (t *TypedBuffer) WriteUvarint(v uint64) {
+	f := (*Buffer)(t)
+	f.WriteType(TUvarint)
+	f.WriteUvarint(v)
+}
+
+func (t *TypedBuffer) ReadUvarint() uint64 {
+	f := (*Buffer)(t)
+	t.assertType(TUvarint)
+	return f.ReadUvarint()
+}
+
+// WriteArray writes the header of an array with n elements. The caller must write exactly n typed values
+// afterwards, which may be of different types and may be containers again.
+func (t *TypedBuffer) WriteArray(n int) {
+	f := (*Buffer)(t)
+	f.WriteType(TArray)
+	f.WriteUvarint(uint64(n))
+}
+
+// ReadArray reads the header of an array and returns the amount of elements, which follow.
+func (t *TypedBuffer) ReadArray() int {
+	f := (*Buffer)(t)
+	t.assertType(TArray)
+	return int(f.ReadUvarint())
+}
+
+// WriteMap writes the header of a map with n entries. The caller must write exactly n typed keys and values
+// afterwards, alternating key and value.
+func (t *TypedBuffer) WriteMap(n int) {
+	f := (*Buffer)(t)
+	f.WriteType(TMap)
+	f.WriteUvarint(uint64(n))
+}
+
+// ReadMap reads the header of a map and returns the amount of entries, which follow.
+func (t *TypedBuffer) ReadMap() int {
+	f := (*Buffer)(t)
+	t.assertType(TMap)
+	return int(f.ReadUvarint())
+}
+
+// BeginRecord writes the header of a record with a placeholder for its length and returns the position
+// which must be passed to EndRecord, after all fields have been written.
+func (t *TypedBuffer) BeginRecord() int {
+	f := (*Buffer)(t)
+	f.WriteType(TRecord)
+	start := f.Pos
+	f.WriteUint32(0)
+	return start
+}
+
+// EndRecord patches the length of the record, which has been started at the given position.
+func (t *TypedBuffer) EndRecord(start int) {
+	f := (*Buffer)(t)
+	end := f.Pos
+	f.Pos = start
+	f.WriteUint32(uint32(end - start - 4))
+	f.Pos = end
+}
+
+// ReadRecord reads the header of a record and returns the position after its last field. A reader should
+// read the known fields and skip all unknown fields until the end has been reached, so that new fields can
+// be appended to a record without breaking older readers:
+//
+//	end := buf.ReadRecord()
+//	for buf.Pos < end {
+//		buf.Skip()
+//	}
+func (t *TypedBuffer) ReadRecord() int {
+	f := (*Buffer)(t)
+	t.assertType(TRecord)
+	n := int(f.ReadUint32())
+	return f.Pos + n
+}
+
+// Skip reads the type of the next value and drains it, including all nested values of containers. It returns
+// the amount of skipped bytes.
+func (t *TypedBuffer) Skip() int {
+	f := (*Buffer)(t)
+	oldPos := f.Pos
+	typ := f.ReadType()
+	if f.DrainFast(typ) < 0 {
+		f.Drain(typ)
+	}
+	return f.Pos - oldPos
+}
+
+func (t *TypedBuffer) assertType(kind Type) {
+	if debug {
+		f := (*Buffer)(t)
+		x := f.ReadType()
+		if x != kind {
+			panic("expected " + kind.String() + " but got " + x.String()) // this is not inlineable
+		}
+	} else {
+		t.Pos++
+	}
+}
+
+// HasNext returns true, if the buffer contains more values.
+func (t *TypedBuffer) HasNext() bool {
+	return t.Pos < len(t.Bytes)
+}
+
+// Next reads the type prefix and the following value, whatever it is. For containers only the header is read, so
+// the elements, entries or fields are returned by the following calls. Blobs are returned as sub slices of the
+// buffer, so they are only valid until the buffer is modified. Strings are copied. Like all other methods, Next
+// panics if the buffer is malformed or truncated.
+func (t *TypedBuffer) Next() (Type, Value) {
+	f := (*Buffer)(t)
+	typ := f.ReadType()
+
+	switch typ {
+	case TUint8:
+		return typ, f.ReadUint8()
+	case TUint16:
+		return typ, f.ReadUint16()
+	case TUint24:
+		return typ, f.ReadUint24()
+	case TUint32:
+		return typ, f.ReadUint32()
+	case TUint40:
+		return typ, f.ReadUint40()
+	case TUint48:
+		return typ, f.ReadUint48()
+	case TUint56:
+		return typ, f.ReadUint56()
+	case TUint64:
+		return typ, f.ReadUint64()
+	case TInt8:
+		return typ, int8(f.ReadUint8())
+	case TInt16:
+		return typ, int16(f.ReadUint16())
+	case TInt24:
+		return typ, int32(f.ReadUint24()<<8) >> 8
+	case TInt32:
+		return typ, int32(f.ReadUint32())
+	case TInt40:
+		return typ, int64(f.ReadUint40()<<24) >> 24
+	case TInt48:
+		return typ, int64(f.ReadUint48()<<16) >> 16
+	case TInt56:
+		return typ, int64(f.ReadUint56()<<8) >> 8
+	case TInt64:
+		return typ, int64(f.ReadUint64())
+	case TBlob8, TString8:
+		return typ, t.nextBlob(typ, int(f.ReadUint8()))
+	case TBlob16, TString16:
+		return typ, t.nextBlob(typ, int(f.ReadUint16()))
+	case TBlob24, TString24:
+		return typ, t.nextBlob(typ, int(f.ReadUint24()))
+	case TBlob32, TString32:
+		return typ, t.nextBlob(typ, int(f.ReadUint32()))
+	case TFloat32:
+		return typ, f.ReadFloat32()
+	case TFloat64:
+		return typ, f.ReadFloat64()
+	case TComplex64:
+		return typ, f.ReadComplex64()
+	case TComplex128:
+		return typ, f.ReadComplex128()
+	case TBool:
+		return typ, f.ReadUint8() != 0
+	case TNil:
+		return typ, nil
+	case TVarint:
+		return typ, f.ReadVarint()
+	case TUvarint:
+		return typ, f.ReadUvarint()
+	case TArray, TMap:
+		return typ, int(f.ReadUvarint())
+	case TRecord:
+		return typ, int(f.ReadUint32())
+	default:
+		panic("unsupported type " + typ.String())
+	}
+}
+
+func (t *TypedBuffer) nextBlob(typ Type, n int) Value {
+	if t.Pos+n > len(t.Bytes) {
+		panic(fmt.Sprintf("%s of %d bytes exceeds the buffer", typ, n))
+	}
+
+	b := t.Bytes[t.Pos : t.Pos+n : t.Pos+n]
+	t.Pos += n
+
+	if typ >= TString8 && typ <= TString32 {
+		return string(b)
+	}
+
+	return b
+}
+
+// WriteValue writes the type prefix and the value, which must have the dynamic type as returned by Next. For
+// containers only the header is written. Blobs
+// and strings which do not fit into the length prefix are truncated, like WriteBlob8 etc. do.
+func (t *TypedBuffer) WriteValue(typ Type, v Value) {
+	f := (*Buffer)(t)
+	f.WriteType(typ)
+
+	if typ == TNil && v == nil {
+		return
+	}
+
+	switch val := v.(type) {
+	case bool:
+		if typ == TBool {
+			if val {
+				f.WriteUint8(1)
+			} else {
+				f.WriteUint8(0)
+			}
+
+			return
+		}
+	case int:
+		switch typ {
+		case TArray, TMap:
+			f.WriteUvarint(uint64(val))
+			return
+		case TRecord:
+			f.WriteUint32(uint32(val))
+			return
+		}
+	case uint8:
+		if typ == TUint8 {
+			f.WriteUint8(val)
+			return
+		}
+	case uint16:
+		if typ == TUint16 {
+			f.WriteUint16(val)
+			return
+		}
+	case uint32:
+		switch typ {
+		case TUint24:
+			f.WriteUint24(val)
+			return
+		case TUint32:
+			f.WriteUint32(val)
+			return
+		}
+	case uint64:
+		switch typ {
+		case TUvarint:
+			f.WriteUvarint(val)
+			return
+		case TUint40:
+			f.WriteUint40(val)
+			return
+		case TUint48:
+			f.WriteUint48(val)
+			return
+		case TUint56:
+			f.WriteUint56(val)
+			return
+		case TUint64:
+			f.WriteUint64(val)
+			return
+		}
+	case int8:
+		if typ == TInt8 {
+			f.WriteUint8(uint8(val))
+			return
+		}
+	case int16:
+		if typ == TInt16 {
+			f.WriteUint16(uint16(val))
+			return
+		}
+	case int32:
+		switch typ {
+		case TInt24:
+			f.WriteUint24(uint32(val))
+			return
+		case TInt32:
+			f.WriteUint32(uint32(val))
+			return
+		}
+	case int64:
+		switch typ {
+		case TVarint:
+			f.WriteVarint(val)
+			return
+		case TInt40:
+			f.WriteUint40(uint64(val))
+			return
+		case TInt48:
+			f.WriteUint48(uint64(val))
+			return
+		case TInt56:
+			f.WriteUint56(uint64(val))
+			return
+		case TInt64:
+			f.WriteUint64(uint64(val))
+			return
+		}
+	case float32:
+		if typ == TFloat32 {
+			f.WriteFloat32(val)
+			return
+		}
+	case float64:
+		if typ == TFloat64 {
+			f.WriteFloat64(val)
+			return
+		}
+	case complex64:
+		if typ == TComplex64 {
+			f.WriteComplex64(val)
+			return
+		}
+	case complex128:
+		if typ == TComplex128 {
+			f.WriteComplex128(val)
+			return
+		}
+	case []byte:
+		switch typ {
+		case TBlob8:
+			f.WriteBlob8(val)
+			return
+		case TBlob16:
+			f.WriteBlob16(val)
+			return
+		case TBlob24:
+			f.WriteBlob24(val)
+			return
+		case TBlob32:
+			f.WriteBlob32(val)
+			return
+		}
+	case string:
+		switch typ {
+		case TString8:
+			f.WriteString8(val)
+			return
+		case TString16:
+			f.WriteString16(val)
+			return
+		case TString24:
+			f.WriteString24(val)
+			return
+		case TString32:
+			f.WriteString32(val)
+			return
+		}
+	}
+
+	panic(fmt.Sprintf("cannot write %T as %s", v, typ))
+}
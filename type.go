@@ -34,11 +34,21 @@ const (
 	TFloat64    Type = 26
 	TComplex64  Type = 27
 	TComplex128 Type = 28
+	TBool       Type = 29
+	TNil        Type = 30
+	TVarint     Type = 31
+	TUvarint    Type = 32
+	TArray      Type = 33 // TArray is followed by an uvarint count and the typed elements
+	TMap        Type = 34 // TMap is followed by an uvarint count and the typed key and value of each entry
+	TRecord     Type = 35 // TRecord is followed by the uint32 byte length of the typed fields
 
 	minTValid = TUint8
-	maxTValid = TFloat64
+	maxTValid = TRecord
 )
 
+// maxTypedDepth limits the nesting of arrays, maps and records, which are walked recursively.
+const maxTypedDepth = 1000
+
 func (d Type) IsValid() bool {
 	return d >= minTValid && d <= maxTValid
 }
@@ -80,12 +90,21 @@ func (d Type) IsNumber() bool {
 	case TFloat32:
 		fallthrough
 	case TFloat64:
+		fallthrough
+	case TVarint:
+		fallthrough
+	case TUvarint:
 		return true
 	default:
 		return false
 	}
 }
 
+// IsContainer returns true for arrays, maps and records.
+func (d Type) IsContainer() bool {
+	return d == TArray || d == TMap || d == TRecord
+}
+
 func (d Type) String() string {
 	switch d {
 	case TUint8:
@@ -144,6 +163,20 @@ func (d Type) String() string {
 		return "complex64"
 	case TComplex128:
 		return "complex128"
+	case TBool:
+		return "bool"
+	case TNil:
+		return "nil"
+	case TVarint:
+		return "varint"
+	case TUvarint:
+		return "uvarint"
+	case TArray:
+		return "array"
+	case TMap:
+		return "map"
+	case TRecord:
+		return "record"
 	default:
 		return "unspecified " + strconv.Itoa(int(d))
 	}
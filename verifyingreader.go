@@ -0,0 +1,117 @@
+/*
+ * Copyright 2020 Torben Schinke
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ioutil
+
+import (
+	"bytes"
+	"hash"
+	"io"
+)
+
+// A VerifyingReader passes all bytes through a HashReader and verifies the digest, when the wrapped reader
+// returns io.EOF. If the digest is not the expected one, the io.EOF is replaced by a ChecksumMismatch, so a caller
+// which just drains the reader, e.g. by io.Copy or ioutil.ReadAll, cannot forget the comparison. Optionally the
+// length can be verified as well, so that reading beyond the expected length fails immediately with a
+// LengthMismatch, without passing through any superfluous bytes. Once an error has been returned, any call
+// returns the same error.
+type VerifyingReader struct {
+	hashReader *HashReader
+	expected   []byte
+	length     int64 // length is the expected length or negative, if unknown
+	probe      [1]byte
+	firstErr   error
+}
+
+// NewVerifyingReader creates a new instance, which calculates the digest using h and compares it with the
+// expected digest at the end of the stream.
+func NewVerifyingReader(h hash.Hash, reader io.Reader, expected []byte) *VerifyingReader {
+	return &VerifyingReader{hashReader: NewHashReader(h, reader), expected: expected, length: -1}
+}
+
+// SetLength sets the expected length of the stream. A negative length disables the length verification.
+func (v *VerifyingReader) SetLength(length int64) {
+	v.length = length
+}
+
+func (v *VerifyingReader) Read(p []byte) (int, error) {
+	if v.firstErr != nil {
+		return 0, v.firstErr
+	}
+
+	if v.length >= 0 {
+		remaining := v.length - int64(v.hashReader.Count())
+		if remaining == 0 {
+			return 0, v.probeEOF()
+		}
+
+		if int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+
+	n, err := v.hashReader.Read(p)
+	if err == io.EOF {
+		err = v.verify()
+	}
+
+	if err != nil {
+		v.firstErr = err
+	}
+
+	return n, err
+}
+
+// probeEOF reads beyond the expected length, which must result in io.EOF. The probed byte is not hashed.
+func (v *VerifyingReader) probeEOF() error {
+	n, err := v.hashReader.reader.Read(v.probe[:])
+
+	switch {
+	case n > 0:
+		err = LengthMismatch{Expected: v.length, Actual: v.length + int64(n)}
+	case err == io.EOF:
+		err = v.verify()
+	}
+
+	if err != nil {
+		v.firstErr = err
+	}
+
+	return err
+}
+
+// verify returns io.EOF, if the length and the digest are the expected ones.
+func (v *VerifyingReader) verify() error {
+	if v.length >= 0 && int64(v.hashReader.Count()) != v.length {
+		return LengthMismatch{Expected: v.length, Actual: int64(v.hashReader.Count())}
+	}
+
+	if actual := v.hashReader.Sum(); !bytes.Equal(actual, v.expected) {
+		return ChecksumMismatch{Expected: v.expected, Actual: actual}
+	}
+
+	return io.EOF
+}
+
+// Sum returns the digest of the bytes read so far.
+func (v *VerifyingReader) Sum() []byte {
+	return v.hashReader.Sum()
+}
+
+// Count returns the total amount of read bytes so far.
+func (v *VerifyingReader) Count() uint64 {
+	return v.hashReader.Count()
+}
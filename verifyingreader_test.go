@@ -0,0 +1,74 @@
+package ioutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"testing/iotest"
+)
+
+func TestVerifyingReader(t *testing.T) {
+	data := []byte("hello world")
+	digest := sha256.Sum256(data)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		length int64
+		err    error
+	}{
+		{"valid", data, -1, nil},
+		{"valid with length", data, int64(len(data)), nil},
+		{"corrupted", []byte("hello World"), -1, ChecksumMismatch{Expected: digest[:]}},
+		{"truncated", data[:5], int64(len(data)), LengthMismatch{Expected: 11, Actual: 5}},
+		{"too long", append(data, '!'), int64(len(data)), LengthMismatch{Expected: 11, Actual: 12}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewVerifyingReader(sha256.New(), iotest.OneByteReader(bytes.NewReader(tt.data)), digest[:])
+			reader.SetLength(tt.length)
+
+			buf, err := ioutil.ReadAll(reader)
+
+			switch expected := tt.err.(type) {
+			case nil:
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(buf, data) {
+					t.Fatalf("expected %q but got %q", data, buf)
+				}
+			case ChecksumMismatch:
+				var mismatch ChecksumMismatch
+				if !errors.As(err, &mismatch) || !bytes.Equal(mismatch.Expected, expected.Expected) ||
+					bytes.Equal(mismatch.Actual, expected.Expected) {
+					t.Fatalf("expected a checksum mismatch but got %v", err)
+				}
+			default:
+				if err != tt.err {
+					t.Fatalf("expected %v but got %v", tt.err, err)
+				}
+
+				if int64(len(buf)) > tt.length {
+					t.Fatalf("read %d bytes beyond the expected length", int64(len(buf))-tt.length)
+				}
+			}
+
+			// the error is sticky
+			_, err2 := reader.Read(make([]byte, 1))
+			if err == nil {
+				err = io.EOF
+			}
+
+			if !reflect.DeepEqual(err, err2) {
+				t.Fatalf("expected %v but got %v", err, err2)
+			}
+		})
+	}
+}